@@ -0,0 +1,291 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sieglu2/go_foundation/foundation"
+	"github.com/sieglu2/go_foundation/llm"
+)
+
+// batchRecord is a line of the input: a prompt, optionally after a system prompt, or a
+// whole conversation.
+type batchRecord struct {
+	ID       string           `json:"id"`
+	System   string           `json:"system,omitempty"`
+	Prompt   string           `json:"prompt,omitempty"`
+	Messages []llm.LlmMessage `json:"messages,omitempty"`
+}
+
+// batchOutput is a line of the output, one per record run.
+type batchOutput struct {
+	ID           string       `json:"id"`
+	Content      string       `json:"content,omitempty"`
+	Model        string       `json:"model,omitempty"`
+	FinishReason string       `json:"finish_reason,omitempty"`
+	Usage        llm.LlmUsage `json:"usage"`
+	Error        string       `json:"error,omitempty"`
+	DurationMs   int64        `json:"duration_ms"`
+}
+
+func runBatch(args []string) error {
+	flags := flag.NewFlagSet("batch", flag.ContinueOnError)
+	inPath := flags.String("in", "", "jsonl of records {id, system, prompt} or {id, messages}")
+	outPath := flags.String("out", "", "jsonl the results are appended to; the records already completed in it are skipped")
+	provider := flags.String("provider", "", "llm provider to use (claude, chatgpt, gemini, deepseek, minimax); the first available one if empty")
+	model := flags.String("model", "", "model of the provider; the provider's default if empty")
+	concurrency := flags.Int("concurrency", 4, "number of records run at the same time")
+	timeout := flags.Duration("timeout", 2*time.Minute, "timeout of every record, retries included")
+	retries := flags.Int("retries", 2, "retries of the records failing with a 429, a 5xx or a network error")
+	verbose := flags.Bool("verbose", false, "keep info logs of the llm clients")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *inPath == "" || *outPath == "" {
+		return fmt.Errorf("-in and -out are required")
+	}
+	if *model != "" && *provider == "" {
+		return fmt.Errorf("-model requires -provider")
+	}
+	if *concurrency <= 0 {
+		return fmt.Errorf("-concurrency must be positive")
+	}
+
+	if !*verbose {
+		foundation.LoadGlobalLogger(foundation.NewSugarLogger("warn", "console"))
+	}
+
+	records, err := readBatchRecords(*inPath)
+	if err != nil {
+		return err
+	}
+	completed, err := readCompletedIDs(*outPath)
+	if err != nil {
+		return err
+	}
+
+	pending := pendingBatchRecords(records, completed)
+	fmt.Fprintf(os.Stderr, "%d records, %d already completed, running %d\n", len(records), len(records)-len(pending), len(pending))
+	if len(pending) == 0 {
+		return nil
+	}
+
+	ctx := context.Background()
+
+	var client llm.LlmClient
+	if *provider == "" {
+		client, err = llm.NewLlmClient()
+	} else {
+		client, err = llm.NewLlmClientForProvider(ctx, *provider, *model)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create llm client: %v", err)
+	}
+	client = llm.NewInterceptedClient(client, llm.RetryInterceptor(*retries, time.Second))
+	defer client.Close()
+
+	out, err := openBatchOutput(*outPath)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	jobs := make(chan any, len(pending))
+	for _, record := range pending {
+		jobs <- record
+	}
+	close(jobs)
+
+	var mu sync.Mutex
+	var failed, done int
+	var usage llm.LlmUsage
+	err = foundation.RunInParallel(*concurrency, 0, jobs, func(a any) error {
+		output := runBatchRecord(ctx, client, a.(batchRecord), *timeout)
+
+		// every line is written whole right away, so a crash loses the running records only
+		mu.Lock()
+		defer mu.Unlock()
+		err := writeBatchOutput(out, output)
+		done++
+		if err != nil || output.Error != "" {
+			failed++
+		}
+		usage.PromptTokens += output.Usage.PromptTokens
+		usage.CompletionTokens += output.Usage.CompletionTokens
+		usage.TotalTokens += output.Usage.TotalTokens
+		fmt.Fprintf(os.Stderr, "\r%d/%d done, %d failed", done, len(pending), failed)
+		return err
+	}, func(errs []error) error {
+		// the results not written are missing from the output, so a run again retries them
+		return errors.Join(errs...)
+	})
+	fmt.Fprintf(os.Stderr, "\nusage: prompt=%d completion=%d total=%d\n", usage.PromptTokens, usage.CompletionTokens, usage.TotalTokens)
+	if err != nil {
+		return fmt.Errorf("%d of %d records failed, run again to retry them: %v", failed, len(pending), err)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d records failed, run again to retry them", failed, len(pending))
+	}
+	return nil
+}
+
+// pendingBatchRecords returns the records without a completed result, in their order.
+func pendingBatchRecords(records []batchRecord, completed map[string]bool) []batchRecord {
+	var pending []batchRecord
+	for _, record := range records {
+		if !completed[record.ID] {
+			pending = append(pending, record)
+		}
+	}
+	return pending
+}
+
+// writeBatchOutput writes output as a single line.
+func writeBatchOutput(w io.Writer, output batchOutput) error {
+	line, err := json.Marshal(output)
+	if err != nil {
+		return fmt.Errorf("failed to Marshal result of %s: %v", output.ID, err)
+	}
+	if _, err := w.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write result of %s: %v", output.ID, err)
+	}
+	return nil
+}
+
+func runBatchRecord(ctx context.Context, client llm.LlmClient, record batchRecord, timeout time.Duration) batchOutput {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	messages := record.Messages
+	if len(messages) == 0 {
+		if record.System != "" {
+			messages = append(messages, llm.LlmMessage{Role: llm.RoleSystem, Content: record.System})
+		}
+		messages = append(messages, llm.LlmMessage{Role: llm.RoleUser, Content: record.Prompt})
+	}
+
+	start := time.Now()
+	reply, err := llm.ReplyMessageDetail(ctx, client, messages)
+	output := batchOutput{
+		ID:         record.ID,
+		DurationMs: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		output.Error = err.Error()
+		return output
+	}
+	output.Content = reply.Content
+	output.Model = reply.Model
+	output.FinishReason = reply.FinishReason
+	output.Usage = reply.Usage
+	return output
+}
+
+// readBatchRecords reads the records, numbering the ones without id by their line.
+func readBatchRecords(path string) ([]batchRecord, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	defer file.Close()
+
+	var records []batchRecord
+	seen := map[string]int{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var record batchRecord
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			return nil, fmt.Errorf("invalid record on line %d of %s: %v", lineNumber, path, err)
+		}
+		if record.ID == "" {
+			record.ID = strconv.Itoa(lineNumber)
+		}
+		if record.Prompt == "" && len(record.Messages) == 0 {
+			return nil, fmt.Errorf("record %s on line %d has neither prompt nor messages", record.ID, lineNumber)
+		}
+		if previous, ok := seen[record.ID]; ok {
+			return nil, fmt.Errorf("record %s on line %d duplicates line %d", record.ID, lineNumber, previous)
+		}
+		seen[record.ID] = lineNumber
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	return records, nil
+}
+
+// readCompletedIDs returns the ids having a result without error in the output, skipping
+// the line cut by a crash.
+func readCompletedIDs(path string) (map[string]bool, error) {
+	completed := map[string]bool{}
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return completed, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var output batchOutput
+		if err := json.Unmarshal(scanner.Bytes(), &output); err != nil {
+			continue
+		}
+		if output.Error == "" {
+			completed[output.ID] = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	return completed, nil
+}
+
+// openBatchOutput opens the output for appending, ending the line cut by a crash first.
+func openBatchOutput(path string) (*os.File, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %v", path, err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to stat %s: %v", path, err)
+	}
+	if info.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := file.ReadAt(last, info.Size()-1); err != nil && err != io.EOF {
+			file.Close()
+			return nil, fmt.Errorf("failed to read %s: %v", path, err)
+		}
+		if last[0] != '\n' {
+			if _, err := file.Write([]byte("\n")); err != nil {
+				file.Close()
+				return nil, fmt.Errorf("failed to write %s: %v", path, err)
+			}
+		}
+	}
+	return file, nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBatchResume(t *testing.T) {
+	t.Run("No output yet", func(t *testing.T) {
+		completed, err := readCompletedIDs(filepath.Join(t.TempDir(), "out.jsonl"))
+		if err != nil || len(completed) != 0 {
+			t.Fatalf("expected no completed ids, got %v: %v", completed, err)
+		}
+	})
+
+	t.Run("Completed ids skip failed and cut lines", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "out.jsonl")
+		content := `{"id":"a","content":"ok"}
+{"id":"b","error":"timeout"}
+{"id":"c","content":"ok"}
+{"id":"d","cont`
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+
+		completed, err := readCompletedIDs(path)
+		if err != nil {
+			t.Fatalf("readCompletedIDs failed: %v", err)
+		}
+		if len(completed) != 2 || !completed["a"] || !completed["c"] {
+			t.Fatalf("expected a and c completed, got %v", completed)
+		}
+
+		records := []batchRecord{{ID: "a"}, {ID: "b"}, {ID: "c"}, {ID: "d"}}
+		pending := pendingBatchRecords(records, completed)
+		if len(pending) != 2 || pending[0].ID != "b" || pending[1].ID != "d" {
+			t.Fatalf("expected b and d pending, got %+v", pending)
+		}
+	})
+
+	t.Run("Cut line ended before appending", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "out.jsonl")
+		if err := os.WriteFile(path, []byte(`{"id":"a","content":"ok"}`+"\n"+`{"id":"b","cont`), 0644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+
+		out, err := openBatchOutput(path)
+		if err != nil {
+			t.Fatalf("openBatchOutput failed: %v", err)
+		}
+		if err := writeBatchOutput(out, batchOutput{ID: "b", Content: "ok"}); err != nil {
+			t.Fatalf("writeBatchOutput failed: %v", err)
+		}
+		out.Close()
+
+		completed, err := readCompletedIDs(path)
+		if err != nil || len(completed) != 2 || !completed["b"] {
+			t.Fatalf("expected a and b completed, got %v: %v", completed, err)
+		}
+	})
+
+	t.Run("Whole output left untouched", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "out.jsonl")
+		content := `{"id":"a","content":"ok"}` + "\n"
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+
+		out, err := openBatchOutput(path)
+		if err != nil {
+			t.Fatalf("openBatchOutput failed: %v", err)
+		}
+		out.Close()
+
+		data, err := os.ReadFile(path)
+		if err != nil || string(data) != content {
+			t.Fatalf("expected the output unchanged, got %q: %v", data, err)
+		}
+	})
+
+	t.Run("Write failure reported", func(t *testing.T) {
+		out, err := openBatchOutput(filepath.Join(t.TempDir(), "out.jsonl"))
+		if err != nil {
+			t.Fatalf("openBatchOutput failed: %v", err)
+		}
+		out.Close()
+
+		err = writeBatchOutput(out, batchOutput{ID: "a"})
+		if err == nil || !strings.Contains(err.Error(), "failed to write result of a") {
+			t.Fatalf("expected a write error, got %v", err)
+		}
+	})
+}
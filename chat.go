@@ -0,0 +1,310 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/sieglu2/go_foundation/foundation"
+	"github.com/sieglu2/go_foundation/llm"
+)
+
+const chatHelp = `Commands:
+  /image <path>   attach an image file to the next message
+  /system <path>  replace the system prompt with the content of a file
+  /save <path>    save the transcript as json
+  /load <path>    load a transcript saved by /save, switching to its provider
+  /reset          clear the conversation, keeping the system prompt
+  /usage          print the token usage of this session
+  /help           print this help
+  /exit           quit the session`
+
+type chatTranscript struct {
+	Provider string           `json:"provider,omitempty"`
+	Model    string           `json:"model,omitempty"`
+	Messages []llm.LlmMessage `json:"messages"`
+}
+
+type chatSession struct {
+	client       llm.LlmClient
+	transcript   chatTranscript
+	pendingImage *llm.Image
+	usage        llm.LlmUsage
+	out          io.Writer
+}
+
+func runChat(args []string) error {
+	flags := flag.NewFlagSet("chat", flag.ContinueOnError)
+	provider := flags.String("provider", "", "llm provider to use (claude, chatgpt, gemini, deepseek, minimax); the first available one if empty")
+	model := flags.String("model", "", "model of the provider; the provider's default if empty")
+	systemPath := flags.String("system", "", "file containing the system prompt")
+	loadPath := flags.String("load", "", "transcript to continue from")
+	savePath := flags.String("save", "", "file to save the transcript to after every turn")
+	verbose := flags.Bool("verbose", false, "keep info logs of the llm clients")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *model != "" && *provider == "" {
+		return fmt.Errorf("-model requires -provider")
+	}
+
+	if !*verbose {
+		foundation.LoadGlobalLogger(foundation.NewSugarLogger("warn", "console"))
+	}
+
+	ctx := context.Background()
+
+	var client llm.LlmClient
+	var err error
+	if *provider == "" {
+		client, err = llm.NewLlmClient()
+	} else {
+		client, err = llm.NewLlmClientForProvider(ctx, *provider, *model)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create llm client: %v", err)
+	}
+
+	session := &chatSession{
+		client: client,
+		transcript: chatTranscript{
+			Provider: *provider,
+			Model:    *model,
+		},
+		out: os.Stdout,
+	}
+	// loading a transcript may switch the client
+	defer func() { session.client.Close() }()
+
+	if *loadPath != "" {
+		// a provider given on the command line is kept over the one of the transcript
+		if err := session.load(ctx, *loadPath, *provider != ""); err != nil {
+			return err
+		}
+	}
+	if *systemPath != "" {
+		if err := session.setSystemPrompt(*systemPath); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintln(session.out, "Type /help for commands, /exit to quit.")
+
+	scanner := bufio.NewScanner(os.Stdin)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for {
+		fmt.Fprint(session.out, "> ")
+		if !scanner.Scan() {
+			break
+		}
+
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "/") {
+			quit, err := session.runCommand(ctx, line)
+			if err != nil {
+				fmt.Fprintf(session.out, "error: %v\n", err)
+			}
+			if quit {
+				return nil
+			}
+			continue
+		}
+
+		if err := session.send(ctx, line); err != nil {
+			fmt.Fprintf(session.out, "error: %v\n", err)
+			continue
+		}
+
+		if *savePath != "" {
+			if err := session.save(*savePath); err != nil {
+				fmt.Fprintf(session.out, "error: %v\n", err)
+			}
+		}
+	}
+
+	return scanner.Err()
+}
+
+func (s *chatSession) runCommand(ctx context.Context, line string) (bool, error) {
+	command, arg, _ := strings.Cut(line, " ")
+	arg = strings.TrimSpace(arg)
+
+	switch command {
+	case "/exit", "/quit":
+		return true, nil
+
+	case "/help":
+		fmt.Fprintln(s.out, chatHelp)
+
+	case "/image":
+		if arg == "" {
+			return false, fmt.Errorf("usage: /image <path>")
+		}
+		img, err := llm.LoadImageFile(arg)
+		if err != nil {
+			return false, fmt.Errorf("failed to read image: %v", err)
+		}
+		// fit the image to the provider, the strictest limits when it is not known
+		img, err = img.Fit(llm.ImageLimitsForProvider(s.transcript.Provider))
+		if err != nil {
+			return false, fmt.Errorf("failed to prepare image: %v", err)
+		}
+		s.pendingImage = img
+		fmt.Fprintf(s.out, "attached %s to the next message\n", arg)
+
+	case "/system":
+		if arg == "" {
+			return false, fmt.Errorf("usage: /system <path>")
+		}
+		return false, s.setSystemPrompt(arg)
+
+	case "/save":
+		if arg == "" {
+			return false, fmt.Errorf("usage: /save <path>")
+		}
+		if err := s.save(arg); err != nil {
+			return false, err
+		}
+		fmt.Fprintf(s.out, "saved transcript to %s\n", arg)
+
+	case "/load":
+		if arg == "" {
+			return false, fmt.Errorf("usage: /load <path>")
+		}
+		if err := s.load(ctx, arg, false); err != nil {
+			return false, err
+		}
+		fmt.Fprintf(s.out, "loaded %d messages from %s\n", len(s.transcript.Messages), arg)
+
+	case "/reset":
+		messages := s.transcript.Messages
+		s.transcript.Messages = nil
+		if len(messages) > 0 && messages[0].Role == llm.RoleSystem {
+			s.transcript.Messages = messages[:1]
+		}
+		s.pendingImage = nil
+
+	case "/usage":
+		fmt.Fprintf(s.out, "session usage: prompt=%d completion=%d total=%d\n",
+			s.usage.PromptTokens, s.usage.CompletionTokens, s.usage.TotalTokens)
+
+	default:
+		return false, fmt.Errorf("unknown command %s, type /help for commands", command)
+	}
+
+	return false, nil
+}
+
+func (s *chatSession) send(ctx context.Context, content string) error {
+	message := llm.LlmMessage{Role: llm.RoleUser, Content: content}
+	if s.pendingImage != nil {
+		message = s.pendingImage.Message(llm.RoleUser, content)
+	}
+	messages := append(s.transcript.Messages, message)
+
+	ctx, cancel := context.WithTimeout(ctx, llm.DefaultTimeout)
+	defer cancel()
+
+	reply, err := llm.ReplyMessageDetail(ctx, s.client, messages)
+	if err != nil {
+		return err
+	}
+
+	s.pendingImage = nil
+	s.transcript.Messages = append(messages, llm.LlmMessage{
+		Role:    llm.RoleAssistant,
+		Content: reply.Content,
+	})
+	s.usage.PromptTokens += reply.Usage.PromptTokens
+	s.usage.CompletionTokens += reply.Usage.CompletionTokens
+	s.usage.TotalTokens += reply.Usage.TotalTokens
+
+	fmt.Fprintln(s.out, reply.Content)
+	fmt.Fprintf(s.out, "[usage] prompt=%d completion=%d total=%d (session total=%d)\n",
+		reply.Usage.PromptTokens, reply.Usage.CompletionTokens, reply.Usage.TotalTokens, s.usage.TotalTokens)
+	return nil
+}
+
+func (s *chatSession) setSystemPrompt(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read system prompt: %v", err)
+	}
+
+	system := llm.LlmMessage{
+		Role:    llm.RoleSystem,
+		Content: strings.TrimSpace(string(data)),
+	}
+	if len(s.transcript.Messages) > 0 && s.transcript.Messages[0].Role == llm.RoleSystem {
+		s.transcript.Messages[0] = system
+	} else {
+		s.transcript.Messages = append([]llm.LlmMessage{system}, s.transcript.Messages...)
+	}
+	return nil
+}
+
+func (s *chatSession) save(path string) error {
+	data, err := json.MarshalIndent(s.transcript, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal transcript: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write transcript: %v", err)
+	}
+	return nil
+}
+
+// load restores the messages of a transcript. A transcript saved with another provider or
+// model switches the client to them, or only warns with keepClient.
+func (s *chatSession) load(ctx context.Context, path string, keepClient bool) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read transcript: %v", err)
+	}
+
+	var transcript chatTranscript
+	if err := json.Unmarshal(data, &transcript); err != nil {
+		return fmt.Errorf("failed to unmarshal transcript: %v", err)
+	}
+
+	if transcript.Provider != "" &&
+		(transcript.Provider != s.transcript.Provider || transcript.Model != s.transcript.Model) {
+		if keepClient {
+			fmt.Fprintf(s.out, "warning: %s was saved with %s, continuing with %s\n",
+				path, describeChatModel(transcript), describeChatModel(s.transcript))
+		} else {
+			client, err := llm.NewLlmClientForProvider(ctx, transcript.Provider, transcript.Model)
+			if err != nil {
+				return fmt.Errorf("failed to create llm client for %s: %v", describeChatModel(transcript), err)
+			}
+			s.client.Close()
+			s.client = client
+			s.transcript.Provider, s.transcript.Model = transcript.Provider, transcript.Model
+			fmt.Fprintf(s.out, "switched to %s, the provider of %s\n", describeChatModel(transcript), path)
+		}
+	}
+
+	s.transcript.Messages = transcript.Messages
+	return nil
+}
+
+// describeChatModel names the provider and model of a transcript, the default ones if unset.
+func describeChatModel(transcript chatTranscript) string {
+	provider, model := transcript.Provider, transcript.Model
+	if provider == "" {
+		provider = "the first available provider"
+	}
+	if model == "" {
+		model = "default model"
+	}
+	return fmt.Sprintf("%s (%s)", provider, model)
+}
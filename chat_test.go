@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sieglu2/go_foundation/llm"
+	"github.com/sieglu2/go_foundation/llm/secret_key"
+)
+
+// closingClient records whether it was closed.
+type closingClient struct {
+	closed bool
+}
+
+func (c *closingClient) ReplyMessage(ctx context.Context, messages []llm.LlmMessage) (string, error) {
+	return "ok", nil
+}
+
+func (c *closingClient) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestChatLoad(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "chat.json")
+	transcript := `{"provider": "chatgpt", "model": "gpt-4o", "messages": [{"role": "user", "content": "hi"}]}`
+	if err := os.WriteFile(path, []byte(transcript), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	chatgpt, _ := secret_key.LookupProvider(llm.ProviderChatGpt)
+	llm.SetSecretProvider(secret_key.NewStaticProvider().Set(chatgpt.AccountName, chatgpt.ServiceName, "test-key"))
+	defer llm.SetSecretProvider(nil)
+
+	newSession := func() (*chatSession, *closingClient, *bytes.Buffer) {
+		client, out := &closingClient{}, &bytes.Buffer{}
+		return &chatSession{
+			client:     client,
+			transcript: chatTranscript{Provider: llm.ProviderClaude},
+			out:        out,
+		}, client, out
+	}
+
+	t.Run("Kept client warns", func(t *testing.T) {
+		session, client, out := newSession()
+		if err := session.load(ctx, path, true); err != nil {
+			t.Fatalf("load failed: %v", err)
+		}
+		if session.client != client || client.closed || session.transcript.Provider != llm.ProviderClaude {
+			t.Fatalf("expected the claude client kept, got %+v", session.transcript)
+		}
+		if !strings.Contains(out.String(), "warning") || len(session.transcript.Messages) != 1 {
+			t.Fatalf("expected a warning and the messages, got %q: %+v", out, session.transcript.Messages)
+		}
+	})
+
+	t.Run("Client switched to the transcript provider", func(t *testing.T) {
+		session, client, _ := newSession()
+		if err := session.load(ctx, path, false); err != nil {
+			t.Fatalf("load failed: %v", err)
+		}
+		defer session.client.Close()
+		if session.client == client || !client.closed {
+			t.Fatalf("expected the client replaced and the old one closed")
+		}
+		if session.transcript.Provider != llm.ProviderChatGpt || session.transcript.Model != "gpt-4o" {
+			t.Fatalf("expected the transcript provider and model, got %+v", session.transcript)
+		}
+	})
+}
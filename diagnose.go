@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sieglu2/go_foundation/foundation"
+	"github.com/sieglu2/go_foundation/llm"
+)
+
+// maxListedModels is how many models the table shows per provider, -json shows them all.
+const maxListedModels = 5
+
+func runDiagnose(args []string) error {
+	flags := flag.NewFlagSet("diagnose", flag.ContinueOnError)
+	providers := flags.String("providers", "", "comma separated providers to check; all of them if empty")
+	asJson := flags.Bool("json", false, "print the diagnoses as json instead of a table")
+	noValidate := flags.Bool("no-validate", false, "only look the keys up, without the validation call")
+	noModels := flags.Bool("no-models", false, "do not list the models")
+	timeout := flags.Duration("timeout", 30*time.Second, "timeout of the calls of every provider")
+	verbose := flags.Bool("verbose", false, "keep info logs of the llm clients")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	// only errors, which go to stderr, so stdout holds the table or the json alone
+	if !*verbose {
+		foundation.LoadGlobalLogger(foundation.NewSugarLogger("error", "console"))
+	}
+
+	config := llm.DiagnosticsConfig{
+		SkipValidation: *noValidate,
+		SkipModels:     *noModels,
+		Timeout:        *timeout,
+	}
+	if *providers != "" {
+		for _, name := range strings.Split(*providers, ",") {
+			provider, err := lookupProvider(strings.TrimSpace(name))
+			if err != nil {
+				return err
+			}
+			config.Providers = append(config.Providers, provider.Name)
+		}
+	}
+
+	diagnoses := llm.DiagnoseProviders(context.Background(), config)
+
+	if *asJson {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(diagnoses); err != nil {
+			return fmt.Errorf("failed to encode diagnoses: %v", err)
+		}
+	} else {
+		printDiagnoses(diagnoses)
+	}
+
+	var failed []string
+	usable := 0
+	for _, diagnosis := range diagnoses {
+		if diagnosis.Ok() {
+			usable++
+		} else if diagnosis.KeyFound || len(config.Providers) > 0 {
+			failed = append(failed, diagnosis.Provider)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("providers not usable: %s", strings.Join(failed, ","))
+	}
+	if usable == 0 {
+		return fmt.Errorf("no provider has an api key, store one with: keys store <provider>")
+	}
+	return nil
+}
+
+func printDiagnoses(diagnoses []llm.ProviderDiagnosis) {
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "PROVIDER\tKEY\tVALID\tLATENCY\tMODELS\tERROR")
+	for _, diagnosis := range diagnoses {
+		key := "not found"
+		if diagnosis.KeyFound {
+			key = diagnosis.MaskedKey
+			if diagnosis.Keys > 1 {
+				key = fmt.Sprintf("%s (+%d)", key, diagnosis.Keys-1)
+			}
+		}
+
+		valid, latency := "-", "-"
+		if diagnosis.Validated {
+			valid = "✗"
+			if diagnosis.Valid {
+				valid = strings.TrimSpace("✓ " + diagnosis.Model)
+			}
+			latency = fmt.Sprintf("%dms", diagnosis.LatencyMs)
+		}
+
+		// the error of the models listing is the one of the validation when it listed them
+		models := "-"
+		if diagnosis.ModelsError != "" {
+			models = "✗"
+		} else if len(diagnosis.Models) > 0 {
+			models = strings.Join(diagnosis.Models[:min(len(diagnosis.Models), maxListedModels)], ",")
+			if len(diagnosis.Models) > maxListedModels {
+				models += fmt.Sprintf(" (+%d)", len(diagnosis.Models)-maxListedModels)
+			}
+		}
+
+		errorText := diagnosis.Error
+		if diagnosis.StatusCode != 0 {
+			errorText = fmt.Sprintf("[%d] %s", diagnosis.StatusCode, errorText)
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%s\n", diagnosis.Provider, key, valid, latency, models, oneLine(errorText))
+	}
+	writer.Flush()
+}
+
+// oneLine keeps a table row on one line, errors of the providers may span several.
+func oneLine(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
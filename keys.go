@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sieglu2/go_foundation/llm/secret_key"
+)
+
+const keysUsage = `Usage: keys <action> [args]
+
+Actions:
+  store <provider> [key]   store the api key of a provider, read from stdin if key is omitted
+  test <provider>          check that the api key of a provider can be read
+  delete <provider>        delete the api key of a provider
+  delete -all              delete the api keys of all providers
+  list                     list the providers and whether their api key is found
+
+Several keys of a provider can be stored at once, separated by commas.`
+
+func runKeys(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing action\n%s", keysUsage)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	action, args := args[0], args[1:]
+	switch action {
+	case "store":
+		return runKeysStore(ctx, args)
+	case "test":
+		return runKeysTest(ctx, args)
+	case "delete":
+		return runKeysDelete(ctx, args)
+	case "list":
+		return runKeysList(ctx)
+	}
+
+	return fmt.Errorf("unknown action: %s\n%s", action, keysUsage)
+}
+
+func lookupProvider(name string) (secret_key.Provider, error) {
+	provider, ok := secret_key.LookupProvider(strings.ToLower(name))
+	if !ok {
+		names := make([]string, 0)
+		for _, p := range secret_key.Providers() {
+			names = append(names, p.Name)
+		}
+		return secret_key.Provider{}, fmt.Errorf("unknown provider %s, supported providers: %s",
+			name, strings.Join(names, ","))
+	}
+	return provider, nil
+}
+
+func runKeysStore(ctx context.Context, args []string) error {
+	if len(args) != 1 && len(args) != 2 {
+		return fmt.Errorf("usage: keys store <provider> [key]")
+	}
+
+	provider, err := lookupProvider(args[0])
+	if err != nil {
+		return err
+	}
+
+	var key string
+	if len(args) == 2 {
+		key = args[1]
+	} else if key, err = readSecret(fmt.Sprintf("Enter the api key for %s: ", provider.Name)); err != nil {
+		return err
+	}
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return fmt.Errorf("empty key")
+	}
+
+	if err := secret_key.StoreSecretKey(ctx, provider.AccountName, provider.ServiceName, key); err != nil {
+		return err
+	}
+
+	fmt.Printf("Successfully stored API key for %s\n", provider.Name)
+	return nil
+}
+
+func runKeysTest(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: keys test <provider>")
+	}
+
+	provider, err := lookupProvider(args[0])
+	if err != nil {
+		return err
+	}
+
+	key, err := secret_key.GetSecretKey(ctx, provider.AccountName, provider.ServiceName)
+	if err != nil || key == "" {
+		return fmt.Errorf("no API key found for %s: %v", provider.Name, err)
+	}
+
+	fmt.Printf("API key exists for %s: %s\n", provider.Name, secret_key.MaskKey(key))
+	return nil
+}
+
+func runKeysDelete(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("keys delete", flag.ContinueOnError)
+	all := flags.Bool("all", false, "delete the api keys of all providers")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *all {
+		for _, provider := range secret_key.Providers() {
+			if err := secret_key.DeleteSecretKey(ctx, provider.AccountName, provider.ServiceName); err != nil {
+				fmt.Printf("✗ Failed to delete API key for %s (key might not exist): %v\n", provider.Name, err)
+				continue
+			}
+			fmt.Printf("✓ Successfully deleted API key for %s\n", provider.Name)
+		}
+		return nil
+	}
+
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: keys delete <provider> | keys delete -all")
+	}
+
+	provider, err := lookupProvider(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	if err := secret_key.DeleteSecretKey(ctx, provider.AccountName, provider.ServiceName); err != nil {
+		return err
+	}
+
+	fmt.Printf("Successfully deleted API key for %s\n", provider.Name)
+	return nil
+}
+
+func runKeysList(ctx context.Context) error {
+	fmt.Printf("%-10s %-14s %-10s %s\n", "PROVIDER", "ACCOUNT", "SERVICE", "KEY")
+	for _, provider := range secret_key.Providers() {
+		status := "not found"
+		key, err := secret_key.GetSecretKey(ctx, provider.AccountName, provider.ServiceName)
+		if err == nil && key != "" {
+			status = secret_key.MaskKey(key)
+		}
+		fmt.Printf("%-10s %-14s %-10s %s\n", provider.Name, provider.AccountName, provider.ServiceName, status)
+	}
+	return nil
+}
@@ -0,0 +1,290 @@
+// Package agent runs a model in a loop with Go functions as tools: the model asks for a
+// tool call, the agent runs it and sends back the result, until the model gives its final
+// answer or a step, time or cost limit is reached.
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sieglu2/go_foundation/foundation"
+	"github.com/sieglu2/go_foundation/llm"
+	"github.com/sieglu2/go_foundation/llm/parse"
+)
+
+const DefaultMaxSteps = 10
+
+var (
+	ErrMaxSteps  = errors.New("agent reached its step limit")
+	ErrCostLimit = errors.New("agent reached its cost limit")
+)
+
+const protocolPrompt = `You solve the task of the user step by step, calling tools when they help.
+Every reply of yours must be exactly one json object and nothing else, either a tool call:
+{"tool": "<tool name>", "arguments": {<arguments of the tool>}}
+or your final answer once you have it:
+{"final": "<answer to the user>"}
+The result of every tool call is sent back to you in the next message.
+
+Tools:
+%s`
+
+type Config struct {
+	// SystemPrompt is added to the instructions of the tool protocol.
+	SystemPrompt string
+	// MaxSteps bounds the number of model replies, DefaultMaxSteps if 0.
+	MaxSteps int
+	// Timeout bounds the whole run, no bound if 0.
+	Timeout time.Duration
+	// MaxCost bounds the cost in USD of the model calls priced with Prices, no bound if 0.
+	MaxCost float64
+	// Prices is the price table for MaxCost, llm.DefaultPrices if nil.
+	Prices llm.PriceTable
+	// Model prices the replies of a client leaving their model empty. A reply which
+	// cannot be priced stops a run having a MaxCost.
+	Model string
+	// Approve is asked before every call of a dangerous tool. Without it dangerous tools
+	// are never run. A refusal is reported to the model, an error stops the run.
+	Approve func(ctx context.Context, call ToolCall) (bool, error)
+	// OnStep is called after every step, e.g. to stream the trace.
+	OnStep func(step Step)
+}
+
+type ToolCall struct {
+	Tool      string          `json:"tool"`
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+}
+
+type StepKind string
+
+const (
+	StepModel StepKind = "model"
+	StepTool  StepKind = "tool"
+)
+
+// Step is one entry of the trace of a run: a model reply or a tool call.
+type Step struct {
+	Index    int
+	Kind     StepKind
+	Reply    string
+	Call     *ToolCall
+	Result   string
+	Error    string
+	Usage    llm.LlmUsage
+	Duration time.Duration
+}
+
+type Result struct {
+	Answer string
+	Steps  []Step
+	Usage  llm.LlmUsage
+	Cost   float64
+}
+
+type Agent struct {
+	client    llm.LlmClient
+	config    Config
+	tools     map[string]*Tool
+	toolOrder []string
+}
+
+func NewAgent(client llm.LlmClient, config Config) *Agent {
+	if config.MaxSteps <= 0 {
+		config.MaxSteps = DefaultMaxSteps
+	}
+	if config.Prices == nil {
+		config.Prices = llm.DefaultPrices
+	}
+	return &Agent{
+		client: client,
+		config: config,
+		tools:  map[string]*Tool{},
+	}
+}
+
+func (a *Agent) Tools() []*Tool {
+	tools := make([]*Tool, 0, len(a.toolOrder))
+	for _, name := range a.toolOrder {
+		tools = append(tools, a.tools[name])
+	}
+	return tools
+}
+
+func (a *Agent) systemPrompt() string {
+	var tools []string
+	for _, tool := range a.Tools() {
+		tools = append(tools, fmt.Sprintf("- %s: %s Arguments: %s", tool.Name, tool.Description, tool.Parameters))
+	}
+	if len(tools) == 0 {
+		tools = append(tools, "none, answer directly")
+	}
+
+	prompt := fmt.Sprintf(protocolPrompt, strings.Join(tools, "\n"))
+	if a.config.SystemPrompt != "" {
+		prompt = a.config.SystemPrompt + "\n\n" + prompt
+	}
+	return prompt
+}
+
+// action is a model reply of the tool protocol.
+type action struct {
+	Tool      string          `json:"tool"`
+	Arguments json.RawMessage `json:"arguments"`
+	Final     *string         `json:"final"`
+}
+
+// Run solves task. When a limit stops the run, the partial Result with its trace is
+// returned along with ErrMaxSteps, ErrCostLimit or the context error.
+func (a *Agent) Run(ctx context.Context, task string) (*Result, error) {
+	logger := foundation.Logger()
+
+	if a.config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, a.config.Timeout)
+		defer cancel()
+	}
+
+	result := &Result{}
+	messages := []llm.LlmMessage{
+		{Role: llm.RoleSystem, Content: a.systemPrompt()},
+		{Role: llm.RoleUser, Content: task},
+	}
+
+	for modelSteps := 0; modelSteps < a.config.MaxSteps; modelSteps++ {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		start := time.Now()
+		reply, err := llm.ReplyMessageDetail(ctx, a.client, messages)
+		if err != nil {
+			logger.Errorf("failed to ReplyMessageDetail at step %d: %v", len(result.Steps), err)
+			return result, fmt.Errorf("failed to ReplyMessageDetail at step %d: %w", len(result.Steps), err)
+		}
+
+		result.Usage.PromptTokens += reply.Usage.PromptTokens
+		result.Usage.CompletionTokens += reply.Usage.CompletionTokens
+		result.Usage.TotalTokens += reply.Usage.TotalTokens
+		model := reply.Model
+		if model == "" {
+			model = a.config.Model
+		}
+		cost, priced := a.config.Prices.Cost(model, reply.Usage)
+		result.Cost += cost
+
+		step := Step{Kind: StepModel, Reply: reply.Content, Usage: reply.Usage, Duration: time.Since(start)}
+		messages = append(messages, llm.LlmMessage{Role: llm.RoleAssistant, Content: reply.Content})
+
+		var next action
+		parseErr := parse.ExtractJSON(reply.Content, &next)
+		if parseErr == nil && next.Final != nil {
+			a.record(result, step)
+			result.Answer = *next.Final
+			return result, nil
+		}
+
+		valid := parseErr == nil && next.Tool != ""
+		call := ToolCall{Tool: next.Tool, Arguments: next.Arguments}
+		if valid {
+			step.Call = &call
+		} else {
+			step.Error = "reply is not a tool call nor a final answer"
+		}
+		a.record(result, step)
+
+		if a.config.MaxCost > 0 && !priced {
+			logger.Errorf("cannot enforce the cost limit, model %q has no price", model)
+			return result, fmt.Errorf("cannot enforce the cost limit, model %q has no price", model)
+		}
+		if a.config.MaxCost > 0 && result.Cost >= a.config.MaxCost {
+			return result, fmt.Errorf("%w: $%.4f spent of $%.4f", ErrCostLimit, result.Cost, a.config.MaxCost)
+		}
+
+		if !valid {
+			messages = append(messages, llm.LlmMessage{
+				Role:    llm.RoleUser,
+				Content: `Your reply must be one json object, {"tool": ..., "arguments": ...} or {"final": ...}.`,
+			})
+			continue
+		}
+
+		toolStep, err := a.callTool(ctx, call)
+		a.record(result, toolStep)
+		if err != nil {
+			return result, err
+		}
+
+		content := fmt.Sprintf("Result of %s:\n%s", call.Tool, toolStep.Result)
+		if toolStep.Error != "" {
+			content = fmt.Sprintf("Error of %s: %s", call.Tool, toolStep.Error)
+		}
+		messages = append(messages, llm.LlmMessage{Role: llm.RoleUser, Content: content})
+	}
+
+	return result, fmt.Errorf("%w of %d", ErrMaxSteps, a.config.MaxSteps)
+}
+
+func (a *Agent) record(result *Result, step Step) {
+	step.Index = len(result.Steps)
+	result.Steps = append(result.Steps, step)
+	if a.config.OnStep != nil {
+		a.config.OnStep(step)
+	}
+}
+
+// callTool runs a tool. Failures the model can react to, like an unknown tool or a tool
+// error, are only reported in the step. The error is set when the run must stop.
+func (a *Agent) callTool(ctx context.Context, call ToolCall) (Step, error) {
+	logger := foundation.Logger()
+
+	start := time.Now()
+	step := Step{Kind: StepTool, Call: &call}
+
+	tool, ok := a.tools[call.Tool]
+	if !ok {
+		step.Error = fmt.Sprintf("unknown tool %s, the tools are: %s", call.Tool, strings.Join(a.toolOrder, ", "))
+		return step, nil
+	}
+
+	if tool.Dangerous {
+		approved := false
+		if a.config.Approve != nil {
+			var err error
+			if approved, err = a.config.Approve(ctx, call); err != nil {
+				step.Error = fmt.Sprintf("approval failed: %v", err)
+				logger.Errorf("failed to approve tool %s: %v", call.Tool, err)
+				return step, fmt.Errorf("failed to approve tool %s: %v", call.Tool, err)
+			}
+		}
+		if !approved {
+			step.Error = "the call was not approved"
+			return step, nil
+		}
+	}
+
+	output, err := callRecovering(ctx, tool, call.Arguments)
+	step.Duration = time.Since(start)
+	if err != nil {
+		logger.Warnf("tool %s failed: %v", call.Tool, err)
+		step.Error = err.Error()
+		return step, nil
+	}
+
+	step.Result = output
+	return step, nil
+}
+
+// callRecovering runs tool, turning a panic into its error so a bad tool does not crash
+// the whole run.
+func callRecovering(ctx context.Context, tool *Tool, arguments json.RawMessage) (output string, err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("tool panicked: %v", recovered)
+		}
+	}()
+	return tool.call(ctx, arguments)
+}
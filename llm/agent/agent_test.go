@@ -0,0 +1,222 @@
+package agent_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/sieglu2/go_foundation/llm"
+	"github.com/sieglu2/go_foundation/llm/agent"
+)
+
+// fakeScriptClient replies with the scripted replies in order, as model, and keeps the
+// last messages.
+type fakeScriptClient struct {
+	replies  []string
+	model    string
+	calls    int
+	received []llm.LlmMessage
+}
+
+func (f *fakeScriptClient) ReplyMessage(ctx context.Context, messages []llm.LlmMessage) (string, error) {
+	reply, err := f.ReplyMessageDetail(ctx, messages)
+	if err != nil {
+		return "", err
+	}
+	return reply.Content, nil
+}
+
+func (f *fakeScriptClient) ReplyMessageDetail(ctx context.Context, messages []llm.LlmMessage) (*llm.LlmReply, error) {
+	f.received = messages
+	if f.calls >= len(f.replies) {
+		return nil, fmt.Errorf("script exhausted")
+	}
+	f.calls++
+	return &llm.LlmReply{
+		Content: f.replies[f.calls-1],
+		Model:   f.model,
+		Usage:   llm.LlmUsage{PromptTokens: 1000000, TotalTokens: 1000000},
+	}, nil
+}
+
+func (f *fakeScriptClient) Close() error {
+	return nil
+}
+
+type addArgs struct {
+	A int `json:"a" description:"first term"`
+	B int `json:"b,omitempty"`
+}
+
+func add(ctx context.Context, args addArgs) (int, error) {
+	return args.A + args.B, nil
+}
+
+func TestAgent(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Runs tools until the final answer", func(t *testing.T) {
+		client := &fakeScriptClient{replies: []string{
+			`{"tool": "add", "arguments": {"a": 2, "b": 3}}`,
+			"Sure, here it is:\n```json\n{\"final\": \"5\"}\n```",
+		}}
+		a := agent.NewAgent(client, agent.Config{})
+		if err := agent.RegisterTool(a, "add", "Adds two integers.", add); err != nil {
+			t.Fatalf("RegisterTool failed: %v", err)
+		}
+
+		result, err := a.Run(ctx, "what is 2+3?")
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		if result.Answer != "5" || len(result.Steps) != 3 {
+			t.Fatalf("unexpected result: %+v", result)
+		}
+		if result.Steps[1].Kind != agent.StepTool || result.Steps[1].Result != "5" {
+			t.Fatalf("unexpected tool step: %+v", result.Steps[1])
+		}
+		if !strings.Contains(client.received[0].Content, "a (integer): first term; b (integer, optional)") {
+			t.Fatalf("the system prompt should describe the tool arguments, got: %s", client.received[0].Content)
+		}
+		if client.received[len(client.received)-1].Content != "Result of add:\n5" {
+			t.Fatalf("the tool result should be sent back, got: %+v", client.received[len(client.received)-1])
+		}
+	})
+
+	t.Run("Tool errors and invalid replies go back to the model", func(t *testing.T) {
+		client := &fakeScriptClient{replies: []string{
+			"I think the answer is 4",
+			`{"tool": "missing"}`,
+			`{"final": "gave up"}`,
+		}}
+		a := agent.NewAgent(client, agent.Config{})
+
+		result, err := a.Run(ctx, "task")
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		if result.Steps[0].Error == "" || !strings.Contains(result.Steps[2].Error, "unknown tool missing") {
+			t.Fatalf("expected the invalid reply and unknown tool in the trace: %+v", result.Steps)
+		}
+	})
+
+	t.Run("Dangerous tools need approval", func(t *testing.T) {
+		client := &fakeScriptClient{replies: []string{
+			`{"tool": "delete", "arguments": {"path": "/"}}`,
+			`{"tool": "delete", "arguments": {"path": "/tmp/x"}}`,
+			`{"final": "done"}`,
+		}}
+
+		var deleted []string
+		a := agent.NewAgent(client, agent.Config{
+			Approve: func(ctx context.Context, call agent.ToolCall) (bool, error) {
+				return !strings.Contains(string(call.Arguments), `"/"`), nil
+			},
+		})
+		agent.RegisterTool(a, "delete", "Deletes a file.", func(ctx context.Context, args struct {
+			Path string `json:"path"`
+		}) (string, error) {
+			deleted = append(deleted, args.Path)
+			return "deleted", nil
+		}, agent.WithDangerous())
+
+		if _, err := a.Run(ctx, "clean up"); err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		if strings.Join(deleted, ",") != "/tmp/x" {
+			t.Fatalf("only the approved call should run, got %v", deleted)
+		}
+	})
+
+	t.Run("Step limit", func(t *testing.T) {
+		client := &fakeScriptClient{replies: []string{
+			`{"tool": "add", "arguments": {"a": 1}}`,
+			`{"tool": "add", "arguments": {"a": 1}}`,
+		}}
+		var traced int
+		a := agent.NewAgent(client, agent.Config{MaxSteps: 2, OnStep: func(step agent.Step) { traced++ }})
+		agent.RegisterTool(a, "add", "Adds two integers.", add)
+
+		result, err := a.Run(ctx, "loop")
+		if !errors.Is(err, agent.ErrMaxSteps) {
+			t.Fatalf("expected ErrMaxSteps, got: %v", err)
+		}
+		if len(result.Steps) != 4 || traced != 4 {
+			t.Fatalf("expected 4 steps traced, got %d and %d", len(result.Steps), traced)
+		}
+	})
+
+	t.Run("Cost limit", func(t *testing.T) {
+		client := &fakeScriptClient{model: "test-model", replies: []string{
+			`{"tool": "add", "arguments": {"a": 1}}`,
+			`{"tool": "add", "arguments": {"a": 1}}`,
+		}}
+		a := agent.NewAgent(client, agent.Config{
+			MaxCost: 1.5,
+			Prices:  llm.PriceTable{"test-model": {Prompt: 1}},
+		})
+		agent.RegisterTool(a, "add", "Adds two integers.", add)
+
+		result, err := a.Run(ctx, "loop")
+		if !errors.Is(err, agent.ErrCostLimit) {
+			t.Fatalf("expected ErrCostLimit, got: %v", err)
+		}
+		if result.Cost != 2 {
+			t.Fatalf("expected $2 spent, got %v", result.Cost)
+		}
+	})
+
+	t.Run("Cost limit with the configured model", func(t *testing.T) {
+		client := &fakeScriptClient{replies: []string{
+			`{"tool": "add", "arguments": {"a": 1}}`,
+			`{"tool": "add", "arguments": {"a": 1}}`,
+		}}
+		a := agent.NewAgent(client, agent.Config{
+			MaxCost: 1.5,
+			Prices:  llm.PriceTable{"test-model": {Prompt: 1}},
+			Model:   "test-model",
+		})
+		agent.RegisterTool(a, "add", "Adds two integers.", add)
+
+		if _, err := a.Run(ctx, "loop"); !errors.Is(err, agent.ErrCostLimit) {
+			t.Fatalf("expected ErrCostLimit, got: %v", err)
+		}
+
+		client = &fakeScriptClient{replies: []string{`{"tool": "add", "arguments": {"a": 1}}`}}
+		a = agent.NewAgent(client, agent.Config{MaxCost: 1.5, Prices: llm.PriceTable{"test-model": {Prompt: 1}}})
+		if _, err := a.Run(ctx, "loop"); err == nil || !strings.Contains(err.Error(), "has no price") {
+			t.Fatalf("expected an unpriced model error, got: %v", err)
+		}
+	})
+
+	t.Run("Tool panic reported to the model", func(t *testing.T) {
+		client := &fakeScriptClient{replies: []string{
+			`{"tool": "crash"}`,
+			`{"final": "recovered"}`,
+		}}
+		a := agent.NewAgent(client, agent.Config{})
+		agent.RegisterTool(a, "crash", "Crashes.", func(ctx context.Context, args struct{}) (string, error) {
+			var values map[string]int
+			values["boom"] = 1
+			return "", nil
+		})
+
+		result, err := a.Run(ctx, "crash")
+		if err != nil || result.Answer != "recovered" {
+			t.Fatalf("expected the run to go on, got %+v: %v", result, err)
+		}
+		if !strings.Contains(result.Steps[1].Error, "tool panicked") || !strings.Contains(client.received[len(client.received)-1].Content, "Error of crash") {
+			t.Fatalf("expected the panic reported as a tool error: %+v", result.Steps[1])
+		}
+	})
+
+	t.Run("Duplicate tool", func(t *testing.T) {
+		a := agent.NewAgent(&fakeScriptClient{}, agent.Config{})
+		agent.RegisterTool(a, "add", "Adds two integers.", add)
+		if err := agent.RegisterTool(a, "add", "Again.", add); err == nil {
+			t.Fatalf("registering a tool twice should fail")
+		}
+	})
+}
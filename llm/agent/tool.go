@@ -0,0 +1,141 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Tool is a Go function the model may call, registered with RegisterTool.
+type Tool struct {
+	Name        string
+	Description string
+	// Parameters describes the json arguments of the tool to the model.
+	Parameters string
+	// Dangerous tools only run once approved, see Config.Approve.
+	Dangerous bool
+
+	call func(ctx context.Context, arguments json.RawMessage) (string, error)
+}
+
+type ToolOption func(*Tool)
+
+// WithDangerous marks the tool as needing approval before every call.
+func WithDangerous() ToolOption {
+	return func(t *Tool) {
+		t.Dangerous = true
+	}
+}
+
+// RegisterTool registers fn as a tool of the agent. The model sends the arguments as a json
+// object decoded into Args, whose fields are described to it from their json names, types
+// and `description` tags. The result is sent back as is if it is a string, as json otherwise.
+func RegisterTool[Args any, Result any](
+	agent *Agent, name string, description string,
+	fn func(ctx context.Context, args Args) (Result, error),
+	options ...ToolOption,
+) error {
+	if name == "" {
+		return fmt.Errorf("empty tool name")
+	}
+	if _, ok := agent.tools[name]; ok {
+		return fmt.Errorf("tool %s is already registered", name)
+	}
+
+	tool := &Tool{
+		Name:        name,
+		Description: description,
+		Parameters:  describeParameters(reflect.TypeOf((*Args)(nil)).Elem()),
+		call: func(ctx context.Context, arguments json.RawMessage) (string, error) {
+			var args Args
+			if len(arguments) > 0 && string(arguments) != "null" {
+				if err := json.Unmarshal(arguments, &args); err != nil {
+					return "", fmt.Errorf("invalid arguments: %v", err)
+				}
+			}
+
+			result, err := fn(ctx, args)
+			if err != nil {
+				return "", err
+			}
+			if text, ok := any(result).(string); ok {
+				return text, nil
+			}
+
+			encoded, err := json.Marshal(result)
+			if err != nil {
+				return "", fmt.Errorf("failed to Marshal result: %v", err)
+			}
+			return string(encoded), nil
+		},
+	}
+	for _, option := range options {
+		option(tool)
+	}
+
+	agent.tools[name] = tool
+	agent.toolOrder = append(agent.toolOrder, name)
+	return nil
+}
+
+// describeParameters lists the json fields of a struct as `name (type): description`.
+func describeParameters(t reflect.Type) string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return jsonTypeName(t)
+	}
+
+	var fields []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		description := fmt.Sprintf("%s (%s", name, jsonTypeName(field.Type))
+		if strings.Contains(options, "omitempty") {
+			description += ", optional"
+		}
+		description += ")"
+		if text := field.Tag.Get("description"); text != "" {
+			description += ": " + text
+		}
+		fields = append(fields, description)
+	}
+
+	if len(fields) == 0 {
+		return "no arguments"
+	}
+	return strings.Join(fields, "; ")
+}
+
+func jsonTypeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return jsonTypeName(t.Elem())
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		return "array of " + jsonTypeName(t.Elem())
+	}
+	return "object"
+}
@@ -0,0 +1,80 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+)
+
+// BatchRequest is one conversation of a batch. Providers restrict the ids to 1 to 64
+// letters, digits, underscores and dashes.
+type BatchRequest struct {
+	ID       string       `json:"id"`
+	Messages []LlmMessage `json:"messages"`
+}
+
+// BatchResult is the reply to the request of the same id, or why it failed.
+type BatchResult struct {
+	ID    string    `json:"id"`
+	Reply *LlmReply `json:"reply,omitempty"`
+	Error string    `json:"error,omitempty"`
+}
+
+type BatchState string
+
+const (
+	// BatchInProgress is a batch still validating, running or finalizing.
+	BatchInProgress BatchState = "in_progress"
+	// BatchEnded is a batch whose results can be downloaded, including the batches
+	// expired or canceled before all their requests ran.
+	BatchEnded BatchState = "ended"
+	// BatchFailed is a batch rejected as a whole, e.g. for an invalid input.
+	BatchFailed BatchState = "failed"
+)
+
+type BatchStatus struct {
+	State BatchState `json:"state"`
+	// ProviderStatus is the status as the provider reports it.
+	ProviderStatus string `json:"provider_status"`
+	Total          int    `json:"total"`
+	Succeeded      int    `json:"succeeded"`
+	Failed         int    `json:"failed"`
+	// Error is why a BatchFailed batch failed.
+	Error string `json:"error,omitempty"`
+}
+
+// BatchClient is implemented by the clients whose provider runs batches of requests
+// offline, usually within 24 hours and at half the price.
+type BatchClient interface {
+	SubmitBatch(ctx context.Context, requests []BatchRequest) (string, error)
+	GetBatchStatus(ctx context.Context, batchID string) (*BatchStatus, error)
+	// GetBatchResults downloads the results of an ended batch, in no particular order.
+	GetBatchResults(ctx context.Context, batchID string) ([]BatchResult, error)
+}
+
+var (
+	_ BatchClient = (*ChatGptClient)(nil)
+	_ BatchClient = (*ClaudeClient)(nil)
+)
+
+var batchIDPattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+func validateBatchRequests(requests []BatchRequest) error {
+	if len(requests) == 0 {
+		return fmt.Errorf("empty batch")
+	}
+	seen := map[string]bool{}
+	for _, request := range requests {
+		if !batchIDPattern.MatchString(request.ID) {
+			return fmt.Errorf("invalid batch request id %q", request.ID)
+		}
+		if seen[request.ID] {
+			return fmt.Errorf("duplicate batch request id %q", request.ID)
+		}
+		seen[request.ID] = true
+		if len(request.Messages) == 0 {
+			return fmt.Errorf("batch request %s has no messages", request.ID)
+		}
+	}
+	return nil
+}
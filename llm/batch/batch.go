@@ -0,0 +1,244 @@
+// Package batch runs bulk prompts through the batch apis of the providers, keeping the
+// state of the job on disk so an interrupted run resumes the same batch instead of paying
+// for it twice.
+package batch
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/sieglu2/go_foundation/foundation"
+	"github.com/sieglu2/go_foundation/llm"
+)
+
+const DefaultPollInterval = time.Minute
+
+var ErrBatchFailed = errors.New("batch failed")
+
+type Config struct {
+	// StatePath is the file holding the state of the job, nothing is resumed if empty.
+	StatePath string
+	// PollInterval is how often the status is checked, DefaultPollInterval if 0.
+	PollInterval time.Duration
+	// OnStatus is called with every status polled.
+	OnStatus func(status llm.BatchStatus)
+}
+
+// Job is the state of a submitted batch saved to Config.StatePath.
+type Job struct {
+	BatchID     string    `json:"batch_id"`
+	SubmittedAt time.Time `json:"submitted_at"`
+	// IDs are the ids of the requests, in order. The provider knows them as their index,
+	// as the providers restrict the characters of the ids.
+	IDs []string `json:"ids"`
+	// RequestsHash is the hash of the requests submitted, so requests changed under the
+	// same ids are not answered by the saved batch.
+	RequestsHash string           `json:"requests_hash"`
+	Status       *llm.BatchStatus `json:"status,omitempty"`
+}
+
+// LoadJob reads the state saved by Run, nil if there is none.
+func LoadJob(path string) (*Job, error) {
+	logger := foundation.Logger()
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		logger.Errorf("failed to ReadFile %s: %v", path, err)
+		return nil, fmt.Errorf("failed to ReadFile %s: %v", path, err)
+	}
+
+	var job Job
+	if err := json.Unmarshal(data, &job); err != nil {
+		logger.Errorf("failed to Unmarshal job %s: %v", path, err)
+		return nil, fmt.Errorf("failed to Unmarshal job %s: %v", path, err)
+	}
+	return &job, nil
+}
+
+func (j *Job) save(path string) error {
+	logger := foundation.Logger()
+
+	if path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		logger.Errorf("failed to Marshal job: %v", err)
+		return fmt.Errorf("failed to Marshal job: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		logger.Errorf("failed to MkdirAll for %s: %v", path, err)
+		return fmt.Errorf("failed to MkdirAll for %s: %v", path, err)
+	}
+
+	// write to a temporary file first so a crash never loses the batch id
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		logger.Errorf("failed to WriteFile %s: %v", tmpPath, err)
+		return fmt.Errorf("failed to WriteFile %s: %v", tmpPath, err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		logger.Errorf("failed to Rename %s: %v", tmpPath, err)
+		return fmt.Errorf("failed to Rename %s: %v", tmpPath, err)
+	}
+	return nil
+}
+
+// Run submits the requests as one batch, or resumes the batch saved in Config.StatePath,
+// waits for it to end and returns the results in the order of the requests. The requests
+// without a result from the provider get one with an error.
+func Run(ctx context.Context, client llm.BatchClient, requests []llm.BatchRequest, config Config) ([]llm.BatchResult, error) {
+	logger := foundation.Logger()
+
+	if config.PollInterval <= 0 {
+		config.PollInterval = DefaultPollInterval
+	}
+
+	job, err := resumeJob(requests, config.StatePath)
+	if err != nil {
+		return nil, err
+	}
+	if job == nil {
+		job, err = submit(ctx, client, requests, config.StatePath)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		logger.Infof("resuming batch %s submitted at %v", job.BatchID, job.SubmittedAt)
+	}
+
+	status, err := wait(ctx, client, job, config)
+	if err != nil {
+		return nil, err
+	}
+	if status.State == llm.BatchFailed {
+		logger.Errorf("batch %s failed: %s", job.BatchID, status.Error)
+		return nil, fmt.Errorf("%w: %s: %s", ErrBatchFailed, job.BatchID, status.Error)
+	}
+
+	results, err := client.GetBatchResults(ctx, job.BatchID)
+	if err != nil {
+		return nil, err
+	}
+	return mapResults(job, results), nil
+}
+
+// resumeJob returns the saved job if it was submitted for the same requests.
+func resumeJob(requests []llm.BatchRequest, path string) (*Job, error) {
+	if path == "" {
+		return nil, nil
+	}
+	job, err := LoadJob(path)
+	if err != nil || job == nil || job.BatchID == "" {
+		return nil, err
+	}
+
+	if len(job.IDs) != len(requests) {
+		return nil, fmt.Errorf("state %s is for %d requests, not %d", path, len(job.IDs), len(requests))
+	}
+	for i, request := range requests {
+		if job.IDs[i] != request.ID {
+			return nil, fmt.Errorf("state %s is for other requests, request %d is %s instead of %s", path, i, request.ID, job.IDs[i])
+		}
+	}
+	hash, err := requestsHash(requests)
+	if err != nil {
+		return nil, err
+	}
+	if job.RequestsHash != hash {
+		return nil, fmt.Errorf("state %s is for other requests, their contents changed", path)
+	}
+	return job, nil
+}
+
+// requestsHash hashes the ids and messages of the requests.
+func requestsHash(requests []llm.BatchRequest) (string, error) {
+	logger := foundation.Logger()
+
+	data, err := json.Marshal(requests)
+	if err != nil {
+		logger.Errorf("failed to Marshal requests: %v", err)
+		return "", fmt.Errorf("failed to Marshal requests: %v", err)
+	}
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:]), nil
+}
+
+func submit(ctx context.Context, client llm.BatchClient, requests []llm.BatchRequest, path string) (*Job, error) {
+	hash, err := requestsHash(requests)
+	if err != nil {
+		return nil, err
+	}
+
+	job := &Job{SubmittedAt: time.Now(), RequestsHash: hash}
+	submitted := make([]llm.BatchRequest, len(requests))
+	for i, request := range requests {
+		job.IDs = append(job.IDs, request.ID)
+		submitted[i] = llm.BatchRequest{ID: strconv.Itoa(i), Messages: request.Messages}
+	}
+
+	batchID, err := client.SubmitBatch(ctx, submitted)
+	if err != nil {
+		return nil, err
+	}
+	job.BatchID = batchID
+	if err := job.save(path); err != nil {
+		return nil, fmt.Errorf("batch %s submitted but its state not saved: %v", batchID, err)
+	}
+	return job, nil
+}
+
+func wait(ctx context.Context, client llm.BatchClient, job *Job, config Config) (*llm.BatchStatus, error) {
+	logger := foundation.Logger()
+
+	for {
+		status, err := client.GetBatchStatus(ctx, job.BatchID)
+		if err != nil {
+			return nil, err
+		}
+		job.Status = status
+		if err := job.save(config.StatePath); err != nil {
+			logger.Warnf("failed to save the status of batch %s: %v", job.BatchID, err)
+		}
+		if config.OnStatus != nil {
+			config.OnStatus(*status)
+		}
+		if status.State != llm.BatchInProgress {
+			return status, nil
+		}
+
+		logger.Infof("batch %s %s, %d of %d done", job.BatchID, status.ProviderStatus, status.Succeeded+status.Failed, status.Total)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(config.PollInterval):
+		}
+	}
+}
+
+func mapResults(job *Job, results []llm.BatchResult) []llm.BatchResult {
+	mapped := make([]llm.BatchResult, len(job.IDs))
+	for i, id := range job.IDs {
+		mapped[i] = llm.BatchResult{ID: id, Error: "no result in the batch"}
+	}
+	for _, result := range results {
+		index, err := strconv.Atoi(result.ID)
+		if err != nil || index < 0 || index >= len(mapped) {
+			continue
+		}
+		result.ID = job.IDs[index]
+		mapped[index] = result
+	}
+	return mapped
+}
@@ -0,0 +1,125 @@
+package batch_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sieglu2/go_foundation/llm"
+	"github.com/sieglu2/go_foundation/llm/batch"
+)
+
+// fakeBatchClient ends its batch after pending polls and answers every request but the
+// ones listed in missing.
+type fakeBatchClient struct {
+	submits   int
+	pending   int
+	failed    bool
+	missing   map[string]bool
+	submitted []llm.BatchRequest
+}
+
+func (f *fakeBatchClient) SubmitBatch(ctx context.Context, requests []llm.BatchRequest) (string, error) {
+	f.submits++
+	f.submitted = requests
+	return "batch-1", nil
+}
+
+func (f *fakeBatchClient) GetBatchStatus(ctx context.Context, batchID string) (*llm.BatchStatus, error) {
+	if f.pending > 0 {
+		f.pending--
+		return &llm.BatchStatus{State: llm.BatchInProgress, ProviderStatus: "in_progress"}, nil
+	}
+	if f.failed {
+		return &llm.BatchStatus{State: llm.BatchFailed, Error: "invalid input"}, nil
+	}
+	return &llm.BatchStatus{State: llm.BatchEnded, ProviderStatus: "completed"}, nil
+}
+
+func (f *fakeBatchClient) GetBatchResults(ctx context.Context, batchID string) ([]llm.BatchResult, error) {
+	var results []llm.BatchResult
+	for i := len(f.submitted) - 1; i >= 0; i-- {
+		request := f.submitted[i]
+		if f.missing[request.ID] {
+			continue
+		}
+		results = append(results, llm.BatchResult{ID: request.ID, Reply: &llm.LlmReply{Content: request.Messages[0].Content}})
+	}
+	return results, nil
+}
+
+func requests() []llm.BatchRequest {
+	return []llm.BatchRequest{
+		{ID: "doc/1.txt", Messages: []llm.LlmMessage{{Role: llm.RoleUser, Content: "first"}}},
+		{ID: "doc/2.txt", Messages: []llm.LlmMessage{{Role: llm.RoleUser, Content: "second"}}},
+	}
+}
+
+func TestRun(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Results mapped back to the ids", func(t *testing.T) {
+		client := &fakeBatchClient{pending: 2, missing: map[string]bool{"1": true}}
+		var polls int
+		results, err := batch.Run(ctx, client, requests(), batch.Config{
+			PollInterval: time.Millisecond,
+			OnStatus:     func(status llm.BatchStatus) { polls++ },
+		})
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		if polls != 3 {
+			t.Fatalf("expected 3 polls, got %d", polls)
+		}
+		if results[0].ID != "doc/1.txt" || results[0].Reply.Content != "first" {
+			t.Fatalf("unexpected first result: %+v", results[0])
+		}
+		if results[1].ID != "doc/2.txt" || results[1].Reply != nil || results[1].Error == "" {
+			t.Fatalf("expected the missing result to be an error: %+v", results[1])
+		}
+	})
+
+	t.Run("Resumes the saved batch", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "job.json")
+		client := &fakeBatchClient{pending: 1000}
+
+		ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+		defer cancel()
+		if _, err := batch.Run(ctx, client, requests(), batch.Config{StatePath: path, PollInterval: time.Millisecond}); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected the run to be interrupted, got: %v", err)
+		}
+
+		job, err := batch.LoadJob(path)
+		if err != nil || job.BatchID != "batch-1" || job.Status == nil {
+			t.Fatalf("expected the saved job, got %+v: %v", job, err)
+		}
+
+		client.pending = 0
+		results, err := batch.Run(context.Background(), client, requests(), batch.Config{StatePath: path, PollInterval: time.Millisecond})
+		if err != nil || len(results) != 2 {
+			t.Fatalf("expected 2 results, got %+v: %v", results, err)
+		}
+		if client.submits != 1 {
+			t.Fatalf("the batch should be submitted once, got %d", client.submits)
+		}
+
+		if _, err := batch.Run(context.Background(), client, requests()[:1], batch.Config{StatePath: path}); err == nil {
+			t.Fatalf("resuming with other requests should fail")
+		}
+
+		changed := requests()
+		changed[1].Messages[0].Content = "changed"
+		if _, err := batch.Run(context.Background(), client, changed, batch.Config{StatePath: path}); err == nil {
+			t.Fatalf("resuming with other contents under the same ids should fail")
+		}
+	})
+
+	t.Run("Failed batch", func(t *testing.T) {
+		_, err := batch.Run(ctx, &fakeBatchClient{failed: true}, requests(), batch.Config{})
+		if !errors.Is(err, batch.ErrBatchFailed) {
+			t.Fatalf("expected ErrBatchFailed, got: %v", err)
+		}
+	})
+}
@@ -0,0 +1,138 @@
+package llm_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sieglu2/go_foundation/llm"
+)
+
+var batchRequests = []llm.BatchRequest{
+	{ID: "0", Messages: []llm.LlmMessage{{Role: llm.RoleSystem, Content: "be brief"}, {Role: llm.RoleUser, Content: "hi"}}},
+	{ID: "1", Messages: []llm.LlmMessage{{Role: llm.RoleUser, Content: "bye"}}},
+}
+
+func TestClaudeBatch(t *testing.T) {
+	ctx := context.Background()
+
+	var submitted string
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	mux.HandleFunc("/v1/messages/batches", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		submitted = string(body)
+		w.Write([]byte(`{"id": "msgbatch_1", "processing_status": "in_progress", "request_counts": {"processing": 2}}`))
+	})
+	mux.HandleFunc("/v1/messages/batches/msgbatch_1", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id": "msgbatch_1", "processing_status": "ended", "request_counts": {"succeeded": 1, "errored": 1},
+			"results_url": "` + server.URL + `/v1/messages/batches/msgbatch_1/results"}`))
+	})
+	mux.HandleFunc("/v1/messages/batches/msgbatch_1/results", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"custom_id": "1", "result": {"type": "errored", "error": {"type": "error", "error": {"type": "invalid_request_error", "message": "too long"}}}}
+{"custom_id": "0", "result": {"type": "succeeded", "message": {"content": [{"type": "text", "text": "hello"}], "stop_reason": "end_turn", "usage": {"input_tokens": 5, "output_tokens": 1}}}}
+`))
+	})
+
+	client := llm.NewClaudeClient("key")
+	client.SetBaseURL(server.URL)
+
+	batchID, err := client.SubmitBatch(ctx, batchRequests)
+	if err != nil {
+		t.Fatalf("SubmitBatch failed: %v", err)
+	}
+	if !strings.Contains(submitted, `"custom_id":"0","params":{"model"`) || !strings.Contains(submitted, `"system":"be brief"`) {
+		t.Fatalf("unexpected submitted batch: %s", submitted)
+	}
+
+	status, err := client.GetBatchStatus(ctx, batchID)
+	if err != nil || status.State != llm.BatchEnded || status.Total != 2 || status.Failed != 1 {
+		t.Fatalf("unexpected status %+v: %v", status, err)
+	}
+
+	results, err := client.GetBatchResults(ctx, batchID)
+	if err != nil || len(results) != 2 {
+		t.Fatalf("expected 2 results, got %+v: %v", results, err)
+	}
+	if results[0].Error != "invalid_request_error: too long" {
+		t.Fatalf("unexpected error result: %+v", results[0])
+	}
+	if results[1].Reply.Content != "hello" || results[1].Reply.Usage.TotalTokens != 6 {
+		t.Fatalf("unexpected reply: %+v", results[1].Reply)
+	}
+
+	if _, err := client.SubmitBatch(ctx, []llm.BatchRequest{{ID: "a/b", Messages: batchRequests[0].Messages}}); err == nil {
+		t.Fatalf("ids with slashes should be rejected")
+	}
+}
+
+func TestChatGptBatch(t *testing.T) {
+	ctx := context.Background()
+
+	var uploaded string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/files", func(w http.ResponseWriter, r *http.Request) {
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			t.Errorf("expected a file upload: %v", err)
+		}
+		content, _ := io.ReadAll(file)
+		uploaded = string(content)
+		w.Write([]byte(`{"id": "file-in"}`))
+	})
+	mux.HandleFunc("/v1/batches", func(w http.ResponseWriter, r *http.Request) {
+		var request map[string]any
+		json.NewDecoder(r.Body).Decode(&request)
+		if request["input_file_id"] != "file-in" {
+			t.Errorf("unexpected batch request: %v", request)
+		}
+		w.Write([]byte(`{"id": "batch_1", "status": "validating"}`))
+	})
+	mux.HandleFunc("/v1/batches/batch_1", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id": "batch_1", "status": "completed", "output_file_id": "file-out", "error_file_id": "file-err",
+			"request_counts": {"total": 2, "completed": 1, "failed": 1}}`))
+	})
+	mux.HandleFunc("/v1/files/file-out/content", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"custom_id": "0", "response": {"status_code": 200, "body": {"choices": [{"message": {"content": "hello"}, "finish_reason": "stop"}], "usage": {"total_tokens": 7}}}}` + "\n"))
+	})
+	mux.HandleFunc("/v1/files/file-err/content", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"custom_id": "1", "response": {"status_code": 400, "body": {}}}` + "\n"))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client, err := llm.NewChatGptClientWithApiConfig(llm.ChatGptConfig{BaseURL: server.URL + "/v1"}, "key", 100, "gpt-4o")
+	if err != nil {
+		t.Fatalf("NewChatGptClientWithApiConfig failed: %v", err)
+	}
+
+	batchID, err := client.SubmitBatch(ctx, batchRequests)
+	if err != nil {
+		t.Fatalf("SubmitBatch failed: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(uploaded), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], `"custom_id":"0"`) || !strings.Contains(lines[0], `"url":"/v1/chat/completions"`) {
+		t.Fatalf("unexpected jsonl: %s", uploaded)
+	}
+
+	status, err := client.GetBatchStatus(ctx, batchID)
+	if err != nil || status.State != llm.BatchEnded || status.Succeeded != 1 {
+		t.Fatalf("unexpected status %+v: %v", status, err)
+	}
+
+	results, err := client.GetBatchResults(ctx, batchID)
+	if err != nil || len(results) != 2 {
+		t.Fatalf("expected 2 results, got %+v: %v", results, err)
+	}
+	if results[0].Reply.Content != "hello" || results[0].Reply.FinishReason != "stop" {
+		t.Fatalf("unexpected reply: %+v", results[0].Reply)
+	}
+	if results[1].ID != "1" || results[1].Error != "status code 400" {
+		t.Fatalf("unexpected error result: %+v", results[1])
+	}
+}
@@ -0,0 +1,335 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/sieglu2/go_foundation/foundation"
+	"github.com/sieglu2/go_foundation/llm/secret_key"
+)
+
+// imageTokenEstimate is a rough token count of an image input, the providers charge
+// between a few hundred and a couple thousand tokens depending on the size.
+const imageTokenEstimate = 1000
+
+var ErrBudgetExceeded = errors.New("budget exceeded")
+
+// ErrModelNotPriced is returned by a BudgetClient whose model has no price, as its calls
+// could not be estimated before being sent.
+var ErrModelNotPriced = errors.New("model has no price")
+
+// BudgetExceededError is returned before sending a call that would exceed a budget.
+// errors.Is(err, ErrBudgetExceeded) holds for it.
+type BudgetExceededError struct {
+	Budget    string
+	Limit     float64
+	Spent     float64
+	Estimated float64
+}
+
+func (e *BudgetExceededError) Error() string {
+	return fmt.Sprintf("budget %s exceeded: $%.4f spent or reserved of $%.4f, call estimated at $%.4f",
+		e.Budget, e.Spent, e.Limit, e.Estimated)
+}
+
+func (e *BudgetExceededError) Is(target error) bool {
+	return target == ErrBudgetExceeded
+}
+
+// ModelPrice is the price of a model in USD per million tokens.
+type ModelPrice struct {
+	Prompt     float64
+	Completion float64
+}
+
+type PriceTable map[string]ModelPrice
+
+// DefaultPrices holds the list prices of the default models of the clients, and of a few
+// common alternatives. Prices change, callers with contracts should pass their own table.
+var DefaultPrices = PriceTable{
+	"claude-3-opus":     {Prompt: 15, Completion: 75},
+	"claude-3-5-sonnet": {Prompt: 3, Completion: 15},
+	"claude-3-5-haiku":  {Prompt: 0.8, Completion: 4},
+	"gpt-4-turbo":       {Prompt: 10, Completion: 30},
+	"gpt-4o":            {Prompt: 2.5, Completion: 10},
+	"gpt-4o-mini":       {Prompt: 0.15, Completion: 0.6},
+	"gemini-1.5-pro":    {Prompt: 1.25, Completion: 5},
+	"gemini-1.5-flash":  {Prompt: 0.075, Completion: 0.3},
+	"deepseek-chat":     {Prompt: 0.27, Completion: 1.1},
+	"deepseek-reasoner": {Prompt: 0.55, Completion: 2.19},
+	"MiniMax-Text-01":   {Prompt: 0.2, Completion: 1.1},
+}
+
+// Lookup returns the price of model, matching the longest entry the model name starts
+// with so dated versions like claude-3-opus-20240229 use the claude-3-opus price.
+func (t PriceTable) Lookup(model string) (ModelPrice, bool) {
+	if price, ok := t[model]; ok {
+		return price, true
+	}
+
+	var found ModelPrice
+	longest := 0
+	for name, price := range t {
+		if len(name) > longest && strings.HasPrefix(model, name) {
+			found = price
+			longest = len(name)
+		}
+	}
+	return found, longest > 0
+}
+
+// Cost returns the cost in USD of usage on model, false if the model has no price.
+func (t PriceTable) Cost(model string, usage LlmUsage) (float64, bool) {
+	price, ok := t.Lookup(model)
+	if !ok {
+		return 0, false
+	}
+	return (price.Prompt*float64(usage.PromptTokens) + price.Completion*float64(usage.CompletionTokens)) / 1e6, true
+}
+
+// EstimateTokens gives a rough token count of messages, about 4 characters per token,
+// for decisions made before the provider reports the real usage.
+func EstimateTokens(messages []LlmMessage) int {
+	tokens := 0
+	for _, message := range messages {
+		tokens += estimateTextTokens(message.Content) + 4
+		if message.B64Image != "" {
+			tokens += imageTokenEstimate
+		}
+	}
+	return tokens
+}
+
+func estimateTextTokens(text string) int {
+	return (len(text) + 3) / 4
+}
+
+// Budget caps the spend in USD of the calls charged to it. Calls reserve their estimated
+// cost before being sent, so concurrent calls cannot overshoot the limit together.
+type Budget struct {
+	name string
+
+	mu       sync.Mutex
+	limit    float64
+	spent    float64
+	reserved float64
+}
+
+// NewBudget creates a budget of limit USD, 0 meaning unlimited.
+func NewBudget(name string, limit float64) *Budget {
+	return &Budget{
+		name:  name,
+		limit: limit,
+	}
+}
+
+func (b *Budget) Name() string {
+	return b.name
+}
+
+func (b *Budget) Limit() float64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.limit
+}
+
+func (b *Budget) SetLimit(limit float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.limit = limit
+}
+
+func (b *Budget) Spent() float64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.spent
+}
+
+// Reset clears the spend, e.g. at the start of a new billing period.
+func (b *Budget) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.spent = 0
+}
+
+func (b *Budget) reserve(estimate float64) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.limit > 0 && (b.spent >= b.limit || b.spent+b.reserved+estimate > b.limit) {
+		return &BudgetExceededError{
+			Budget:    b.name,
+			Limit:     b.limit,
+			Spent:     b.spent + b.reserved,
+			Estimated: estimate,
+		}
+	}
+	b.reserved += estimate
+	return nil
+}
+
+func (b *Budget) settle(reserved float64, cost float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.reserved -= reserved
+	b.spent += cost
+}
+
+var (
+	processBudget = NewBudget("process", 0)
+
+	keyBudgetsMu sync.Mutex
+	keyBudgets   = map[string]*Budget{}
+)
+
+// ProcessBudget is charged with every call made through a BudgetClient. It is unlimited
+// until SetLimit is called on it.
+func ProcessBudget() *Budget {
+	return processBudget
+}
+
+// KeyBudget returns the budget of an api key, shared by every BudgetClient configured with
+// that key. It is unlimited until SetLimit is called on it.
+func KeyBudget(apiKey string) *Budget {
+	keyBudgetsMu.Lock()
+	defer keyBudgetsMu.Unlock()
+
+	budget, ok := keyBudgets[apiKey]
+	if !ok {
+		budget = NewBudget("key "+secret_key.MaskKey(apiKey), 0)
+		keyBudgets[apiKey] = budget
+	}
+	return budget
+}
+
+type budgetContextKey struct{}
+
+// WithBudget returns a context whose calls through a BudgetClient are also charged to
+// budget, e.g. one budget per batch job. Budgets of enclosing contexts still apply.
+func WithBudget(ctx context.Context, budget *Budget) context.Context {
+	budgets := append(budgetsFromContext(ctx), budget)
+	return context.WithValue(ctx, budgetContextKey{}, budgets)
+}
+
+func budgetsFromContext(ctx context.Context) []*Budget {
+	budgets, _ := ctx.Value(budgetContextKey{}).([]*Budget)
+	// copy so sibling contexts never share the backing array
+	return append([]*Budget(nil), budgets...)
+}
+
+type BudgetConfig struct {
+	// Prices is the price table, DefaultPrices if nil.
+	Prices PriceTable
+	// Model is the model of the wrapped client, used to estimate a call before sending it,
+	// so it must have a price. The model reported in the reply is charged when set.
+	Model string
+	// ApiKey charges the calls to KeyBudget(ApiKey) as well when set.
+	ApiKey string
+	// Budgets are charged on top of the process, key and context budgets.
+	Budgets []*Budget
+	// MaxCompletionTokens is the completion size assumed when estimating a call,
+	// DefaultMaxTokens if 0.
+	MaxCompletionTokens int
+}
+
+// BudgetClient is an LlmClient failing fast with a BudgetExceededError when a call would
+// exceed the process budget, the budget of its key, a budget set on the context with
+// WithBudget or one of its configured budgets.
+type BudgetClient struct {
+	client LlmClient
+	config BudgetConfig
+}
+
+func NewBudgetClient(client LlmClient, config BudgetConfig) *BudgetClient {
+	if config.Prices == nil {
+		config.Prices = DefaultPrices
+	}
+	if config.MaxCompletionTokens <= 0 {
+		config.MaxCompletionTokens = DefaultMaxTokens
+	}
+	return &BudgetClient{
+		client: client,
+		config: config,
+	}
+}
+
+func (b *BudgetClient) ReplyMessage(ctx context.Context, messages []LlmMessage) (string, error) {
+	reply, err := b.ReplyMessageDetail(ctx, messages)
+	if err != nil {
+		return "", err
+	}
+	return reply.Content, nil
+}
+
+func (b *BudgetClient) ReplyMessageDetail(ctx context.Context, messages []LlmMessage) (*LlmReply, error) {
+	logger := foundation.Logger()
+
+	budgets := append([]*Budget{processBudget}, b.config.Budgets...)
+	if b.config.ApiKey != "" {
+		budgets = append(budgets, KeyBudget(b.config.ApiKey))
+	}
+	budgets = append(budgets, budgetsFromContext(ctx)...)
+
+	estimate, ok := b.config.Prices.Cost(b.config.Model, LlmUsage{
+		PromptTokens:     EstimateTokens(messages),
+		CompletionTokens: b.config.MaxCompletionTokens,
+	})
+	if !ok {
+		// an estimate of 0 would let the call through until the limit is already spent
+		logger.Errorf("refusing call: no price for model %q", b.config.Model)
+		return nil, fmt.Errorf("%w: %q, set BudgetConfig.Model to a priced model", ErrModelNotPriced, b.config.Model)
+	}
+
+	for i, budget := range budgets {
+		if err := budget.reserve(estimate); err != nil {
+			for _, reserved := range budgets[:i] {
+				reserved.settle(estimate, 0)
+			}
+			logger.Errorf("refusing call: %v", err)
+			return nil, err
+		}
+	}
+
+	reply, err := ReplyMessageDetail(ctx, b.client, messages)
+
+	cost := 0.0
+	if err == nil {
+		cost = b.cost(messages, reply, estimate)
+	}
+	for _, budget := range budgets {
+		budget.settle(estimate, cost)
+	}
+
+	return reply, err
+}
+
+// cost charges the usage reported by the provider, or an estimate when it reported none.
+// A reply of a model without price, e.g. a dated snapshot of the configured model, is
+// charged at the price of the configured model, or at the reserved estimate.
+func (b *BudgetClient) cost(messages []LlmMessage, reply *LlmReply, estimate float64) float64 {
+	logger := foundation.Logger()
+
+	usage := reply.Usage
+	if usage.PromptTokens == 0 && usage.CompletionTokens == 0 {
+		usage.PromptTokens = EstimateTokens(messages)
+		usage.CompletionTokens = estimateTextTokens(reply.Content)
+	}
+
+	if reply.Model != "" {
+		if cost, ok := b.config.Prices.Cost(reply.Model, usage); ok {
+			return cost
+		}
+		logger.Warnf("no price for model %q, charging the price of %q", reply.Model, b.config.Model)
+	}
+	if cost, ok := b.config.Prices.Cost(b.config.Model, usage); ok {
+		return cost
+	}
+	return estimate
+}
+
+func (b *BudgetClient) Close() error {
+	return b.client.Close()
+}
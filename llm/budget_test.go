@@ -0,0 +1,188 @@
+package llm_test
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/sieglu2/go_foundation/llm"
+)
+
+// newFakeUsageClient replies with the test model and a fixed usage.
+func newFakeUsageClient(usage llm.LlmUsage) *fakeClient {
+	return &fakeClient{reply: fixedReply(llm.LlmReply{Content: "ok", Model: "test-model", Usage: usage})}
+}
+
+var budgetPrices = llm.PriceTable{
+	"test-model": {Prompt: 1, Completion: 2},
+}
+
+func TestPriceTable(t *testing.T) {
+	cost, ok := llm.DefaultPrices.Cost("claude-3-opus-20240229", llm.LlmUsage{PromptTokens: 1000000})
+	if !ok || cost != 15 {
+		t.Fatalf("expected the claude-3-opus prompt price 15, got %v (found %v)", cost, ok)
+	}
+
+	price, ok := llm.DefaultPrices.Lookup("gpt-4o-mini-2024-07-18")
+	if !ok || price.Prompt != 0.15 {
+		t.Fatalf("expected the longest prefix gpt-4o-mini to match, got %+v", price)
+	}
+
+	if _, ok := llm.DefaultPrices.Lookup("unknown-model"); ok {
+		t.Fatalf("unknown model should have no price")
+	}
+}
+
+func TestBudgetClient(t *testing.T) {
+	messages := []llm.LlmMessage{{Role: llm.RoleUser, Content: "hi"}}
+	// each call costs (1*1000000 + 2*500000) / 1e6 = $2
+	usage := llm.LlmUsage{PromptTokens: 1000000, CompletionTokens: 500000}
+
+	t.Run("Charges the reported usage", func(t *testing.T) {
+		budget := llm.NewBudget("test", 0)
+		client := llm.NewBudgetClient(newFakeUsageClient(usage), llm.BudgetConfig{
+			Prices:  budgetPrices,
+			Model:   "test-model",
+			Budgets: []*llm.Budget{budget},
+		})
+
+		for i := 0; i < 3; i++ {
+			if _, err := client.ReplyMessage(context.Background(), messages); err != nil {
+				t.Fatalf("ReplyMessage failed: %v", err)
+			}
+		}
+		if budget.Spent() != 6 {
+			t.Fatalf("expected $6 spent, got %v", budget.Spent())
+		}
+	})
+
+	t.Run("Unpriced reply model charged at the configured model", func(t *testing.T) {
+		budget := llm.NewBudget("test", 0)
+		fake := &fakeClient{reply: fixedReply(llm.LlmReply{Content: "ok", Model: "test-model-2024-08-06", Usage: usage})}
+		client := llm.NewBudgetClient(fake, llm.BudgetConfig{
+			Prices:  budgetPrices,
+			Model:   "test-model",
+			Budgets: []*llm.Budget{budget},
+		})
+
+		if _, err := client.ReplyMessage(context.Background(), messages); err != nil {
+			t.Fatalf("ReplyMessage failed: %v", err)
+		}
+		if budget.Spent() != 2 {
+			t.Fatalf("expected $2 spent, got %v", budget.Spent())
+		}
+	})
+
+	t.Run("Fails fast once the budget is spent", func(t *testing.T) {
+		fake := newFakeUsageClient(usage)
+		budget := llm.NewBudget("test", 3)
+		client := llm.NewBudgetClient(fake, llm.BudgetConfig{
+			Prices:              budgetPrices,
+			Model:               "test-model",
+			Budgets:             []*llm.Budget{budget},
+			MaxCompletionTokens: 1,
+		})
+
+		if _, err := client.ReplyMessage(context.Background(), messages); err != nil {
+			t.Fatalf("first call should pass: %v", err)
+		}
+		if _, err := client.ReplyMessage(context.Background(), messages); err != nil {
+			t.Fatalf("second call should pass, the limit was not reached yet: %v", err)
+		}
+
+		_, err := client.ReplyMessage(context.Background(), messages)
+		var budgetErr *llm.BudgetExceededError
+		if !errors.As(err, &budgetErr) || !errors.Is(err, llm.ErrBudgetExceeded) {
+			t.Fatalf("expected a BudgetExceededError, got: %v", err)
+		}
+		if budgetErr.Budget != "test" {
+			t.Fatalf("expected the test budget to be exceeded, got %s", budgetErr.Budget)
+		}
+		if fake.Calls() != 2 {
+			t.Fatalf("the refused call should not reach the client, got %d calls", fake.Calls())
+		}
+	})
+
+	t.Run("Refuses a call whose estimate exceeds the budget", func(t *testing.T) {
+		fake := newFakeUsageClient(usage)
+		client := llm.NewBudgetClient(fake, llm.BudgetConfig{
+			Prices:              budgetPrices,
+			Model:               "test-model",
+			Budgets:             []*llm.Budget{llm.NewBudget("small", 0.001)},
+			MaxCompletionTokens: 1000,
+		})
+
+		if _, err := client.ReplyMessage(context.Background(), messages); !errors.Is(err, llm.ErrBudgetExceeded) {
+			t.Fatalf("expected ErrBudgetExceeded, got: %v", err)
+		}
+		if fake.Calls() != 0 {
+			t.Fatalf("the refused call should not reach the client")
+		}
+	})
+
+	t.Run("Context budget applies to the calls made with the context", func(t *testing.T) {
+		client := llm.NewBudgetClient(newFakeUsageClient(usage), llm.BudgetConfig{
+			Prices:              budgetPrices,
+			Model:               "test-model",
+			MaxCompletionTokens: 1,
+		})
+
+		job := llm.NewBudget("job", 2)
+		ctx := llm.WithBudget(context.Background(), job)
+		if _, err := client.ReplyMessage(ctx, messages); err != nil {
+			t.Fatalf("ReplyMessage failed: %v", err)
+		}
+		if _, err := client.ReplyMessage(ctx, messages); !errors.Is(err, llm.ErrBudgetExceeded) {
+			t.Fatalf("expected ErrBudgetExceeded, got: %v", err)
+		}
+		if _, err := client.ReplyMessage(context.Background(), messages); err != nil {
+			t.Fatalf("calls without the job context should pass: %v", err)
+		}
+	})
+
+	t.Run("Key budget is shared by the clients of a key", func(t *testing.T) {
+		llm.KeyBudget("sk-budget-test").SetLimit(2)
+
+		config := llm.BudgetConfig{Prices: budgetPrices, Model: "test-model", ApiKey: "sk-budget-test", MaxCompletionTokens: 1}
+		first := llm.NewBudgetClient(newFakeUsageClient(usage), config)
+		second := llm.NewBudgetClient(newFakeUsageClient(usage), config)
+
+		if _, err := first.ReplyMessage(context.Background(), messages); err != nil {
+			t.Fatalf("ReplyMessage failed: %v", err)
+		}
+		if _, err := second.ReplyMessage(context.Background(), messages); !errors.Is(err, llm.ErrBudgetExceeded) {
+			t.Fatalf("expected ErrBudgetExceeded, got: %v", err)
+		}
+	})
+
+	t.Run("Missing usage is estimated from the text", func(t *testing.T) {
+		budget := llm.NewBudget("test", 0)
+		client := llm.NewBudgetClient(newFakeUsageClient(llm.LlmUsage{}), llm.BudgetConfig{
+			Prices:  budgetPrices,
+			Model:   "test-model",
+			Budgets: []*llm.Budget{budget},
+		})
+
+		if _, err := client.ReplyMessage(context.Background(), messages); err != nil {
+			t.Fatalf("ReplyMessage failed: %v", err)
+		}
+		// "hi" is 1 token plus 4 of message overhead, "ok" 1 token at twice the price
+		if math.Abs(budget.Spent()-7e-6) > 1e-12 {
+			t.Fatalf("expected $0.000007 spent, got %v", budget.Spent())
+		}
+	})
+	t.Run("Refuses a model without price", func(t *testing.T) {
+		for _, model := range []string{"", "unknown-model"} {
+			fake := newFakeUsageClient(usage)
+			client := llm.NewBudgetClient(fake, llm.BudgetConfig{Prices: budgetPrices, Model: model})
+
+			if _, err := client.ReplyMessage(context.Background(), messages); !errors.Is(err, llm.ErrModelNotPriced) {
+				t.Fatalf("expected ErrModelNotPriced for %q, got: %v", model, err)
+			}
+			if fake.Calls() != 0 {
+				t.Fatalf("the refused call should not reach the client")
+			}
+		}
+	})
+}
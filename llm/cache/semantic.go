@@ -0,0 +1,254 @@
+// Package cache answers prompts similar enough to an earlier one with the earlier reply,
+// comparing the embeddings of the prompts.
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sieglu2/go_foundation/foundation"
+	"github.com/sieglu2/go_foundation/llm"
+	"github.com/sieglu2/go_foundation/llm/vectorstore"
+)
+
+// DefaultThreshold is the cosine similarity from which two prompts are deemed the same
+// question. Paraphrases usually score above 0.9 with the common embedding models.
+const DefaultThreshold = 0.92
+
+type Config struct {
+	// Threshold is the similarity from which a stored reply is returned, DefaultThreshold
+	// if 0.
+	Threshold float32
+	// Model scopes the entries, so clients of different models can share an embedder
+	// without sharing replies.
+	Model string
+	// TTL is how long a reply is returned, forever if 0.
+	TTL time.Duration
+	// MaxEntries evicts the oldest replies beyond it, unlimited if 0.
+	MaxEntries int
+}
+
+type Stats struct {
+	Hits   int64 `json:"hits"`
+	Misses int64 `json:"misses"`
+	// Bypassed are the calls not looked up, e.g. with an image or when embedding failed.
+	Bypassed int64 `json:"bypassed"`
+	Entries  int   `json:"entries"`
+}
+
+// HitRate is the share of the looked up calls answered from the cache.
+func (s Stats) HitRate() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+type entry struct {
+	reply     llm.LlmReply
+	createdAt time.Time
+}
+
+// SemanticCacheClient is an LlmClient embedding the last user message of every call and
+// returning the reply of a stored message close enough to it. Entries are scoped by the
+// model, the system prompt and the earlier messages of the conversation.
+type SemanticCacheClient struct {
+	client   llm.LlmClient
+	embedder llm.Embedder
+	config   Config
+	store    *vectorstore.Store
+
+	mu      sync.Mutex
+	entries map[string]entry
+	// order is the ids of the entries, oldest first.
+	order  []string
+	nextID int
+	stats  Stats
+}
+
+var (
+	_ llm.LlmClient       = (*SemanticCacheClient)(nil)
+	_ llm.LlmDetailClient = (*SemanticCacheClient)(nil)
+)
+
+func NewSemanticCacheClient(client llm.LlmClient, embedder llm.Embedder, config Config) *SemanticCacheClient {
+	if config.Threshold <= 0 {
+		config.Threshold = DefaultThreshold
+	}
+	return &SemanticCacheClient{
+		client:   client,
+		embedder: embedder,
+		config:   config,
+		store:    vectorstore.NewStore(vectorstore.Cosine),
+		entries:  map[string]entry{},
+	}
+}
+
+func (c *SemanticCacheClient) ReplyMessage(ctx context.Context, messages []llm.LlmMessage) (string, error) {
+	reply, err := c.ReplyMessageDetail(ctx, messages)
+	if err != nil {
+		return "", err
+	}
+	return reply.Content, nil
+}
+
+// ReplyMessageDetail returns a stored reply with an empty usage on a hit, as no tokens
+// were spent.
+func (c *SemanticCacheClient) ReplyMessageDetail(ctx context.Context, messages []llm.LlmMessage) (*llm.LlmReply, error) {
+	logger := foundation.Logger()
+
+	if len(messages) == 0 {
+		return llm.ReplyMessageDetail(ctx, c.client, messages)
+	}
+	last := messages[len(messages)-1]
+	if last.Role != llm.RoleUser || last.Content == "" || last.B64Image != "" {
+		c.count(func(stats *Stats) { stats.Bypassed++ })
+		return llm.ReplyMessageDetail(ctx, c.client, messages)
+	}
+
+	vectors, err := c.embedder.Embed(ctx, []string{last.Content})
+	if err != nil || len(vectors) != 1 {
+		logger.Warnf("failed to embed the prompt, calling without cache: %v", err)
+		c.count(func(stats *Stats) { stats.Bypassed++ })
+		return llm.ReplyMessageDetail(ctx, c.client, messages)
+	}
+	vector := vectors[0]
+	scope := c.scope(messages[:len(messages)-1])
+
+	if reply, ok := c.lookup(vector, scope); ok {
+		c.count(func(stats *Stats) { stats.Hits++ })
+		return reply, nil
+	}
+	c.count(func(stats *Stats) { stats.Misses++ })
+
+	reply, err := llm.ReplyMessageDetail(ctx, c.client, messages)
+	if err != nil {
+		return nil, err
+	}
+	c.add(last.Content, vector, scope, reply)
+	return reply, nil
+}
+
+func (c *SemanticCacheClient) Close() error {
+	return c.client.Close()
+}
+
+// Stats returns the hits and misses since the client was created.
+func (c *SemanticCacheClient) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Entries = len(c.entries)
+	return stats
+}
+
+// Clear drops every stored reply, keeping the statistics.
+func (c *SemanticCacheClient) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.store.Delete(c.order...)
+	c.entries = map[string]entry{}
+	c.order = nil
+}
+
+func (c *SemanticCacheClient) count(update func(stats *Stats)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	update(&c.stats)
+}
+
+// scope hashes the model and the messages before the prompt.
+func (c *SemanticCacheClient) scope(context []llm.LlmMessage) string {
+	hash := sha256.New()
+	hash.Write([]byte(c.config.Model))
+	for _, message := range context {
+		hash.Write([]byte{0})
+		hash.Write([]byte(message.Role))
+		hash.Write([]byte{0})
+		hash.Write([]byte(message.Content))
+		hash.Write([]byte{0})
+		hash.Write([]byte(message.B64Image))
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func (c *SemanticCacheClient) lookup(vector []float32, scope string) (*llm.LlmReply, bool) {
+	logger := foundation.Logger()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// with the expired entries gone, an older match never hides a fresh one
+	c.removeExpired()
+	if len(c.entries) == 0 {
+		return nil, false
+	}
+	matches, err := c.store.Search(vector, 1, vectorstore.MetadataEquals(map[string]string{"scope": scope}))
+	if err != nil {
+		logger.Warnf("failed to search the cache: %v", err)
+		return nil, false
+	}
+	if len(matches) == 0 || matches[0].Score < c.config.Threshold {
+		return nil, false
+	}
+
+	reply := c.entries[matches[0].Document.ID].reply
+	reply.Usage = llm.LlmUsage{}
+	return &reply, true
+}
+
+func (c *SemanticCacheClient) add(prompt string, vector []float32, scope string, reply *llm.LlmReply) {
+	logger := foundation.Logger()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.removeExpired()
+	c.nextID++
+	id := strconv.Itoa(c.nextID)
+	document := vectorstore.Document{
+		ID:       id,
+		Text:     prompt,
+		Metadata: map[string]string{"scope": scope},
+		Vector:   vector,
+	}
+	if err := c.store.Add(document); err != nil {
+		logger.Warnf("failed to add the reply to the cache: %v", err)
+		return
+	}
+	c.entries[id] = entry{reply: *reply, createdAt: time.Now()}
+	c.order = append(c.order, id)
+
+	for c.config.MaxEntries > 0 && len(c.order) > c.config.MaxEntries {
+		c.remove(c.order[0])
+	}
+}
+
+// removeExpired drops the entries older than the TTL, the lock being held. The order is
+// the one of creation, so they are at its start.
+func (c *SemanticCacheClient) removeExpired() {
+	if c.config.TTL <= 0 {
+		return
+	}
+	for len(c.order) > 0 && time.Since(c.entries[c.order[0]].createdAt) > c.config.TTL {
+		c.remove(c.order[0])
+	}
+}
+
+// remove drops an entry, the lock being held.
+func (c *SemanticCacheClient) remove(id string) {
+	c.store.Delete(id)
+	delete(c.entries, id)
+	for i, ordered := range c.order {
+		if ordered == id {
+			c.order = append(c.order[:i], c.order[i+1:]...)
+			break
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package cache_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sieglu2/go_foundation/llm"
+	"github.com/sieglu2/go_foundation/llm/cache"
+)
+
+// fakeWordEmbedder embeds a text as the counts of a few words, so paraphrases using the
+// same words are identical.
+type fakeWordEmbedder struct{}
+
+func (fakeWordEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	words := []string{"password", "reset", "refund", "order"}
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vectors[i] = make([]float32, len(words))
+		for j, word := range words {
+			vectors[i][j] = float32(strings.Count(strings.ToLower(text), word))
+		}
+	}
+	return vectors, nil
+}
+
+// fakeCountingClient answers with the number of the call.
+type fakeCountingClient struct {
+	calls int
+}
+
+func (f *fakeCountingClient) ReplyMessage(ctx context.Context, messages []llm.LlmMessage) (string, error) {
+	reply, err := f.ReplyMessageDetail(ctx, messages)
+	if err != nil {
+		return "", err
+	}
+	return reply.Content, nil
+}
+
+func (f *fakeCountingClient) ReplyMessageDetail(ctx context.Context, messages []llm.LlmMessage) (*llm.LlmReply, error) {
+	f.calls++
+	return &llm.LlmReply{Content: "answer " + string(rune('0'+f.calls)), Usage: llm.LlmUsage{TotalTokens: 10}}, nil
+}
+
+func (f *fakeCountingClient) Close() error {
+	return nil
+}
+
+func ask(system string, question string) []llm.LlmMessage {
+	return []llm.LlmMessage{
+		{Role: llm.RoleSystem, Content: system},
+		{Role: llm.RoleUser, Content: question},
+	}
+}
+
+func TestSemanticCacheClient(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Paraphrases hit", func(t *testing.T) {
+		client := &fakeCountingClient{}
+		cached := cache.NewSemanticCacheClient(client, fakeWordEmbedder{}, cache.Config{})
+
+		first, _ := cached.ReplyMessageDetail(ctx, ask("support", "How do I reset my password?"))
+		second, _ := cached.ReplyMessageDetail(ctx, ask("support", "password reset please"))
+		if client.calls != 1 || second.Content != first.Content || second.Usage.TotalTokens != 0 {
+			t.Fatalf("expected the stored reply, got %+v after %d calls", second, client.calls)
+		}
+
+		if reply, _ := cached.ReplyMessage(ctx, ask("support", "where is my refund?")); reply != "answer 2" {
+			t.Fatalf("another question should miss, got %s", reply)
+		}
+
+		stats := cached.Stats()
+		if stats.Hits != 1 || stats.Misses != 2 || stats.Entries != 2 || stats.HitRate() != 1.0/3 {
+			t.Fatalf("unexpected stats: %+v", stats)
+		}
+	})
+
+	t.Run("Scoped by system prompt and model", func(t *testing.T) {
+		client := &fakeCountingClient{}
+		embedder := fakeWordEmbedder{}
+		cached := cache.NewSemanticCacheClient(client, embedder, cache.Config{Model: "a"})
+
+		cached.ReplyMessage(ctx, ask("support", "reset password"))
+		cached.ReplyMessage(ctx, ask("sales", "reset password"))
+		if client.calls != 2 {
+			t.Fatalf("another system prompt should miss, got %d calls", client.calls)
+		}
+
+		other := cache.NewSemanticCacheClient(client, embedder, cache.Config{Model: "b"})
+		other.ReplyMessage(ctx, ask("support", "reset password"))
+		if client.calls != 3 {
+			t.Fatalf("another model should miss, got %d calls", client.calls)
+		}
+	})
+
+	t.Run("TTL and eviction", func(t *testing.T) {
+		client := &fakeCountingClient{}
+		cached := cache.NewSemanticCacheClient(client, fakeWordEmbedder{}, cache.Config{TTL: time.Millisecond, MaxEntries: 1})
+
+		cached.ReplyMessage(ctx, ask("", "reset password"))
+		cached.ReplyMessage(ctx, ask("", "refund order"))
+		if cached.Stats().Entries != 1 {
+			t.Fatalf("expected the oldest entry evicted, got %+v", cached.Stats())
+		}
+
+		time.Sleep(5 * time.Millisecond)
+		cached.ReplyMessage(ctx, ask("", "refund order"))
+		if client.calls != 3 {
+			t.Fatalf("the expired reply should not be returned, got %d calls", client.calls)
+		}
+	})
+
+	t.Run("Expired entries dropped without eviction", func(t *testing.T) {
+		client := &fakeCountingClient{}
+		cached := cache.NewSemanticCacheClient(client, fakeWordEmbedder{}, cache.Config{TTL: time.Millisecond})
+
+		cached.ReplyMessage(ctx, ask("", "reset password"))
+		cached.ReplyMessage(ctx, ask("", "refund order"))
+		time.Sleep(5 * time.Millisecond)
+		cached.ReplyMessage(ctx, ask("", "order"))
+		if cached.Stats().Entries != 1 {
+			t.Fatalf("expected the expired entries dropped, got %+v", cached.Stats())
+		}
+	})
+
+	t.Run("Expired best match does not hide a fresh one", func(t *testing.T) {
+		client := &fakeCountingClient{}
+		cached := cache.NewSemanticCacheClient(client, fakeWordEmbedder{}, cache.Config{TTL: 100 * time.Millisecond})
+
+		cached.ReplyMessage(ctx, ask("", "password"))
+		time.Sleep(60 * time.Millisecond)
+		cached.ReplyMessage(ctx, ask("", strings.Repeat("password ", 7)+strings.Repeat("reset ", 4)))
+		time.Sleep(60 * time.Millisecond)
+
+		// closer to the expired first entry, yet close enough to the fresh second one
+		reply, _ := cached.ReplyMessage(ctx, ask("", strings.Repeat("password ", 4)+"reset"))
+		if client.calls != 2 || reply != "answer 2" {
+			t.Fatalf("expected the fresh second reply, got %s after %d calls", reply, client.calls)
+		}
+	})
+
+	t.Run("Images bypass", func(t *testing.T) {
+		client := &fakeCountingClient{}
+		cached := cache.NewSemanticCacheClient(client, fakeWordEmbedder{}, cache.Config{})
+		messages := []llm.LlmMessage{{Role: llm.RoleUser, Content: "reset password", B64Image: "aGk="}}
+
+		cached.ReplyMessage(ctx, messages)
+		cached.ReplyMessage(ctx, messages)
+		if client.calls != 2 || cached.Stats().Bypassed != 2 {
+			t.Fatalf("expected 2 bypassed calls, got %d calls and %+v", client.calls, cached.Stats())
+		}
+	})
+}
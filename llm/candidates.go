@@ -0,0 +1,98 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sieglu2/go_foundation/foundation"
+)
+
+type LlmCandidate struct {
+	Content      string `json:"content"`
+	FinishReason string `json:"finish_reason"`
+}
+
+// CandidatesClient is implemented by the clients whose provider returns several
+// alternative replies to a single request.
+type CandidatesClient interface {
+	ReplyCandidates(ctx context.Context, messages []LlmMessage, n int) (*LlmReply, error)
+}
+
+var (
+	_ CandidatesClient = (*ChatGptClient)(nil)
+	_ CandidatesClient = (*GeminiClient)(nil)
+)
+
+// ReplyCandidates asks client for n alternative replies, returned in the Candidates of the
+// reply. Clients implementing CandidatesClient are asked in one request, the others are
+// called n times in parallel, the usage being the sum of the calls. The failed parallel
+// calls are dropped, ReplyCandidates fails only if all of them fail.
+func ReplyCandidates(ctx context.Context, client LlmClient, messages []LlmMessage, n int) (*LlmReply, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("candidate count must be positive")
+	}
+	if candidatesClient, ok := client.(CandidatesClient); ok {
+		return candidatesClient.ReplyCandidates(ctx, messages, n)
+	}
+	return replyCandidatesInParallel(ctx, client, messages, n)
+}
+
+// replyCandidatesInParallel calls client n times in parallel, for the clients whose
+// provider cannot return several replies to the request.
+func replyCandidatesInParallel(ctx context.Context, client LlmClient, messages []LlmMessage, n int) (*LlmReply, error) {
+	logger := foundation.Logger()
+
+	calls := make(chan any, n)
+	for i := 0; i < n; i++ {
+		calls <- i
+	}
+	close(calls)
+
+	// every call writes its own index, the replies need no lock
+	replies := make([]*LlmReply, n)
+	errs := make([]error, n)
+	foundation.RunInParallel(n, 0, calls, func(a any) error {
+		replies[a.(int)], errs[a.(int)] = ReplyMessageDetail(ctx, client, messages)
+		return nil
+	}, func([]error) error {
+		return nil
+	})
+
+	var failed []error
+	for _, err := range errs {
+		if err != nil {
+			failed = append(failed, err)
+		}
+	}
+	if len(failed) == n {
+		logger.Errorf("failed to get any of %d candidates: %v", n, failed[0])
+		return nil, fmt.Errorf("failed to get any of %d candidates: %w", n, failed[0])
+	}
+	if len(failed) > 0 {
+		logger.Warnf("dropped %d failed candidates of %d: %v", len(failed), n, failed[0])
+	}
+
+	var merged *LlmReply
+	for _, reply := range replies {
+		if reply == nil {
+			continue
+		}
+		if merged == nil {
+			copied := *reply
+			merged = &copied
+		} else {
+			merged.Usage.PromptTokens += reply.Usage.PromptTokens
+			merged.Usage.CompletionTokens += reply.Usage.CompletionTokens
+			merged.Usage.TotalTokens += reply.Usage.TotalTokens
+			// the latest state wins, they come from the same account
+			if reply.RateLimit != nil {
+				merged.RateLimit = reply.RateLimit
+			}
+		}
+		merged.Candidates = append(merged.Candidates, LlmCandidate{
+			Content:      reply.Content,
+			FinishReason: reply.FinishReason,
+		})
+	}
+	return merged, nil
+}
@@ -0,0 +1,98 @@
+package llm_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/sieglu2/go_foundation/llm"
+)
+
+// newFakeCountingClient numbers its replies, failing the calls listed in fail.
+func newFakeCountingClient(fail ...int) *fakeClient {
+	return &fakeClient{reply: func(call int, messages []llm.LlmMessage) (*llm.LlmReply, error) {
+		if slices.Contains(fail, call) {
+			return nil, fmt.Errorf("call %d failed", call)
+		}
+		return &llm.LlmReply{
+			Content:      fmt.Sprintf("reply %d", call),
+			FinishReason: "stop",
+			Usage:        llm.LlmUsage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15},
+		}, nil
+	}}
+}
+
+// fakeNativeCandidatesClient returns n candidates from a single call.
+type fakeNativeCandidatesClient struct {
+	fakeClient
+}
+
+func (f *fakeNativeCandidatesClient) ReplyCandidates(ctx context.Context, messages []llm.LlmMessage, n int) (*llm.LlmReply, error) {
+	f.record(messages)
+	reply := &llm.LlmReply{Content: "native"}
+	for i := 0; i < n; i++ {
+		reply.Candidates = append(reply.Candidates, llm.LlmCandidate{Content: "native", FinishReason: "length"})
+	}
+	return reply, nil
+}
+
+func TestReplyCandidates(t *testing.T) {
+	ctx := context.Background()
+	messages := []llm.LlmMessage{{Role: llm.RoleUser, Content: "hi"}}
+
+	t.Run("Emulated with parallel calls", func(t *testing.T) {
+		client := newFakeCountingClient()
+		reply, err := llm.ReplyCandidates(ctx, client, messages, 3)
+		if err != nil {
+			t.Fatalf("ReplyCandidates failed: %v", err)
+		}
+		if len(reply.Candidates) != 3 || reply.Candidates[0].Content != reply.Content || reply.Candidates[2].FinishReason != "stop" {
+			t.Fatalf("unexpected candidates: %+v", reply)
+		}
+		if reply.Usage.TotalTokens != 45 {
+			t.Fatalf("expected the usage of the 3 calls, got %+v", reply.Usage)
+		}
+	})
+
+	t.Run("Failed calls are dropped", func(t *testing.T) {
+		client := newFakeCountingClient(1)
+		reply, err := llm.ReplyCandidates(ctx, client, messages, 2)
+		if err != nil || len(reply.Candidates) != 1 {
+			t.Fatalf("expected 1 candidate, got %+v: %v", reply, err)
+		}
+
+		client = newFakeCountingClient(1, 2)
+		if _, err := llm.ReplyCandidates(ctx, client, messages, 2); err == nil {
+			t.Fatalf("expected an error when every call fails")
+		}
+	})
+
+	t.Run("Native candidates in one call", func(t *testing.T) {
+		client := &fakeNativeCandidatesClient{}
+		reply, err := llm.ReplyCandidates(ctx, client, messages, 4)
+		if err != nil || len(reply.Candidates) != 4 || client.Calls() != 1 {
+			t.Fatalf("expected 4 candidates from 1 call, got %+v after %d calls: %v", reply, client.Calls(), err)
+		}
+	})
+
+	t.Run("Single native candidate filled", func(t *testing.T) {
+		var last *http.Request
+		server := newFakeOpenAIServer(t, &last)
+		client, err := llm.NewChatGptClientWithApiConfig(llm.ChatGptConfig{BaseURL: server.URL}, "key", 100, "gpt-4o")
+		if err != nil {
+			t.Fatalf("NewChatGptClientWithApiConfig failed: %v", err)
+		}
+		reply, err := llm.ReplyCandidates(ctx, client, messages, 1)
+		if err != nil || len(reply.Candidates) != 1 || reply.Candidates[0].Content != "ok" {
+			t.Fatalf("expected the single candidate ok, got %+v: %v", reply, err)
+		}
+	})
+
+	t.Run("Invalid count", func(t *testing.T) {
+		if _, err := llm.ReplyCandidates(ctx, newFakeCountingClient(), messages, 0); err == nil {
+			t.Fatalf("expected an error for 0 candidates")
+		}
+	})
+}
@@ -8,27 +8,26 @@ import (
 	"github.com/sieglu2/go_foundation/foundation"
 )
 
-const (
-	chatgptSecretAccountName string = "my_openai"
-	chatgptSecretServiceName string = "chatgpt"
-)
+const defaultChatGptModel = "gpt-4-turbo"
 
 type ChatGptClient struct {
-	client    *openai.Client
-	maxTokens int
-	model     string
+	client         *openai.Client
+	maxTokens      int
+	model          string
+	embeddingModel string
 }
 
 func NewChatGptClient(apiKey string) *ChatGptClient {
-	return NewChatGptClientWithConfig(apiKey, DefaultMaxTokens, "gpt-4-turbo")
+	return NewChatGptClientWithConfig(apiKey, DefaultMaxTokens, defaultChatGptModel)
 }
 
 func NewChatGptClientWithConfig(apiKey string, maxTokens int, model string) *ChatGptClient {
 	openaiClient := openai.NewClient(apiKey)
 	return &ChatGptClient{
-		client:    openaiClient,
-		maxTokens: maxTokens,
-		model:     model,
+		client:         openaiClient,
+		maxTokens:      maxTokens,
+		model:          model,
+		embeddingModel: defaultChatGptEmbeddingModel,
 	}
 }
 
@@ -70,7 +69,7 @@ func convertToChatGptMessages(chatGptMessages []LlmMessage) ([]openai.ChatComple
 				{
 					Type: openai.ChatMessagePartTypeImageURL,
 					ImageURL: &openai.ChatMessageImageURL{
-						URL: fmt.Sprintf("data:image/png;base64,%s", chatGptMessage.B64Image),
+						URL: fmt.Sprintf("data:%s;base64,%s", chatGptMessage.imageMediaType(), chatGptMessage.B64Image),
 					},
 				},
 			}
@@ -86,32 +85,91 @@ func convertToChatGptMessages(chatGptMessages []LlmMessage) ([]openai.ChatComple
 func (t *ChatGptClient) ReplyMessage(
 	ctx context.Context, llmMessages []LlmMessage,
 ) (string, error) {
-	logger := foundation.Logger()
-
-	messages, err := convertToChatGptMessages(llmMessages)
+	reply, err := t.ReplyMessageDetail(ctx, llmMessages)
 	if err != nil {
-		logger.Errorf("failed to convertToChatGptMessages: %v", err)
-		return "", fmt.Errorf("failed to convertToChatGptMessages: %v", err)
+		return "", err
 	}
+	return reply.Content, nil
+}
 
-	request := openai.ChatCompletionRequest{
-		Model:     t.model,
-		MaxTokens: t.maxTokens,
-		Messages:  messages,
+func (t *ChatGptClient) ReplyMessageDetail(
+	ctx context.Context, llmMessages []LlmMessage,
+) (*LlmReply, error) {
+	return t.createChatCompletion(ctx, llmMessages, 1)
+}
+
+// ReplyCandidates asks ChatGpt for n alternative replies in a single request.
+func (t *ChatGptClient) ReplyCandidates(
+	ctx context.Context, llmMessages []LlmMessage, n int,
+) (*LlmReply, error) {
+	return t.createChatCompletion(ctx, llmMessages, n)
+}
+
+func (t *ChatGptClient) createChatCompletion(
+	ctx context.Context, llmMessages []LlmMessage, n int,
+) (*LlmReply, error) {
+	logger := foundation.Logger()
+
+	request, err := t.newChatCompletionRequest(llmMessages, n)
+	if err != nil {
+		return nil, err
 	}
 
 	logger.Infof("sending request %+v to ChatGpt", request)
 	resp, err := t.client.CreateChatCompletion(ctx, request)
 	if err != nil {
 		logger.Errorf("failed to CreateChatCompletion: %v", err)
-		return "", fmt.Errorf("failed to CreateChatCompletion: %v", err)
+		return nil, fmt.Errorf("failed to CreateChatCompletion: %w", err)
 	}
 
 	if len(resp.Choices) == 0 {
 		logger.Errorf("empty resp.Choices")
-		return "", fmt.Errorf("empty resp.Choices")
+		return nil, fmt.Errorf("empty resp.Choices")
 	}
 
 	logger.Infof("receive ChatGpt response.")
-	return resp.Choices[0].Message.Content, nil
+	reply := t.convertChatCompletionResponse(&resp)
+	reply.RateLimit = parseRateLimitHeaders(resp.Header())
+	return reply, nil
+}
+
+func (t *ChatGptClient) newChatCompletionRequest(llmMessages []LlmMessage, n int) (openai.ChatCompletionRequest, error) {
+	logger := foundation.Logger()
+
+	messages, err := convertToChatGptMessages(llmMessages)
+	if err != nil {
+		logger.Errorf("failed to convertToChatGptMessages: %v", err)
+		return openai.ChatCompletionRequest{}, fmt.Errorf("failed to convertToChatGptMessages: %v", err)
+	}
+
+	request := openai.ChatCompletionRequest{
+		Model:     t.model,
+		MaxTokens: t.maxTokens,
+		Messages:  messages,
+	}
+	if n > 1 {
+		request.N = n
+	}
+	return request, nil
+}
+
+// convertChatCompletionResponse converts a response holding at least one choice.
+func (t *ChatGptClient) convertChatCompletionResponse(resp *openai.ChatCompletionResponse) *LlmReply {
+	reply := &LlmReply{
+		Content:      resp.Choices[0].Message.Content,
+		Model:        t.model,
+		FinishReason: string(resp.Choices[0].FinishReason),
+		Usage: LlmUsage{
+			PromptTokens:     resp.Usage.PromptTokens,
+			CompletionTokens: resp.Usage.CompletionTokens,
+			TotalTokens:      resp.Usage.TotalTokens,
+		},
+	}
+	for _, choice := range resp.Choices {
+		reply.Candidates = append(reply.Candidates, LlmCandidate{
+			Content:      choice.Message.Content,
+			FinishReason: string(choice.FinishReason),
+		})
+	}
+	return reply
 }
@@ -0,0 +1,160 @@
+package llm
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/sieglu2/go_foundation/foundation"
+)
+
+const chatGptBatchWindow = "24h"
+
+// chatGptBatchLine is a line of the output and error files of a batch.
+type chatGptBatchLine struct {
+	CustomID string `json:"custom_id"`
+	Response *struct {
+		StatusCode int                           `json:"status_code"`
+		Body       openai.ChatCompletionResponse `json:"body"`
+	} `json:"response"`
+	Error *struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// SubmitBatch uploads the requests as a JSONL file and starts a batch of it.
+func (t *ChatGptClient) SubmitBatch(ctx context.Context, requests []BatchRequest) (string, error) {
+	logger := foundation.Logger()
+
+	if err := validateBatchRequests(requests); err != nil {
+		logger.Errorf("invalid batch: %v", err)
+		return "", err
+	}
+
+	upload := openai.CreateBatchWithUploadFileRequest{
+		Endpoint:         openai.BatchEndpointChatCompletions,
+		CompletionWindow: chatGptBatchWindow,
+	}
+	upload.FileName = "batch.jsonl"
+	for _, request := range requests {
+		chatRequest, err := t.newChatCompletionRequest(request.Messages, 1)
+		if err != nil {
+			return "", fmt.Errorf("failed to convert batch request %s: %v", request.ID, err)
+		}
+		upload.AddChatCompletion(request.ID, chatRequest)
+	}
+
+	logger.Infof("submitting batch of %d requests to ChatGpt", len(requests))
+	resp, err := t.client.CreateBatchWithUploadFile(ctx, upload)
+	if err != nil {
+		logger.Errorf("failed to CreateBatchWithUploadFile: %v", err)
+		return "", fmt.Errorf("failed to CreateBatchWithUploadFile: %w", err)
+	}
+	return resp.ID, nil
+}
+
+func (t *ChatGptClient) GetBatchStatus(ctx context.Context, batchID string) (*BatchStatus, error) {
+	logger := foundation.Logger()
+
+	resp, err := t.client.RetrieveBatch(ctx, batchID)
+	if err != nil {
+		logger.Errorf("failed to RetrieveBatch %s: %v", batchID, err)
+		return nil, fmt.Errorf("failed to RetrieveBatch %s: %w", batchID, err)
+	}
+
+	status := &BatchStatus{
+		ProviderStatus: resp.Status,
+		Total:          resp.RequestCounts.Total,
+		Succeeded:      resp.RequestCounts.Completed,
+		Failed:         resp.RequestCounts.Failed,
+	}
+	switch resp.Status {
+	case "completed", "expired", "cancelled":
+		status.State = BatchEnded
+	case "failed":
+		status.State = BatchFailed
+		if resp.Errors != nil {
+			var messages []string
+			for _, batchErr := range resp.Errors.Data {
+				messages = append(messages, fmt.Sprintf("%s: %s", batchErr.Code, batchErr.Message))
+			}
+			status.Error = strings.Join(messages, "; ")
+		}
+	default:
+		status.State = BatchInProgress
+	}
+	return status, nil
+}
+
+// GetBatchResults reads the output file and the error file of the batch.
+func (t *ChatGptClient) GetBatchResults(ctx context.Context, batchID string) ([]BatchResult, error) {
+	logger := foundation.Logger()
+
+	resp, err := t.client.RetrieveBatch(ctx, batchID)
+	if err != nil {
+		logger.Errorf("failed to RetrieveBatch %s: %v", batchID, err)
+		return nil, fmt.Errorf("failed to RetrieveBatch %s: %w", batchID, err)
+	}
+
+	var results []BatchResult
+	for _, fileID := range []*string{resp.OutputFileID, resp.ErrorFileID} {
+		if fileID == nil || *fileID == "" {
+			continue
+		}
+		fileResults, err := t.readBatchFile(ctx, *fileID)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, fileResults...)
+	}
+	return results, nil
+}
+
+func (t *ChatGptClient) readBatchFile(ctx context.Context, fileID string) ([]BatchResult, error) {
+	logger := foundation.Logger()
+
+	content, err := t.client.GetFileContent(ctx, fileID)
+	if err != nil {
+		logger.Errorf("failed to GetFileContent %s: %v", fileID, err)
+		return nil, fmt.Errorf("failed to GetFileContent %s: %w", fileID, err)
+	}
+	defer content.Close()
+
+	var results []BatchResult
+	scanner := bufio.NewScanner(content)
+	scanner.Buffer(make([]byte, 1024*1024), 64*1024*1024)
+	for scanner.Scan() {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var line chatGptBatchLine
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			logger.Errorf("failed to Unmarshal batch line: %v", err)
+			return nil, fmt.Errorf("failed to Unmarshal batch line: %v", err)
+		}
+
+		result := BatchResult{ID: line.CustomID}
+		switch {
+		case line.Error != nil:
+			result.Error = fmt.Sprintf("%s: %s", line.Error.Code, line.Error.Message)
+		case line.Response == nil:
+			result.Error = "no response"
+		case line.Response.StatusCode != 200:
+			result.Error = fmt.Sprintf("status code %d", line.Response.StatusCode)
+		case len(line.Response.Body.Choices) == 0:
+			result.Error = "empty choices"
+		default:
+			result.Reply = t.convertChatCompletionResponse(&line.Response.Body)
+		}
+		results = append(results, result)
+	}
+	if err := scanner.Err(); err != nil {
+		logger.Errorf("failed to read batch file %s: %v", fileID, err)
+		return nil, fmt.Errorf("failed to read batch file %s: %v", fileID, err)
+	}
+	return results, nil
+}
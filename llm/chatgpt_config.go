@@ -0,0 +1,180 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/sieglu2/go_foundation/foundation"
+)
+
+type ChatGptApiType string
+
+const (
+	// ChatGptApiOpenAI is OpenAI or a gateway speaking its api, the key sent as a bearer token.
+	ChatGptApiOpenAI ChatGptApiType = "openai"
+	// ChatGptApiAzure is an Azure OpenAI resource, the key sent in the api-key header.
+	ChatGptApiAzure ChatGptApiType = "azure"
+	// ChatGptApiAzureAD is an Azure OpenAI resource authenticated by an Azure AD token.
+	ChatGptApiAzureAD ChatGptApiType = "azure_ad"
+
+	DefaultAzureApiVersion = "2024-06-01"
+)
+
+// ChatGptConfig selects the OpenAI compatible endpoint a ChatGptClient talks to.
+type ChatGptConfig struct {
+	// ApiType is ChatGptApiOpenAI if empty.
+	ApiType ChatGptApiType
+	// BaseURL replaces https://api.openai.com/v1, e.g. with a gateway. It is required for
+	// Azure, as the https://<resource>.openai.azure.com endpoint.
+	BaseURL string
+	// ApiVersion is the Azure api-version, DefaultAzureApiVersion if empty.
+	ApiVersion string
+	// Deployments maps the models to their Azure deployment names. The models missing use
+	// their name without dots and colons, e.g. gpt-35-turbo for gpt-3.5-turbo.
+	Deployments map[string]string
+	// AzureADToken returns the Azure AD token of every request, so expiring tokens are
+	// refreshed. The api key is used as a static token if nil.
+	AzureADToken func(ctx context.Context) (string, error)
+	// Headers are added to every request, e.g. to route through a gateway.
+	Headers map[string]string
+}
+
+// ChatGptConfigFromEnv reads OPENAI_API_TYPE (openai, azure or azure_ad), OPENAI_BASE_URL,
+// OPENAI_API_VERSION and OPENAI_DEPLOYMENTS, a comma separated list of model=deployment.
+func ChatGptConfigFromEnv() ChatGptConfig {
+	config := ChatGptConfig{
+		ApiType:    ChatGptApiType(strings.ToLower(strings.TrimSpace(os.Getenv("OPENAI_API_TYPE")))),
+		BaseURL:    strings.TrimSpace(os.Getenv("OPENAI_BASE_URL")),
+		ApiVersion: strings.TrimSpace(os.Getenv("OPENAI_API_VERSION")),
+	}
+	for _, pair := range strings.Split(os.Getenv("OPENAI_DEPLOYMENTS"), ",") {
+		model, deployment, ok := strings.Cut(pair, "=")
+		if !ok {
+			continue
+		}
+		if config.Deployments == nil {
+			config.Deployments = map[string]string{}
+		}
+		config.Deployments[strings.TrimSpace(model)] = strings.TrimSpace(deployment)
+	}
+	return config
+}
+
+var (
+	chatGptConfigMu sync.RWMutex
+	chatGptConfig   *ChatGptConfig
+)
+
+// SetChatGptConfig sets the endpoint of the ChatGpt clients created by NewLlmClient and
+// NewLlmClientForProvider. nil restores ChatGptConfigFromEnv.
+func SetChatGptConfig(config *ChatGptConfig) {
+	chatGptConfigMu.Lock()
+	defer chatGptConfigMu.Unlock()
+	chatGptConfig = config
+}
+
+func getChatGptConfig() ChatGptConfig {
+	chatGptConfigMu.RLock()
+	defer chatGptConfigMu.RUnlock()
+
+	if chatGptConfig == nil {
+		return ChatGptConfigFromEnv()
+	}
+	return *chatGptConfig
+}
+
+// NewChatGptClientWithApiConfig creates a client of the endpoint described by config.
+func NewChatGptClientWithApiConfig(config ChatGptConfig, apiKey string, maxTokens int, model string) (*ChatGptClient, error) {
+	logger := foundation.Logger()
+
+	clientConfig, err := config.clientConfig(apiKey)
+	if err != nil {
+		logger.Errorf("invalid ChatGpt config: %v", err)
+		return nil, fmt.Errorf("invalid ChatGpt config: %v", err)
+	}
+	return &ChatGptClient{
+		client:         openai.NewClientWithConfig(clientConfig),
+		maxTokens:      maxTokens,
+		model:          model,
+		embeddingModel: defaultChatGptEmbeddingModel,
+	}, nil
+}
+
+func (c ChatGptConfig) clientConfig(apiKey string) (openai.ClientConfig, error) {
+	var clientConfig openai.ClientConfig
+	switch c.ApiType {
+	case "", ChatGptApiOpenAI:
+		clientConfig = openai.DefaultConfig(apiKey)
+		if c.BaseURL != "" {
+			clientConfig.BaseURL = strings.TrimRight(c.BaseURL, "/")
+		}
+
+	case ChatGptApiAzure, ChatGptApiAzureAD:
+		if c.BaseURL == "" {
+			return clientConfig, fmt.Errorf("azure needs the base url of the resource")
+		}
+		clientConfig = openai.DefaultAzureConfig(apiKey, strings.TrimRight(c.BaseURL, "/"))
+		if c.ApiType == ChatGptApiAzureAD {
+			clientConfig.APIType = openai.APITypeAzureAD
+		}
+		clientConfig.APIVersion = DefaultAzureApiVersion
+		if c.ApiVersion != "" {
+			clientConfig.APIVersion = c.ApiVersion
+		}
+		defaultMapper := clientConfig.AzureModelMapperFunc
+		clientConfig.AzureModelMapperFunc = func(model string) string {
+			if deployment, ok := c.Deployments[model]; ok {
+				return deployment
+			}
+			return defaultMapper(model)
+		}
+
+	default:
+		return clientConfig, fmt.Errorf("unknown api type: %s", c.ApiType)
+	}
+
+	if len(c.Headers) > 0 || (c.ApiType == ChatGptApiAzureAD && c.AzureADToken != nil) {
+		transport := &chatGptTransport{base: http.DefaultTransport, headers: c.Headers}
+		if c.ApiType == ChatGptApiAzureAD {
+			transport.token = c.AzureADToken
+		}
+		clientConfig.HTTPClient = &http.Client{Transport: transport}
+	}
+	return clientConfig, nil
+}
+
+// chatGptTransport adds the configured headers and the fresh Azure AD token to the requests.
+type chatGptTransport struct {
+	base    http.RoundTripper
+	headers map[string]string
+	token   func(ctx context.Context) (string, error)
+}
+
+func (t *chatGptTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	for name, value := range t.headers {
+		req.Header.Set(name, value)
+	}
+	if t.token != nil {
+		token, err := t.token(req.Context())
+		if err != nil {
+			return nil, fmt.Errorf("failed to get Azure AD token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return t.base.RoundTrip(req)
+}
+
+// newChatGptClient creates the ChatGpt client of NewLlmClient and NewLlmClientWithApiKey,
+// using the model's default when model is empty.
+func newChatGptClient(apiKey string, maxTokens int, model string) (*ChatGptClient, error) {
+	if model == "" {
+		model = defaultChatGptModel
+	}
+	return NewChatGptClientWithApiConfig(getChatGptConfig(), apiKey, maxTokens, model)
+}
@@ -0,0 +1,102 @@
+package llm_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sieglu2/go_foundation/llm"
+)
+
+// newFakeOpenAIServer answers every chat completion with "ok" and keeps the last request.
+func newFakeOpenAIServer(t *testing.T, last **http.Request) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*last = r
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "ok"}, "finish_reason": "stop"}], "usage": {"total_tokens": 3}}`))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestChatGptConfig(t *testing.T) {
+	ctx := context.Background()
+	messages := []llm.LlmMessage{{Role: llm.RoleUser, Content: "hi"}}
+
+	t.Run("Azure deployment and api key", func(t *testing.T) {
+		var last *http.Request
+		server := newFakeOpenAIServer(t, &last)
+
+		client, err := llm.NewChatGptClientWithApiConfig(llm.ChatGptConfig{
+			ApiType:     llm.ChatGptApiAzure,
+			BaseURL:     server.URL,
+			Deployments: map[string]string{"gpt-4o": "prod-4o"},
+		}, "azure-key", 100, "gpt-4o")
+		if err != nil {
+			t.Fatalf("NewChatGptClientWithApiConfig failed: %v", err)
+		}
+		if _, err := client.ReplyMessage(ctx, messages); err != nil {
+			t.Fatalf("ReplyMessage failed: %v", err)
+		}
+
+		if last.URL.Path != "/openai/deployments/prod-4o/chat/completions" || last.URL.Query().Get("api-version") != llm.DefaultAzureApiVersion {
+			t.Fatalf("unexpected url: %s", last.URL)
+		}
+		if last.Header.Get("api-key") != "azure-key" {
+			t.Fatalf("expected the api-key header, got %v", last.Header)
+		}
+	})
+
+	t.Run("Azure AD token refreshed per request", func(t *testing.T) {
+		var last *http.Request
+		server := newFakeOpenAIServer(t, &last)
+
+		client, err := llm.NewChatGptClientWithApiConfig(llm.ChatGptConfig{
+			ApiType: llm.ChatGptApiAzureAD,
+			BaseURL: server.URL,
+			AzureADToken: func(ctx context.Context) (string, error) {
+				return "fresh-token", nil
+			},
+		}, "", 100, "gpt-3.5-turbo")
+		if err != nil {
+			t.Fatalf("NewChatGptClientWithApiConfig failed: %v", err)
+		}
+		if _, err := client.ReplyMessage(ctx, messages); err != nil {
+			t.Fatalf("ReplyMessage failed: %v", err)
+		}
+
+		if last.URL.Path != "/openai/deployments/gpt-35-turbo/chat/completions" {
+			t.Fatalf("unexpected url: %s", last.URL)
+		}
+		if last.Header.Get("Authorization") != "Bearer fresh-token" {
+			t.Fatalf("expected the Azure AD token, got %v", last.Header)
+		}
+	})
+
+	t.Run("Gateway through NewLlmClientWithApiKey", func(t *testing.T) {
+		var last *http.Request
+		server := newFakeOpenAIServer(t, &last)
+
+		llm.SetChatGptConfig(&llm.ChatGptConfig{BaseURL: server.URL + "/v1/", Headers: map[string]string{"X-Team": "search"}})
+		defer llm.SetChatGptConfig(nil)
+
+		client, err := llm.NewLlmClientWithApiKey(ctx, llm.ProviderChatGpt, "gateway-key", "")
+		if err != nil {
+			t.Fatalf("NewLlmClientWithApiKey failed: %v", err)
+		}
+		if _, err := client.ReplyMessage(ctx, messages); err != nil {
+			t.Fatalf("ReplyMessage failed: %v", err)
+		}
+
+		if last.URL.Path != "/v1/chat/completions" || last.Header.Get("X-Team") != "search" || last.Header.Get("Authorization") != "Bearer gateway-key" {
+			t.Fatalf("unexpected request: %s %v", last.URL, last.Header)
+		}
+	})
+
+	t.Run("Azure needs a base url", func(t *testing.T) {
+		if _, err := llm.NewChatGptClientWithApiConfig(llm.ChatGptConfig{ApiType: llm.ChatGptApiAzure}, "key", 100, "gpt-4o"); err == nil {
+			t.Fatalf("expected an error without base url")
+		}
+	})
+}
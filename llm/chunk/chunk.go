@@ -0,0 +1,213 @@
+// Package chunk splits long documents into chunks sized in tokens, breaking at paragraphs,
+// then lines, sentences and words, and keeping markdown sections and code blocks together.
+package chunk
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const DefaultChunkTokens = 500
+
+type Config struct {
+	// ChunkTokens is the most tokens of a chunk, DefaultChunkTokens if 0.
+	ChunkTokens int
+	// OverlapTokens is how many tokens of the end of a chunk are repeated at the start of
+	// the next one, at most half a chunk.
+	OverlapTokens int
+	// Counter counts the tokens, EstimateTokens if nil.
+	Counter TokenCounter
+}
+
+type Chunk struct {
+	Index int
+	Text  string
+	// Start and End are the byte offsets of Text in the document.
+	Start  int
+	End    int
+	Tokens int
+	// Headings are the markdown headings the chunk is under, outermost first.
+	Headings []string
+}
+
+// Metadata describes the chunk as metadata values, e.g. for a vector store document.
+func (c Chunk) Metadata() map[string]string {
+	metadata := map[string]string{
+		"chunk": strconv.Itoa(c.Index),
+		"start": strconv.Itoa(c.Start),
+		"end":   strconv.Itoa(c.End),
+	}
+	if len(c.Headings) > 0 {
+		metadata["headings"] = strings.Join(c.Headings, " > ")
+	}
+	return metadata
+}
+
+type Splitter interface {
+	Split(text string) []Chunk
+}
+
+// separators are where a text too long is broken, tried in order: paragraphs, lines,
+// sentences and words.
+var separators = []*regexp.Regexp{
+	regexp.MustCompile(`\n[ \t]*\n\s*`),
+	regexp.MustCompile(`\n`),
+	regexp.MustCompile(`[.!?。！？]+["'”’)\]]*\s+|[。！？]`),
+	regexp.MustCompile(`\s+`),
+}
+
+// segment is a span of the document never broken further, section being the index of the
+// markdown section holding it.
+type segment struct {
+	start   int
+	end     int
+	tokens  int
+	section int
+}
+
+type splitter struct {
+	chunkTokens   int
+	overlapTokens int
+	count         TokenCounter
+}
+
+func newSplitter(config Config) splitter {
+	s := splitter{
+		chunkTokens:   config.ChunkTokens,
+		overlapTokens: config.OverlapTokens,
+		count:         config.Counter,
+	}
+	if s.chunkTokens <= 0 {
+		s.chunkTokens = DefaultChunkTokens
+	}
+	s.overlapTokens = max(0, min(s.overlapTokens, s.chunkTokens/2))
+	if s.count == nil {
+		s.count = EstimateTokens
+	}
+	return s
+}
+
+// TextSplitter splits plain text.
+type TextSplitter struct {
+	splitter
+}
+
+func NewTextSplitter(config Config) *TextSplitter {
+	return &TextSplitter{splitter: newSplitter(config)}
+}
+
+func (t *TextSplitter) Split(text string) []Chunk {
+	segments := t.segment(text, 0, len(text), 0, 0)
+	return t.merge(text, segments, [][]string{nil})
+}
+
+// segment breaks text[start:end] at the separators from level on until every segment
+// fits in a chunk, halving what no separator breaks.
+func (s *splitter) segment(text string, start int, end int, level int, section int) []segment {
+	tokens := s.count(text[start:end])
+	if tokens <= s.chunkTokens {
+		return []segment{{start: start, end: end, tokens: tokens, section: section}}
+	}
+
+	var pieces [][2]int
+	if level < len(separators) {
+		pieces = splitAfter(text, start, end, separators[level])
+		if len(pieces) == 1 {
+			return s.segment(text, start, end, level+1, section)
+		}
+	} else {
+		middle := start + (end-start)/2
+		for middle > start && !utf8.RuneStart(text[middle]) {
+			middle--
+		}
+		if middle == start {
+			return []segment{{start: start, end: end, tokens: tokens, section: section}}
+		}
+		pieces = [][2]int{{start, middle}, {middle, end}}
+		level--
+	}
+
+	var segments []segment
+	for _, piece := range pieces {
+		segments = append(segments, s.segment(text, piece[0], piece[1], level+1, section)...)
+	}
+	return segments
+}
+
+// splitAfter breaks text[start:end] after every match of separator.
+func splitAfter(text string, start int, end int, separator *regexp.Regexp) [][2]int {
+	var pieces [][2]int
+	from := start
+	for _, match := range separator.FindAllStringIndex(text[start:end], -1) {
+		if cut := start + match[1]; cut > from && cut < end {
+			pieces = append(pieces, [2]int{from, cut})
+			from = cut
+		}
+	}
+	return append(pieces, [2]int{from, end})
+}
+
+// merge packs consecutive segments of a section into chunks, starting each chunk with the
+// overlap of the previous one.
+func (s *splitter) merge(text string, segments []segment, headings [][]string) []Chunk {
+	var chunks []Chunk
+	var current []segment
+	tokens := 0
+
+	flush := func() {
+		start, end := current[0].start, current[len(current)-1].end
+		for start < end {
+			c, size := utf8.DecodeRuneInString(text[start:end])
+			if !unicode.IsSpace(c) {
+				break
+			}
+			start += size
+		}
+		for end > start {
+			c, size := utf8.DecodeLastRuneInString(text[start:end])
+			if !unicode.IsSpace(c) {
+				break
+			}
+			end -= size
+		}
+		if start == end {
+			return
+		}
+		chunks = append(chunks, Chunk{
+			Index:    len(chunks),
+			Text:     text[start:end],
+			Start:    start,
+			End:      end,
+			Tokens:   s.count(text[start:end]),
+			Headings: headings[current[0].section],
+		})
+	}
+
+	for _, next := range segments {
+		if len(current) > 0 && (next.section != current[0].section || tokens+next.tokens > s.chunkTokens) {
+			flush()
+
+			var overlap []segment
+			tokens = 0
+			if next.section == current[0].section {
+				for i := len(current) - 1; i > 0; i-- {
+					if tokens+current[i].tokens > s.overlapTokens || tokens+current[i].tokens+next.tokens > s.chunkTokens {
+						break
+					}
+					tokens += current[i].tokens
+					overlap = append([]segment{current[i]}, overlap...)
+				}
+			}
+			current = overlap
+		}
+		current = append(current, next)
+		tokens += next.tokens
+	}
+	if len(current) > 0 {
+		flush()
+	}
+	return chunks
+}
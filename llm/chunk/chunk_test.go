@@ -0,0 +1,104 @@
+package chunk_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sieglu2/go_foundation/llm"
+	"github.com/sieglu2/go_foundation/llm/chunk"
+)
+
+// words counts the words, so the sizes in the tests are easy to follow.
+func words(text string) int {
+	return len(strings.Fields(text))
+}
+
+func texts(chunks []chunk.Chunk) []string {
+	var result []string
+	for _, c := range chunks {
+		result = append(result, c.Text)
+	}
+	return result
+}
+
+func TestTextSplitter(t *testing.T) {
+	t.Run("Paragraphs then sentences", func(t *testing.T) {
+		text := "one two three.\n\nfour five. six seven. eight nine ten eleven."
+		splitter := chunk.NewTextSplitter(chunk.Config{ChunkTokens: 4, Counter: words})
+
+		chunks := splitter.Split(text)
+		expected := []string{"one two three.", "four five. six seven.", "eight nine ten eleven."}
+		if strings.Join(texts(chunks), "|") != strings.Join(expected, "|") {
+			t.Fatalf("expected %q, got %q", expected, texts(chunks))
+		}
+		for _, c := range chunks {
+			if text[c.Start:c.End] != c.Text || c.Tokens > 4 {
+				t.Fatalf("unexpected chunk: %+v", c)
+			}
+		}
+	})
+
+	t.Run("Overlap", func(t *testing.T) {
+		text := "a b. c d. e f. g h."
+		splitter := chunk.NewTextSplitter(chunk.Config{ChunkTokens: 4, OverlapTokens: 2, Counter: words})
+
+		expected := []string{"a b. c d.", "c d. e f.", "e f. g h."}
+		if got := texts(splitter.Split(text)); strings.Join(got, "|") != strings.Join(expected, "|") {
+			t.Fatalf("expected %q, got %q", expected, got)
+		}
+	})
+
+	t.Run("Words longer than a chunk are halved", func(t *testing.T) {
+		splitter := chunk.NewTextSplitter(chunk.Config{ChunkTokens: 2})
+		chunks := splitter.Split("abcdefgh日本")
+		if strings.Join(texts(chunks), "") != "abcdefgh日本" {
+			t.Fatalf("the chunks should cover the text, got %q", texts(chunks))
+		}
+		for _, c := range chunks {
+			if c.Tokens > 2 {
+				t.Fatalf("chunk too long: %+v", c)
+			}
+		}
+	})
+}
+
+func TestMarkdownSplitter(t *testing.T) {
+	text := "# Guide\nintro text\n## Install\nrun this:\n```sh\nmake deps\n\nmake all\n```\n## Usage\nuse it\n### Flags\nsome flags\n"
+	splitter := chunk.NewMarkdownSplitter(chunk.Config{ChunkTokens: 10, Counter: words})
+
+	chunks := splitter.Split(text)
+	if len(chunks) != 4 {
+		t.Fatalf("expected a chunk per section, got %q", texts(chunks))
+	}
+	if chunks[1].Text != "## Install\nrun this:\n```sh\nmake deps\n\nmake all\n```" {
+		t.Fatalf("the code block should stay whole, got %q", chunks[1].Text)
+	}
+	if strings.Join(chunks[3].Headings, " > ") != "Guide > Usage > Flags" {
+		t.Fatalf("unexpected heading path: %v", chunks[3].Headings)
+	}
+	if chunks[3].Metadata()["headings"] != "Guide > Usage > Flags" || chunks[3].Metadata()["chunk"] != "3" {
+		t.Fatalf("unexpected metadata: %v", chunks[3].Metadata())
+	}
+
+	t.Run("Headings inside code are not sections", func(t *testing.T) {
+		chunks := splitter.Split("# Title\n~~~\n# comment\n~~~\n")
+		if len(chunks) != 1 || len(chunks[0].Headings) != 1 {
+			t.Fatalf("unexpected chunks: %+v", chunks)
+		}
+	})
+}
+
+func TestProviderTokens(t *testing.T) {
+	text := strings.Repeat("abcd", 10)
+	if tokens := chunk.ProviderTokenCounter(llm.ProviderChatGpt)(text); tokens != 10 {
+		t.Fatalf("expected 10 tokens, got %d", tokens)
+	}
+	if chunk.ProviderTokenCounter(llm.ProviderDeepseek)("中文中文中") >= chunk.ProviderTokenCounter(llm.ProviderChatGpt)("中文中文中") {
+		t.Fatalf("ideograms should be cheaper for deepseek")
+	}
+
+	config := chunk.ForProvider(llm.ProviderClaude)
+	if config.ChunkTokens != 50000 || config.OverlapTokens != 5000 {
+		t.Fatalf("unexpected config: %+v", config)
+	}
+}
@@ -0,0 +1,91 @@
+package chunk
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	headingPattern = regexp.MustCompile(`^(#{1,6})[ \t]+(.*?)[ \t#]*$`)
+	fencePattern   = regexp.MustCompile("^[ \t]{0,3}(`{3,}|~{3,})")
+)
+
+// MarkdownSplitter splits markdown, starting a chunk at every heading and keeping the
+// code blocks whole unless one alone is too long for a chunk.
+type MarkdownSplitter struct {
+	splitter
+}
+
+func NewMarkdownSplitter(config Config) *MarkdownSplitter {
+	return &MarkdownSplitter{splitter: newSplitter(config)}
+}
+
+type markdownHeading struct {
+	level int
+	title string
+}
+
+func (m *MarkdownSplitter) Split(text string) []Chunk {
+	var segments []segment
+	headings := [][]string{nil}
+	var path []markdownHeading
+
+	section := 0
+	blockStart := 0
+	fence := ""
+	// closeBlock segments the lines before end, as prose or as a code block
+	closeBlock := func(end int, code bool) {
+		if end <= blockStart {
+			return
+		}
+		level := 0
+		if code {
+			level = 1
+			if tokens := m.count(text[blockStart:end]); tokens <= m.chunkTokens {
+				segments = append(segments, segment{start: blockStart, end: end, tokens: tokens, section: section})
+				blockStart = end
+				return
+			}
+		}
+		segments = append(segments, m.segment(text, blockStart, end, level, section)...)
+		blockStart = end
+	}
+
+	for lineStart := 0; lineStart < len(text); {
+		lineEnd := len(text)
+		if i := strings.IndexByte(text[lineStart:], '\n'); i >= 0 {
+			lineEnd = lineStart + i + 1
+		}
+		line := strings.TrimRight(text[lineStart:lineEnd], "\r\n")
+
+		if fence != "" {
+			if match := fencePattern.FindStringSubmatch(line); match != nil && match[1][0] == fence[0] && len(match[1]) >= len(fence) &&
+				strings.TrimSpace(line[len(match[0]):]) == "" {
+				fence = ""
+				closeBlock(lineEnd, true)
+			}
+		} else if match := fencePattern.FindStringSubmatch(line); match != nil {
+			closeBlock(lineStart, false)
+			fence = match[1]
+		} else if match := headingPattern.FindStringSubmatch(line); match != nil {
+			closeBlock(lineStart, false)
+
+			level := len(match[1])
+			for len(path) > 0 && path[len(path)-1].level >= level {
+				path = path[:len(path)-1]
+			}
+			path = append(path, markdownHeading{level: level, title: match[2]})
+
+			titles := make([]string, len(path))
+			for i, heading := range path {
+				titles[i] = heading.title
+			}
+			headings = append(headings, titles)
+			section = len(headings) - 1
+		}
+		lineStart = lineEnd
+	}
+	closeBlock(len(text), fence != "")
+
+	return m.merge(text, segments, headings)
+}
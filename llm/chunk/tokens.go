@@ -0,0 +1,84 @@
+package chunk
+
+import (
+	"math"
+	"unicode"
+
+	"github.com/sieglu2/go_foundation/llm"
+)
+
+// TokenCounter returns the number of tokens of text, usually estimated.
+type TokenCounter func(text string) int
+
+// tokenRatio is how a provider's tokenizer roughly handles a text: latin characters per
+// token, and tokens per CJK character.
+type tokenRatio struct {
+	charsPerToken     float64
+	tokensPerIdeogram float64
+}
+
+// providerRatios are rough averages of the tokenizers of the default models, the
+// Chinese-first providers having cheaper ideograms.
+var providerRatios = map[string]tokenRatio{
+	llm.ProviderChatGpt:  {charsPerToken: 4, tokensPerIdeogram: 1},
+	llm.ProviderClaude:   {charsPerToken: 3.5, tokensPerIdeogram: 1.2},
+	llm.ProviderDeepseek: {charsPerToken: 3.3, tokensPerIdeogram: 0.6},
+	llm.ProviderGemini:   {charsPerToken: 4, tokensPerIdeogram: 1},
+	llm.ProviderMinimax:  {charsPerToken: 3.5, tokensPerIdeogram: 0.7},
+}
+
+var defaultRatio = tokenRatio{charsPerToken: 4, tokensPerIdeogram: 1}
+
+// ContextTokens are the context windows of the default models of the providers.
+var ContextTokens = map[string]int{
+	llm.ProviderChatGpt:  128000,
+	llm.ProviderClaude:   200000,
+	llm.ProviderDeepseek: 64000,
+	llm.ProviderGemini:   1000000,
+	llm.ProviderMinimax:  245000,
+}
+
+// EstimateTokens estimates the tokens of text for an unknown tokenizer.
+func EstimateTokens(text string) int {
+	return defaultRatio.count(text)
+}
+
+// ProviderTokenCounter estimates the tokens of a text for the tokenizer of the provider,
+// falling back to EstimateTokens for unknown providers.
+func ProviderTokenCounter(provider string) TokenCounter {
+	ratio, ok := providerRatios[provider]
+	if !ok {
+		ratio = defaultRatio
+	}
+	return ratio.count
+}
+
+// ForProvider returns a Config sizing the chunks to a quarter of the provider's context
+// window, leaving room for the prompt and the reply, with a tenth of a chunk of overlap.
+func ForProvider(provider string) Config {
+	chunkTokens := DefaultChunkTokens
+	if contextTokens, ok := ContextTokens[provider]; ok {
+		chunkTokens = contextTokens / 4
+	}
+	return Config{
+		ChunkTokens:   chunkTokens,
+		OverlapTokens: chunkTokens / 10,
+		Counter:       ProviderTokenCounter(provider),
+	}
+}
+
+func (r tokenRatio) count(text string) int {
+	chars, ideograms := 0, 0
+	for _, c := range text {
+		if isIdeogram(c) {
+			ideograms++
+		} else {
+			chars++
+		}
+	}
+	return int(math.Ceil(float64(chars)/r.charsPerToken + float64(ideograms)*r.tokensPerIdeogram))
+}
+
+func isIdeogram(c rune) bool {
+	return unicode.In(c, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
@@ -7,13 +7,14 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 
 	"github.com/sieglu2/go_foundation/foundation"
 )
 
 const (
-	claudeSecretAccountName string = "my_anthropic"
-	claudeSecretServiceName string = "claude"
+	defaultClaudeBaseURL = "https://api.anthropic.com"
+	defaultClaudeModel   = "claude-3-opus-20240229"
 )
 
 type ClaudeClient struct {
@@ -21,13 +22,19 @@ type ClaudeClient struct {
 	maxTokens int
 	client    *http.Client
 	model     string
+	baseURL   string
 }
 
 type claudeContent struct {
+	Type   string             `json:"type"`
+	Text   string             `json:"text,omitempty"`
+	Source *claudeImageSource `json:"source,omitempty"`
+}
+
+type claudeImageSource struct {
 	Type      string `json:"type"`
-	Text      string `json:"text,omitempty"`
-	Source    string `json:"source,omitempty"`
-	MediaType string `json:"media_type,omitempty"`
+	MediaType string `json:"media_type"`
+	Data      string `json:"data"`
 }
 
 type claudeMessage struct {
@@ -46,6 +53,11 @@ type claudeResponse struct {
 	Content []struct {
 		Text string `json:"text"`
 	} `json:"content"`
+	StopReason string `json:"stop_reason"`
+	Usage      struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
 	Error *struct {
 		Type    string `json:"type"`
 		Message string `json:"message"`
@@ -53,7 +65,7 @@ type claudeResponse struct {
 }
 
 func NewClaudeClient(apiKey string) *ClaudeClient {
-	return NewClaudeClientWithConfig(apiKey, DefaultMaxTokens, "claude-3-opus-20240229")
+	return NewClaudeClientWithConfig(apiKey, DefaultMaxTokens, defaultClaudeModel)
 }
 
 func NewClaudeClientWithConfig(apiKey string, maxTokens int, model string) *ClaudeClient {
@@ -64,9 +76,15 @@ func NewClaudeClientWithConfig(apiKey string, maxTokens int, model string) *Clau
 		client: &http.Client{
 			Timeout: DefaultTimeout,
 		},
+		baseURL: defaultClaudeBaseURL,
 	}
 }
 
+// SetBaseURL replaces https://api.anthropic.com, e.g. with a gateway.
+func (c *ClaudeClient) SetBaseURL(baseURL string) {
+	c.baseURL = strings.TrimRight(baseURL, "/")
+}
+
 func (t *ClaudeClient) Close() error {
 	return nil
 }
@@ -91,9 +109,12 @@ func convertToClaudeMessages(messages []LlmMessage) []claudeMessage {
 		// Add image content if present
 		if msg.B64Image != "" {
 			claudeMsg.Content = append(claudeMsg.Content, claudeContent{
-				Type:      "image",
-				Source:    fmt.Sprintf("data:image/png;base64,%s", msg.B64Image),
-				MediaType: "image/png",
+				Type: "image",
+				Source: &claudeImageSource{
+					Type:      "base64",
+					MediaType: msg.imageMediaType(),
+					Data:      msg.B64Image,
+				},
 			})
 		}
 
@@ -107,43 +128,40 @@ func (c *ClaudeClient) ReplyMessage(
 	ctx context.Context,
 	messages []LlmMessage,
 ) (string, error) {
+	reply, err := c.ReplyMessageDetail(ctx, messages)
+	if err != nil {
+		return "", err
+	}
+	return reply.Content, nil
+}
+
+func (c *ClaudeClient) ReplyMessageDetail(
+	ctx context.Context,
+	messages []LlmMessage,
+) (*LlmReply, error) {
 	logger := foundation.Logger()
 
 	if len(messages) == 0 {
 		logger.Errorf("empty messages array")
-		return "", fmt.Errorf("empty messages array")
-	}
-
-	systemPrompt := ""
-	if messages[0].Role == RoleSystem {
-		systemPrompt = messages[0].Content
-		messages = messages[1:]
-	}
-
-	claudeMessages := convertToClaudeMessages(messages)
-
-	reqBody := claudeRequest{
-		Model:     c.model,
-		MaxTokens: c.maxTokens,
-		Messages:  claudeMessages,
-		System:    systemPrompt,
+		return nil, fmt.Errorf("empty messages array")
 	}
+	reqBody := c.newClaudeRequest(messages)
 
 	jsonBody, err := json.Marshal(reqBody)
 	if err != nil {
 		logger.Errorf("failed to Marshal: %v", err)
-		return "", fmt.Errorf("failed to Marshal: %v", err)
+		return nil, fmt.Errorf("failed to Marshal: %v", err)
 	}
 
 	req, err := http.NewRequestWithContext(
 		ctx,
 		"POST",
-		"https://api.anthropic.com/v1/messages",
+		c.baseURL+"/v1/messages",
 		bytes.NewBuffer(jsonBody),
 	)
 	if err != nil {
 		logger.Errorf("failed to NewRequestWithContext: %v", err)
-		return "", fmt.Errorf("failed to NewRequestWithContext: %v", err)
+		return nil, fmt.Errorf("failed to NewRequestWithContext: %v", err)
 	}
 
 	req.Header.Set("x-api-key", c.apiKey)
@@ -153,40 +171,75 @@ func (c *ClaudeClient) ReplyMessage(
 	resp, err := c.client.Do(req)
 	if err != nil {
 		logger.Errorf("failed to client.Do: %v", err)
-		return "", fmt.Errorf("failed to client.Do: %v", err)
+		return nil, fmt.Errorf("failed to client.Do: %w", err)
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		logger.Errorf("failed to io.ReadAll: %v", err)
-		return "", fmt.Errorf("failed to io.ReadAll: %v", err)
+		return nil, fmt.Errorf("failed to io.ReadAll: %v", err)
 	}
 
 	if resp.StatusCode != http.StatusOK {
 		var errorResp claudeResponse
 		if err := json.Unmarshal(body, &errorResp); err != nil {
 			logger.Errorf("failed to Unmarshal claudeResponse: %v", err)
-			return "", fmt.Errorf("failed to parse error response, status code: %d", resp.StatusCode)
+			return nil, newApiError(ProviderClaude, resp, "failed to parse error response, status code: %d", resp.StatusCode)
 		}
 		if errorResp.Error != nil {
 			logger.Errorf("claude API error: %s - %s", errorResp.Error.Type, errorResp.Error.Message)
-			return "", fmt.Errorf("claude API error: %s - %s", errorResp.Error.Type, errorResp.Error.Message)
+			return nil, newApiError(ProviderClaude, resp, "claude API error: %s - %s", errorResp.Error.Type, errorResp.Error.Message)
 		}
 		logger.Errorf("unexpected status code: %d", resp.StatusCode)
-		return "", fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		return nil, newApiError(ProviderClaude, resp, "unexpected status code: %d", resp.StatusCode)
 	}
 
 	var claudeResp claudeResponse
 	if err := json.Unmarshal(body, &claudeResp); err != nil {
 		logger.Errorf("failed to Unmarshal claudeResponse: %v", err)
-		return "", fmt.Errorf("failed to Unmarshal claudeResponse: %v", err)
+		return nil, fmt.Errorf("failed to Unmarshal claudeResponse: %v", err)
+	}
+
+	reply, err := c.convertClaudeResponse(&claudeResp)
+	if err != nil {
+		return nil, err
+	}
+	reply.RateLimit = parseRateLimitHeaders(resp.Header)
+	return reply, nil
+}
+
+func (c *ClaudeClient) newClaudeRequest(messages []LlmMessage) claudeRequest {
+	systemPrompt := ""
+	if len(messages) > 0 && messages[0].Role == RoleSystem {
+		systemPrompt = messages[0].Content
+		messages = messages[1:]
 	}
 
+	return claudeRequest{
+		Model:     c.model,
+		MaxTokens: c.maxTokens,
+		Messages:  convertToClaudeMessages(messages),
+		System:    systemPrompt,
+	}
+}
+
+func (c *ClaudeClient) convertClaudeResponse(claudeResp *claudeResponse) (*LlmReply, error) {
+	logger := foundation.Logger()
+
 	if len(claudeResp.Content) == 0 {
 		logger.Errorf("empty response from Claude API")
-		return "", fmt.Errorf("empty response from Claude API")
+		return nil, fmt.Errorf("empty response from Claude API")
 	}
 
-	return claudeResp.Content[0].Text, nil
+	return &LlmReply{
+		Content:      claudeResp.Content[0].Text,
+		Model:        c.model,
+		FinishReason: claudeResp.StopReason,
+		Usage: LlmUsage{
+			PromptTokens:     claudeResp.Usage.InputTokens,
+			CompletionTokens: claudeResp.Usage.OutputTokens,
+			TotalTokens:      claudeResp.Usage.InputTokens + claudeResp.Usage.OutputTokens,
+		},
+	}, nil
 }
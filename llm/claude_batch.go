@@ -0,0 +1,209 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/sieglu2/go_foundation/foundation"
+)
+
+type claudeBatchRequest struct {
+	CustomID string        `json:"custom_id"`
+	Params   claudeRequest `json:"params"`
+}
+
+type claudeBatch struct {
+	ID               string `json:"id"`
+	ProcessingStatus string `json:"processing_status"`
+	RequestCounts    struct {
+		Processing int `json:"processing"`
+		Succeeded  int `json:"succeeded"`
+		Errored    int `json:"errored"`
+		Canceled   int `json:"canceled"`
+		Expired    int `json:"expired"`
+	} `json:"request_counts"`
+	ResultsURL string `json:"results_url"`
+}
+
+type claudeBatchLine struct {
+	CustomID string `json:"custom_id"`
+	Result   struct {
+		Type    string         `json:"type"`
+		Message claudeResponse `json:"message"`
+		Error   struct {
+			Error *struct {
+				Type    string `json:"type"`
+				Message string `json:"message"`
+			} `json:"error"`
+		} `json:"error"`
+	} `json:"result"`
+}
+
+// SubmitBatch creates a message batch of the requests.
+func (c *ClaudeClient) SubmitBatch(ctx context.Context, requests []BatchRequest) (string, error) {
+	logger := foundation.Logger()
+
+	if err := validateBatchRequests(requests); err != nil {
+		logger.Errorf("invalid batch: %v", err)
+		return "", err
+	}
+
+	body := struct {
+		Requests []claudeBatchRequest `json:"requests"`
+	}{}
+	for _, request := range requests {
+		body.Requests = append(body.Requests, claudeBatchRequest{
+			CustomID: request.ID,
+			Params:   c.newClaudeRequest(request.Messages),
+		})
+	}
+
+	logger.Infof("submitting batch of %d requests to Claude", len(requests))
+	var batch claudeBatch
+	if err := c.callApi(ctx, http.MethodPost, c.baseURL+"/v1/messages/batches", body, &batch); err != nil {
+		return "", err
+	}
+	return batch.ID, nil
+}
+
+func (c *ClaudeClient) GetBatchStatus(ctx context.Context, batchID string) (*BatchStatus, error) {
+	var batch claudeBatch
+	if err := c.callApi(ctx, http.MethodGet, c.baseURL+"/v1/messages/batches/"+batchID, nil, &batch); err != nil {
+		return nil, err
+	}
+
+	counts := batch.RequestCounts
+	status := &BatchStatus{
+		State:          BatchInProgress,
+		ProviderStatus: batch.ProcessingStatus,
+		Total:          counts.Processing + counts.Succeeded + counts.Errored + counts.Canceled + counts.Expired,
+		Succeeded:      counts.Succeeded,
+		Failed:         counts.Errored + counts.Canceled + counts.Expired,
+	}
+	if batch.ProcessingStatus == "ended" {
+		status.State = BatchEnded
+	}
+	return status, nil
+}
+
+// GetBatchResults downloads the results file of the batch.
+func (c *ClaudeClient) GetBatchResults(ctx context.Context, batchID string) ([]BatchResult, error) {
+	logger := foundation.Logger()
+
+	var batch claudeBatch
+	if err := c.callApi(ctx, http.MethodGet, c.baseURL+"/v1/messages/batches/"+batchID, nil, &batch); err != nil {
+		return nil, err
+	}
+	if batch.ResultsURL == "" {
+		logger.Errorf("batch %s has no results yet", batchID)
+		return nil, fmt.Errorf("batch %s has no results yet", batchID)
+	}
+
+	var content []byte
+	if err := c.callApi(ctx, http.MethodGet, batch.ResultsURL, nil, &content); err != nil {
+		return nil, err
+	}
+
+	var results []BatchResult
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 1024*1024), 64*1024*1024)
+	for scanner.Scan() {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var line claudeBatchLine
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			logger.Errorf("failed to Unmarshal batch line: %v", err)
+			return nil, fmt.Errorf("failed to Unmarshal batch line: %v", err)
+		}
+
+		result := BatchResult{ID: line.CustomID}
+		switch line.Result.Type {
+		case "succeeded":
+			reply, err := c.convertClaudeResponse(&line.Result.Message)
+			if err != nil {
+				result.Error = err.Error()
+			} else {
+				result.Reply = reply
+			}
+		case "errored":
+			result.Error = "errored"
+			if line.Result.Error.Error != nil {
+				result.Error = fmt.Sprintf("%s: %s", line.Result.Error.Error.Type, line.Result.Error.Error.Message)
+			}
+		default:
+			result.Error = line.Result.Type
+		}
+		results = append(results, result)
+	}
+	if err := scanner.Err(); err != nil {
+		logger.Errorf("failed to read batch results of %s: %v", batchID, err)
+		return nil, fmt.Errorf("failed to read batch results of %s: %v", batchID, err)
+	}
+	return results, nil
+}
+
+// callApi sends body as json if not nil and decodes the response into out, or keeps
+// it raw when out is a *[]byte.
+func (c *ClaudeClient) callApi(ctx context.Context, method string, url string, body any, out any) error {
+	logger := foundation.Logger()
+
+	var reader io.Reader
+	if body != nil {
+		jsonBody, err := json.Marshal(body)
+		if err != nil {
+			logger.Errorf("failed to Marshal: %v", err)
+			return fmt.Errorf("failed to Marshal: %v", err)
+		}
+		reader = bytes.NewReader(jsonBody)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		logger.Errorf("failed to NewRequestWithContext: %v", err)
+		return fmt.Errorf("failed to NewRequestWithContext: %v", err)
+	}
+	req.Header.Set("x-api-key", c.apiKey)
+	req.Header.Set("anthropic-version", "2023-06-01")
+	req.Header.Set("content-type", "application/json")
+
+	// batches and their results can be far bigger than a reply, no client timeout
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		logger.Errorf("failed to client.Do: %v", err)
+		return fmt.Errorf("failed to client.Do: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		logger.Errorf("failed to io.ReadAll: %v", err)
+		return fmt.Errorf("failed to io.ReadAll: %v", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var errorResp claudeResponse
+		if err := json.Unmarshal(respBody, &errorResp); err == nil && errorResp.Error != nil {
+			logger.Errorf("claude API error: %s - %s", errorResp.Error.Type, errorResp.Error.Message)
+			return newApiError(ProviderClaude, resp, "claude API error: %s - %s", errorResp.Error.Type, errorResp.Error.Message)
+		}
+		logger.Errorf("unexpected status code: %d", resp.StatusCode)
+		return newApiError(ProviderClaude, resp, "unexpected status code: %d", resp.StatusCode)
+	}
+
+	if raw, ok := out.(*[]byte); ok {
+		*raw = respBody
+		return nil
+	}
+	if err := json.Unmarshal(respBody, out); err != nil {
+		logger.Errorf("failed to Unmarshal batch response: %v", err)
+		return fmt.Errorf("failed to Unmarshal batch response: %v", err)
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package llm
 import (
 	"context"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/sieglu2/go_foundation/foundation"
@@ -23,7 +24,36 @@ const (
 type LlmMessage struct {
 	Role     LlmRole `json:"role"`
 	Content  string  `json:"content"`
-	B64Image string
+	B64Image string  `json:"b64_image,omitempty"`
+	// ImageMediaType is the format of B64Image, image/png if empty.
+	ImageMediaType string `json:"image_media_type,omitempty"`
+}
+
+func (m LlmMessage) imageMediaType() string {
+	if m.ImageMediaType == "" {
+		return MediaTypePng
+	}
+	return m.ImageMediaType
+}
+
+type LlmUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+type LlmReply struct {
+	Content string   `json:"content"`
+	Model   string   `json:"model"`
+	Usage   LlmUsage `json:"usage"`
+	// RateLimit is the rate limit state reported along with the reply, nil if the provider
+	// does not report it.
+	RateLimit *LlmRateLimit `json:"rate_limit,omitempty"`
+	// FinishReason is why the provider stopped generating, as it reports it.
+	FinishReason string `json:"finish_reason,omitempty"`
+	// Candidates are all the alternative replies of a ReplyCandidates call, the first one
+	// being Content.
+	Candidates []LlmCandidate `json:"candidates,omitempty"`
 }
 
 type LlmClient interface {
@@ -31,6 +61,39 @@ type LlmClient interface {
 	Close() error
 }
 
+// LlmDetailClient is implemented by the clients reporting the metadata of a reply along
+// with its content, as all the clients of this package do.
+type LlmDetailClient interface {
+	// ReplyMessageDetail is ReplyMessage plus the metadata reported by the provider.
+	ReplyMessageDetail(ctx context.Context, messages []LlmMessage) (*LlmReply, error)
+}
+
+var (
+	_ LlmDetailClient = (*ClaudeClient)(nil)
+	_ LlmDetailClient = (*ChatGptClient)(nil)
+	_ LlmDetailClient = (*GeminiClient)(nil)
+	_ LlmDetailClient = (*DeepseekClient)(nil)
+	_ LlmDetailClient = (*MinimaxClient)(nil)
+	_ LlmDetailClient = (*KeyPool)(nil)
+	_ LlmDetailClient = (*InterceptedClient)(nil)
+	_ LlmDetailClient = (*BudgetClient)(nil)
+	_ LlmDetailClient = (*RateLimitedClient)(nil)
+	_ LlmDetailClient = (*RouterClient)(nil)
+)
+
+// ReplyMessageDetail asks client for a reply with its metadata. A client not implementing
+// LlmDetailClient is asked with ReplyMessage, the reply then holding the content only.
+func ReplyMessageDetail(ctx context.Context, client LlmClient, messages []LlmMessage) (*LlmReply, error) {
+	if detailClient, ok := client.(LlmDetailClient); ok {
+		return detailClient.ReplyMessageDetail(ctx, messages)
+	}
+	content, err := client.ReplyMessage(ctx, messages)
+	if err != nil {
+		return nil, err
+	}
+	return &LlmReply{Content: content}, nil
+}
+
 func NewLlmClient() (LlmClient, error) {
 	logger := foundation.Logger()
 	var errors []error
@@ -45,7 +108,11 @@ func NewLlmClient() (LlmClient, error) {
 	chatgptApiKey, err := getSecretKey(chatgptSecretAccountName, chatgptSecretServiceName)
 	if err == nil {
 		logger.Infof("using chatgpt client")
-		return NewChatGptClient(chatgptApiKey), nil
+		chatgptClient, err := newChatGptClient(chatgptApiKey, DefaultMaxTokens, "")
+		if err != nil {
+			return nil, fmt.Errorf("failed to create chatgpt client: %v", err)
+		}
+		return chatgptClient, nil
 	}
 	errors = append(errors, fmt.Errorf("chatgpt client init failed: %w", err))
 
@@ -62,6 +129,7 @@ func NewLlmClient() (LlmClient, error) {
 		}
 		return geminiClient, nil
 	}
+	errors = append(errors, fmt.Errorf("gemini client init failed: %w", err))
 
 	deepseekApiKey, err := getSecretKey(deepseekSecretAccountName, deepseekSecretServiceName)
 	if err == nil {
@@ -80,21 +148,121 @@ func NewLlmClient() (LlmClient, error) {
 	return nil, fmt.Errorf("no viable client available, errors: %v", errors)
 }
 
+// NewLlmClientForProvider creates the client of the given provider, using the model's
+// default when model is empty.
+func NewLlmClientForProvider(ctx context.Context, provider string, model string) (LlmClient, error) {
+	secret, ok := secret_key.LookupProvider(provider)
+	if !ok {
+		return nil, fmt.Errorf("unknown provider: %s", provider)
+	}
+
+	apiKey, err := getSecretKey(secret.AccountName, secret.ServiceName)
+	if err != nil {
+		return nil, fmt.Errorf("%s client init failed: %w", provider, err)
+	}
+
+	return NewLlmClientWithApiKey(ctx, provider, apiKey, model)
+}
+
+// NewLlmClientWithApiKey creates the client of the given provider with an explicit api key,
+// using the model's default when model is empty.
+func NewLlmClientWithApiKey(ctx context.Context, provider string, apiKey string, model string) (LlmClient, error) {
+	return newLlmClientWithApiKey(ctx, provider, apiKey, model, DefaultMaxTokens)
+}
+
+// newLlmClientWithApiKey creates the client of provider with model, the provider's default
+// if empty, replying with up to maxTokens.
+func newLlmClientWithApiKey(ctx context.Context, provider string, apiKey string, model string, maxTokens int) (LlmClient, error) {
+	switch provider {
+	case ProviderClaude:
+		if model == "" {
+			model = defaultClaudeModel
+		}
+		return NewClaudeClientWithConfig(apiKey, maxTokens, model), nil
+
+	case ProviderChatGpt:
+		chatgptClient, err := newChatGptClient(apiKey, maxTokens, model)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create chatgpt client: %v", err)
+		}
+		return chatgptClient, nil
+
+	case ProviderGemini:
+		if model == "" {
+			model = defaultGeminiModel
+		}
+		geminiClient, err := NewGeminiClientWithConfig(ctx, apiKey, int32(maxTokens), model)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create gemini client: %v", err)
+		}
+		return geminiClient, nil
+
+	case ProviderDeepseek:
+		if model == "" {
+			model = defaultDeepseekModel
+		}
+		return NewDeepseekClientWithConfig(apiKey, maxTokens, model), nil
+
+	case ProviderMinimax:
+		if model == "" {
+			model = defaultMinimaxModel
+		}
+		return NewMinimaxClientWithConfig(apiKey, maxTokens, model), nil
+	}
+
+	return nil, fmt.Errorf("unknown provider: %s", provider)
+}
+
+var (
+	secretProviderMu sync.RWMutex
+	secretProvider   secret_key.SecretProvider = secret_key.DefaultProvider()
+)
+
+// SetSecretProvider replaces where the clients created by NewLlmClient and
+// NewLlmClientForProvider get their api keys from, e.g. a vault integration or a
+// secret_key.StaticProvider in tests. nil restores secret_key.DefaultProvider.
+func SetSecretProvider(provider secret_key.SecretProvider) {
+	secretProviderMu.Lock()
+	defer secretProviderMu.Unlock()
+
+	if provider == nil {
+		provider = secret_key.DefaultProvider()
+	}
+	secretProvider = provider
+}
+
+func getSecretProvider() secret_key.SecretProvider {
+	secretProviderMu.RLock()
+	defer secretProviderMu.RUnlock()
+
+	return secretProvider
+}
+
+// getSecretKey returns the first key when the secret holds several, see getSecretKeys.
 func getSecretKey(accountName, serviceName string) (string, error) {
+	keys, err := getSecretKeys(accountName, serviceName)
+	if err != nil {
+		return "", err
+	}
+	return keys[0], nil
+}
+
+func getSecretKeys(accountName, serviceName string) ([]string, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	key, err := secret_key.GetSecretKey(ctx, accountName, serviceName)
+	secret, err := getSecretProvider().GetSecretKey(ctx, accountName, serviceName)
 	if err != nil {
 		if ctx.Err() == context.DeadlineExceeded {
-			return "", fmt.Errorf("timeout getting secret for %s/%s", accountName, serviceName)
+			return nil, fmt.Errorf("timeout getting secret for %s/%s", accountName, serviceName)
 		}
-		return "", fmt.Errorf("failed to GetSecretKey: %v", err)
+		return nil, fmt.Errorf("failed to GetSecretKey: %v", err)
 	}
 
-	if key == "" {
-		return "", fmt.Errorf("empty secret key returned")
+	keys := secret_key.SplitSecretKeys(secret)
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("empty secret key returned")
 	}
 
-	return key, nil
+	return keys, nil
 }
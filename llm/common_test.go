@@ -0,0 +1,73 @@
+package llm_test
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/sieglu2/go_foundation/llm"
+	"github.com/sieglu2/go_foundation/llm/secret_key"
+)
+
+// TestEveryProviderHasAClient catches a provider added to provider.json without a client.
+func TestEveryProviderHasAClient(t *testing.T) {
+	secrets := secret_key.NewStaticProvider()
+	for _, provider := range secret_key.Providers() {
+		secrets.Set(provider.AccountName, provider.ServiceName, "test-key")
+	}
+	llm.SetSecretProvider(secrets)
+	defer llm.SetSecretProvider(nil)
+
+	for _, provider := range secret_key.Providers() {
+		t.Run(provider.Name, func(t *testing.T) {
+			client, err := llm.NewLlmClientForProvider(context.Background(), provider.Name, "")
+			if err != nil {
+				t.Fatalf("NewLlmClientForProvider failed: %v", err)
+			}
+			client.Close()
+		})
+	}
+}
+
+func TestNewLlmClientForUnknownProvider(t *testing.T) {
+	if _, err := llm.NewLlmClientForProvider(context.Background(), "unknown", ""); err == nil {
+		t.Fatalf("NewLlmClientForProvider should fail for an unknown provider")
+	}
+}
+
+// plainClient implements LlmClient only, as clients outside of this package may.
+type plainClient struct{}
+
+func (plainClient) ReplyMessage(ctx context.Context, messages []llm.LlmMessage) (string, error) {
+	return "plain", nil
+}
+
+func (plainClient) Close() error {
+	return nil
+}
+
+func TestReplyMessageDetail(t *testing.T) {
+	ctx := context.Background()
+	messages := []llm.LlmMessage{{Role: llm.RoleUser, Content: "hi"}}
+
+	reply, err := llm.ReplyMessageDetail(ctx, plainClient{}, messages)
+	if err != nil || reply.Content != "plain" {
+		t.Fatalf("expected the plain content, got %+v: %v", reply, err)
+	}
+
+	wrapped := llm.NewInterceptedClient(plainClient{})
+	if content, err := wrapped.ReplyMessage(ctx, messages); err != nil || content != "plain" {
+		t.Fatalf("expected a wrapper over a plain client to work, got %s: %v", content, err)
+	}
+}
+
+func TestLlmMessageJson(t *testing.T) {
+	data, err := json.Marshal(llm.LlmMessage{Role: llm.RoleUser, Content: "hi", B64Image: "aGk=", ImageMediaType: llm.MediaTypeJpeg})
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if !strings.Contains(string(data), `"b64_image":"aGk="`) || !strings.Contains(string(data), `"image_media_type":"image/jpeg"`) {
+		t.Fatalf("expected snake case keys, got %s", data)
+	}
+}
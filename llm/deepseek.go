@@ -7,13 +7,14 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 
 	"github.com/sieglu2/go_foundation/foundation"
 )
 
 const (
-	deepseekSecretAccountName string = "my_deepseek"
-	deepseekSecretServiceName string = "deepseek"
+	defaultDeepseekBaseURL = "https://api.deepseek.com"
+	defaultDeepseekModel   = "deepseek-chat"
 )
 
 type DeepseekClient struct {
@@ -21,6 +22,7 @@ type DeepseekClient struct {
 	maxTokens int
 	client    *http.Client
 	model     string
+	baseURL   string
 }
 
 type deepseekMessageContent struct {
@@ -33,6 +35,8 @@ type deepseekMessageContent struct {
 type deepseekMessage struct {
 	Role    string                   `json:"role"`
 	Content []deepseekMessageContent `json:"content"`
+	// Prefix marks the last assistant message as the start of the reply to continue.
+	Prefix bool `json:"prefix,omitempty"`
 }
 
 type deepseekRequest struct {
@@ -42,11 +46,14 @@ type deepseekRequest struct {
 }
 
 type deepseekResponse struct {
+	Model   string `json:"model"`
 	Choices []struct {
 		Message struct {
 			Content string `json:"content"`
 		} `json:"message"`
+		FinishReason string `json:"finish_reason"`
 	} `json:"choices"`
+	Usage LlmUsage `json:"usage"`
 	Error *struct {
 		Message string `json:"message"`
 		Type    string `json:"type"`
@@ -55,7 +62,7 @@ type deepseekResponse struct {
 }
 
 func NewDeepseekClient(apiKey string) *DeepseekClient {
-	return NewDeepseekClientWithConfig(apiKey, DefaultMaxTokens, "deepseek-chat")
+	return NewDeepseekClientWithConfig(apiKey, DefaultMaxTokens, defaultDeepseekModel)
 }
 
 func NewDeepseekClientWithConfig(apiKey string, maxTokens int, model string) *DeepseekClient {
@@ -66,9 +73,15 @@ func NewDeepseekClientWithConfig(apiKey string, maxTokens int, model string) *De
 		client: &http.Client{
 			Timeout: DefaultTimeout,
 		},
+		baseURL: defaultDeepseekBaseURL,
 	}
 }
 
+// SetBaseURL replaces https://api.deepseek.com, e.g. with a gateway.
+func (d *DeepseekClient) SetBaseURL(baseURL string) {
+	d.baseURL = strings.TrimRight(baseURL, "/")
+}
+
 func (t *DeepseekClient) Close() error {
 	return nil
 }
@@ -110,8 +123,19 @@ func (d *DeepseekClient) ReplyMessage(
 	ctx context.Context,
 	messages []LlmMessage,
 ) (string, error) {
+	reply, err := d.ReplyMessageDetail(ctx, messages)
+	if err != nil {
+		return "", err
+	}
+	return reply.Content, nil
+}
+
+func (d *DeepseekClient) ReplyMessageDetail(
+	ctx context.Context,
+	messages []LlmMessage,
+) (*LlmReply, error) {
 	if len(messages) == 0 {
-		return "", fmt.Errorf("empty messages array")
+		return nil, fmt.Errorf("empty messages array")
 	}
 
 	deepseekMessages := convertToDeepseekMessages(messages)
@@ -122,19 +146,45 @@ func (d *DeepseekClient) ReplyMessage(
 		Messages:  deepseekMessages,
 	}
 
-	jsonBody, err := json.Marshal(reqBody)
+	var deepseekResp deepseekResponse
+	header, err := d.callApi(ctx, http.MethodPost, "/v1/chat/completions", reqBody, &deepseekResp)
 	if err != nil {
-		return "", fmt.Errorf("failed to json.Marshal: %v", err)
+		return nil, err
+	}
+
+	if len(deepseekResp.Choices) == 0 {
+		return nil, fmt.Errorf("empty response from Deepseek API")
+	}
+
+	return &LlmReply{
+		Content:      deepseekResp.Choices[0].Message.Content,
+		Model:        d.model,
+		RateLimit:    parseRateLimitHeaders(header),
+		FinishReason: deepseekResp.Choices[0].FinishReason,
+		Usage:        deepseekResp.Usage,
+	}, nil
+}
+
+// callApi sends body as json if not nil to path and decodes the response into out,
+// returning the response headers.
+func (d *DeepseekClient) callApi(ctx context.Context, method string, path string, body any, out any) (http.Header, error) {
+	var reader io.Reader
+	if body != nil {
+		jsonBody, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to json.Marshal: %v", err)
+		}
+		reader = bytes.NewBuffer(jsonBody)
 	}
 
 	req, err := http.NewRequestWithContext(
 		ctx,
-		"POST",
-		"https://api.deepseek.com/v1/chat/completions",
-		bytes.NewBuffer(jsonBody),
+		method,
+		d.baseURL+path,
+		reader,
 	)
 	if err != nil {
-		return "", fmt.Errorf("failed to NewRequestWithContext: %v", err)
+		return nil, fmt.Errorf("failed to NewRequestWithContext: %v", err)
 	}
 
 	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", d.apiKey))
@@ -142,35 +192,29 @@ func (d *DeepseekClient) ReplyMessage(
 
 	resp, err := d.client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("failed to client.Do: %v", err)
+		return nil, fmt.Errorf("failed to client.Do: %w", err)
 	}
 	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("failed to io.ReadAll: %v", err)
+		return nil, fmt.Errorf("failed to io.ReadAll: %v", err)
 	}
 
 	if resp.StatusCode != http.StatusOK {
 		var errorResp deepseekResponse
-		if err := json.Unmarshal(body, &errorResp); err != nil {
-			return "", fmt.Errorf("failed to parse error response, status code: %d", resp.StatusCode)
+		if err := json.Unmarshal(respBody, &errorResp); err != nil {
+			return nil, newApiError(ProviderDeepseek, resp, "failed to parse error response, status code: %d", resp.StatusCode)
 		}
 		if errorResp.Error != nil {
-			return "", fmt.Errorf("deepseek API error: %s - %s (code: %s)",
+			return nil, newApiError(ProviderDeepseek, resp, "deepseek API error: %s - %s (code: %s)",
 				errorResp.Error.Type, errorResp.Error.Message, errorResp.Error.Code)
 		}
-		return "", fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		return nil, newApiError(ProviderDeepseek, resp, "unexpected status code: %d", resp.StatusCode)
 	}
 
-	var deepseekResp deepseekResponse
-	if err := json.Unmarshal(body, &deepseekResp); err != nil {
-		return "", fmt.Errorf("failed to json.Unmarshal: %v", err)
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return nil, fmt.Errorf("failed to json.Unmarshal: %v", err)
 	}
-
-	if len(deepseekResp.Choices) == 0 {
-		return "", fmt.Errorf("empty response from Deepseek API")
-	}
-
-	return deepseekResp.Choices[0].Message.Content, nil
+	return resp.Header, nil
 }
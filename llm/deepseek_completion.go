@@ -0,0 +1,146 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// deepseekFimMaxTokens is the most tokens the fill-in-the-middle endpoint generates.
+const deepseekFimMaxTokens = 4096
+
+type deepseekFimRequest struct {
+	Model     string   `json:"model"`
+	Prompt    string   `json:"prompt"`
+	Suffix    string   `json:"suffix,omitempty"`
+	MaxTokens int      `json:"max_tokens"`
+	Stop      []string `json:"stop,omitempty"`
+}
+
+type deepseekFimResponse struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Text         string `json:"text"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage LlmUsage `json:"usage"`
+}
+
+// DeepseekCompletionOptions tune a completion, the zero value using the client settings.
+type DeepseekCompletionOptions struct {
+	// MaxTokens caps the completion, the client's max tokens if 0.
+	MaxTokens int
+	// Stop are the sequences ending the completion, not included in it.
+	Stop []string
+}
+
+// CompleteFim fills in the middle between prompt and suffix, e.g. the code before and
+// after the cursor, with the beta completions api. The reply content is the middle only.
+func (d *DeepseekClient) CompleteFim(
+	ctx context.Context,
+	prompt string,
+	suffix string,
+	options DeepseekCompletionOptions,
+) (*LlmReply, error) {
+	if prompt == "" {
+		return nil, fmt.Errorf("empty prompt")
+	}
+
+	maxTokens := options.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = d.maxTokens
+	}
+
+	reqBody := deepseekFimRequest{
+		Model:     d.model,
+		Prompt:    prompt,
+		Suffix:    suffix,
+		MaxTokens: min(maxTokens, deepseekFimMaxTokens),
+		Stop:      options.Stop,
+	}
+
+	var deepseekResp deepseekFimResponse
+	header, err := d.callApi(ctx, http.MethodPost, "/beta/completions", reqBody, &deepseekResp)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(deepseekResp.Choices) == 0 {
+		return nil, fmt.Errorf("empty response from Deepseek API")
+	}
+
+	model := deepseekResp.Model
+	if model == "" {
+		model = d.model
+	}
+	return &LlmReply{
+		Content:      deepseekResp.Choices[0].Text,
+		Model:        model,
+		RateLimit:    parseRateLimitHeaders(header),
+		FinishReason: deepseekResp.Choices[0].FinishReason,
+		Usage:        deepseekResp.Usage,
+	}, nil
+}
+
+// CompletePrefix replies to messages starting the reply with prefix, e.g. "```go\n" to
+// force a code block, with the beta chat completions api. The reply content is the
+// continuation only, without prefix.
+func (d *DeepseekClient) CompletePrefix(
+	ctx context.Context,
+	messages []LlmMessage,
+	prefix string,
+	options DeepseekCompletionOptions,
+) (*LlmReply, error) {
+	if len(messages) == 0 {
+		return nil, fmt.Errorf("empty messages array")
+	}
+	if prefix == "" {
+		return nil, fmt.Errorf("empty prefix")
+	}
+
+	maxTokens := options.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = d.maxTokens
+	}
+
+	deepseekMessages := convertToDeepseekMessages(messages)
+	deepseekMessages = append(deepseekMessages, deepseekMessage{
+		Role:    string(RoleAssistant),
+		Content: []deepseekMessageContent{{Type: "text", Text: prefix}},
+		Prefix:  true,
+	})
+
+	reqBody := struct {
+		deepseekRequest
+		Stop []string `json:"stop,omitempty"`
+	}{
+		deepseekRequest: deepseekRequest{
+			Model:     d.model,
+			MaxTokens: maxTokens,
+			Messages:  deepseekMessages,
+		},
+		Stop: options.Stop,
+	}
+
+	var deepseekResp deepseekResponse
+	header, err := d.callApi(ctx, http.MethodPost, "/beta/chat/completions", reqBody, &deepseekResp)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(deepseekResp.Choices) == 0 {
+		return nil, fmt.Errorf("empty response from Deepseek API")
+	}
+
+	model := deepseekResp.Model
+	if model == "" {
+		model = d.model
+	}
+	return &LlmReply{
+		Content:      deepseekResp.Choices[0].Message.Content,
+		Model:        model,
+		RateLimit:    parseRateLimitHeaders(header),
+		FinishReason: deepseekResp.Choices[0].FinishReason,
+		Usage:        deepseekResp.Usage,
+	}, nil
+}
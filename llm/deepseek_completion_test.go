@@ -0,0 +1,76 @@
+package llm_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sieglu2/go_foundation/llm"
+)
+
+func TestDeepseekCompletion(t *testing.T) {
+	ctx := context.Background()
+
+	var received string
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	mux.HandleFunc("/beta/completions", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = string(body)
+		w.Write([]byte(`{"model": "deepseek-chat", "choices": [{"text": "a + b", "finish_reason": "stop"}],
+			"usage": {"prompt_tokens": 8, "completion_tokens": 3, "total_tokens": 11}}`))
+	})
+	mux.HandleFunc("/beta/chat/completions", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = string(body)
+		w.Write([]byte(`{"model": "deepseek-coder", "choices": [{"message": {"content": "fmt.Println(1)\n"}, "finish_reason": "stop"}]}`))
+	})
+	mux.HandleFunc("/v1/chat/completions", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"error": {"message": "slow down", "type": "rate_limit", "code": "429"}}`))
+	})
+
+	client := llm.NewDeepseekClientWithConfig("key", 8000, "deepseek-chat")
+	client.SetBaseURL(server.URL + "/")
+
+	t.Run("Fill in the middle", func(t *testing.T) {
+		reply, err := client.CompleteFim(ctx, "func add(a, b int) int {\n\treturn ", "\n}", llm.DeepseekCompletionOptions{Stop: []string{"\n"}})
+		if err != nil {
+			t.Fatalf("CompleteFim failed: %v", err)
+		}
+		if reply.Content != "a + b" || reply.FinishReason != "stop" || reply.Usage.TotalTokens != 11 {
+			t.Fatalf("unexpected reply: %+v", reply)
+		}
+		if !strings.Contains(received, `"suffix":"\n}"`) || !strings.Contains(received, `"max_tokens":4096`) || !strings.Contains(received, `"stop":["\n"]`) {
+			t.Fatalf("unexpected request: %s", received)
+		}
+	})
+
+	t.Run("Prefix", func(t *testing.T) {
+		messages := []llm.LlmMessage{{Role: llm.RoleUser, Content: "print 1 in go"}}
+		reply, err := client.CompletePrefix(ctx, messages, "```go\n", llm.DeepseekCompletionOptions{Stop: []string{"```"}})
+		if err != nil {
+			t.Fatalf("CompletePrefix failed: %v", err)
+		}
+		if reply.Content != "fmt.Println(1)\n" || reply.Model != "deepseek-coder" {
+			t.Fatalf("unexpected reply: %+v", reply)
+		}
+		if !strings.Contains(received, `"role":"assistant","content":[{"type":"text","text":"`+"```go\\n"+`"}],"prefix":true`) {
+			t.Fatalf("expected the prefix as last assistant message, got %s", received)
+		}
+	})
+
+	t.Run("Same errors as chat", func(t *testing.T) {
+		_, err := client.ReplyMessage(ctx, []llm.LlmMessage{{Role: llm.RoleUser, Content: "hi"}})
+		if llm.HttpStatusCode(err) != http.StatusTooManyRequests || !strings.Contains(err.Error(), "slow down") {
+			t.Fatalf("expected the api error, got %v", err)
+		}
+		if _, err := client.CompleteFim(ctx, "", "", llm.DeepseekCompletionOptions{}); err == nil {
+			t.Fatalf("an empty prompt should be rejected")
+		}
+	})
+}
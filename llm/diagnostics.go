@@ -0,0 +1,171 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sieglu2/go_foundation/foundation"
+	"github.com/sieglu2/go_foundation/llm/secret_key"
+)
+
+const (
+	// diagnosticPrompt is the message of the validation call of the providers without a
+	// models listing, asking for the shortest reply.
+	diagnosticPrompt = "Reply with the single word ok."
+	// diagnosticMaxTokens caps the reply of that call.
+	diagnosticMaxTokens = 5
+)
+
+// ProviderDiagnosis is the state of a provider: whether its key is found, accepted, and
+// which models it offers.
+type ProviderDiagnosis struct {
+	Provider string `json:"provider"`
+	KeyFound bool   `json:"key_found"`
+	// MaskedKey is the first key found, masked.
+	MaskedKey string `json:"masked_key,omitempty"`
+	// Keys is the number of keys found, several keys being separated by commas.
+	Keys int `json:"keys,omitempty"`
+
+	Validated bool `json:"validated"`
+	// Valid is whether the validation call succeeded. The call lists the models, or asks
+	// for a few tokens if the provider cannot list them.
+	Valid bool `json:"valid"`
+	// Model is the model answering the validation call, empty if it listed the models.
+	Model      string `json:"model,omitempty"`
+	LatencyMs  int64  `json:"latency_ms,omitempty"`
+	StatusCode int    `json:"status_code,omitempty"`
+	// Error is why the key was not found or the validation call failed.
+	Error string `json:"error,omitempty"`
+
+	Models          []string `json:"models,omitempty"`
+	ModelsLatencyMs int64    `json:"models_latency_ms,omitempty"`
+	ModelsError     string   `json:"models_error,omitempty"`
+}
+
+// Ok is whether the provider is usable: its key is found, and valid if validated.
+func (d *ProviderDiagnosis) Ok() bool {
+	return d.KeyFound && (!d.Validated || d.Valid)
+}
+
+type DiagnosticsConfig struct {
+	// Providers are the providers to diagnose, all of them if empty.
+	Providers []string
+	// SkipValidation only looks the keys up, without any call.
+	SkipValidation bool
+	// SkipModels does not list the models.
+	SkipModels bool
+	// Timeout bounds the calls of every provider, DefaultTimeout if 0.
+	Timeout time.Duration
+}
+
+// DiagnoseProviders diagnoses the providers in parallel, in the order of
+// config.Providers or by name.
+func DiagnoseProviders(ctx context.Context, config DiagnosticsConfig) []ProviderDiagnosis {
+	providers := config.Providers
+	if len(providers) == 0 {
+		for _, provider := range secret_key.Providers() {
+			providers = append(providers, provider.Name)
+		}
+	}
+
+	diagnoses := make([]ProviderDiagnosis, len(providers))
+	jobs := make(chan any, len(providers))
+	for i := range providers {
+		jobs <- i
+	}
+	close(jobs)
+
+	// every diagnosis keeps its own errors, the run itself never fails
+	foundation.RunInParallel(len(providers), 0, jobs, func(a any) error {
+		i := a.(int)
+		diagnoses[i] = DiagnoseProvider(ctx, providers[i], config)
+		return nil
+	}, func(errs []error) error {
+		return nil
+	})
+	return diagnoses
+}
+
+// DiagnoseProvider looks the key of provider up, validates it with the cheapest call and
+// lists the models, as configured. Listing the models validates the key too, so a
+// provider able to list them is called once.
+func DiagnoseProvider(ctx context.Context, provider string, config DiagnosticsConfig) ProviderDiagnosis {
+	diagnosis := ProviderDiagnosis{Provider: provider}
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultTimeout
+	}
+
+	secret, ok := secret_key.LookupProvider(provider)
+	if !ok {
+		diagnosis.Error = fmt.Sprintf("unknown provider: %s", provider)
+		return diagnosis
+	}
+	keys, err := getSecretKeys(secret.AccountName, secret.ServiceName)
+	if err != nil {
+		diagnosis.Error = err.Error()
+		return diagnosis
+	}
+	diagnosis.KeyFound = true
+	diagnosis.Keys = len(keys)
+	diagnosis.MaskedKey = secret_key.MaskKey(keys[0])
+
+	if config.SkipValidation && config.SkipModels {
+		return diagnosis
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, config.Timeout)
+	defer cancel()
+
+	client, err := newLlmClientWithApiKey(ctx, provider, keys[0], "", diagnosticMaxTokens)
+	if err != nil {
+		diagnosis.Error = err.Error()
+		return diagnosis
+	}
+	defer client.Close()
+
+	if lister, ok := client.(ModelLister); ok {
+		start := time.Now()
+		models, err := lister.ListModels(ctx)
+		latency := time.Since(start).Milliseconds()
+
+		if !config.SkipValidation {
+			diagnosis.Validated = true
+			diagnosis.LatencyMs = latency
+			if err != nil {
+				diagnosis.Error = err.Error()
+				diagnosis.StatusCode = HttpStatusCode(err)
+			} else {
+				diagnosis.Valid = true
+			}
+		}
+		if !config.SkipModels {
+			diagnosis.ModelsLatencyMs = latency
+			if err != nil {
+				diagnosis.ModelsError = err.Error()
+			} else {
+				diagnosis.Models = models
+			}
+		}
+		return diagnosis
+	}
+
+	if !config.SkipValidation {
+		diagnosis.Validated = true
+		start := time.Now()
+		reply, err := ReplyMessageDetail(ctx, client, []LlmMessage{{Role: RoleUser, Content: diagnosticPrompt}})
+		diagnosis.LatencyMs = time.Since(start).Milliseconds()
+		if err != nil {
+			diagnosis.Error = err.Error()
+			diagnosis.StatusCode = HttpStatusCode(err)
+		} else {
+			diagnosis.Valid = true
+			diagnosis.Model = reply.Model
+		}
+	}
+	if !config.SkipModels {
+		diagnosis.ModelsError = "model listing not supported"
+	}
+
+	return diagnosis
+}
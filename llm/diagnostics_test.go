@@ -0,0 +1,93 @@
+package llm_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sieglu2/go_foundation/llm"
+	"github.com/sieglu2/go_foundation/llm/secret_key"
+)
+
+// newFakeModelsServer serves the models of an openai style api, accepting only the
+// "good-key" api key. It serves no chat completions, the validation must not need them.
+func newFakeModelsServer(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.Header.Get("Authorization"), "good-key") {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error": {"message": "invalid api key", "type": "invalid_request_error", "code": "invalid_api_key"}}`))
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	models := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data": [{"id": "gpt-4o-mini"}, {"id": "gpt-4o"}]}`))
+	}
+	mux.HandleFunc("/v1/models", models)
+	mux.HandleFunc("/models", models)
+	return server
+}
+
+func TestDiagnoseProviders(t *testing.T) {
+	ctx := context.Background()
+	server := newFakeModelsServer(t)
+
+	llm.SetChatGptConfig(&llm.ChatGptConfig{BaseURL: server.URL + "/v1"})
+	defer llm.SetChatGptConfig(nil)
+	defer llm.SetSecretProvider(nil)
+
+	chatgpt, _ := secret_key.LookupProvider(llm.ProviderChatGpt)
+	config := llm.DiagnosticsConfig{Providers: []string{llm.ProviderChatGpt, llm.ProviderClaude}}
+
+	t.Run("Valid key and missing key", func(t *testing.T) {
+		llm.SetSecretProvider(secret_key.NewStaticProvider().Set(chatgpt.AccountName, chatgpt.ServiceName, "good-key,other-key"))
+
+		diagnoses := llm.DiagnoseProviders(ctx, config)
+		if len(diagnoses) != 2 {
+			t.Fatalf("expected 2 diagnoses, got %+v", diagnoses)
+		}
+
+		valid := diagnoses[0]
+		if !valid.Ok() || !valid.Valid || valid.Keys != 2 || valid.MaskedKey == "good-key" {
+			t.Fatalf("unexpected diagnosis: %+v", valid)
+		}
+		if strings.Join(valid.Models, ",") != "gpt-4o,gpt-4o-mini" {
+			t.Fatalf("expected the sorted models, got %v", valid.Models)
+		}
+
+		missing := diagnoses[1]
+		if missing.Provider != llm.ProviderClaude || missing.KeyFound || missing.Ok() || missing.Error == "" {
+			t.Fatalf("unexpected diagnosis: %+v", missing)
+		}
+	})
+
+	t.Run("Invalid key", func(t *testing.T) {
+		llm.SetSecretProvider(secret_key.NewStaticProvider().Set(chatgpt.AccountName, chatgpt.ServiceName, "bad-key"))
+
+		diagnosis := llm.DiagnoseProvider(ctx, llm.ProviderChatGpt, llm.DiagnosticsConfig{SkipModels: true})
+		if diagnosis.Ok() || diagnosis.Valid || diagnosis.StatusCode != http.StatusUnauthorized || diagnosis.Models != nil {
+			t.Fatalf("unexpected diagnosis: %+v", diagnosis)
+		}
+
+		diagnosis = llm.DiagnoseProvider(ctx, llm.ProviderChatGpt, llm.DiagnosticsConfig{SkipValidation: true, SkipModels: true})
+		if !diagnosis.Ok() || diagnosis.Validated {
+			t.Fatalf("a found key should be ok without validation: %+v", diagnosis)
+		}
+	})
+}
+
+func TestDeepseekListModels(t *testing.T) {
+	server := newFakeModelsServer(t)
+
+	client := llm.NewDeepseekClient("good-key")
+	client.SetBaseURL(server.URL)
+	models, err := client.ListModels(context.Background())
+	if err != nil || len(models) != 2 || models[0] != "gpt-4o" {
+		t.Fatalf("unexpected models %v: %v", models, err)
+	}
+}
@@ -0,0 +1,108 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/google/generative-ai-go/genai"
+	"github.com/sashabaranov/go-openai"
+	"github.com/sieglu2/go_foundation/foundation"
+)
+
+const (
+	defaultChatGptEmbeddingModel = string(openai.SmallEmbedding3)
+	defaultGeminiEmbeddingModel  = "text-embedding-004"
+
+	// geminiEmbeddingBatchSize is the most texts BatchEmbedContents accepts in one request.
+	geminiEmbeddingBatchSize = 100
+)
+
+// Embedder turns texts into vectors, one per text in the same order.
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+var (
+	_ Embedder = (*ChatGptClient)(nil)
+	_ Embedder = (*GeminiClient)(nil)
+)
+
+// SetEmbeddingModel replaces the default text-embedding-3-small model used by Embed.
+func (t *ChatGptClient) SetEmbeddingModel(model string) {
+	t.embeddingModel = model
+}
+
+func (t *ChatGptClient) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	logger := foundation.Logger()
+
+	if len(texts) == 0 {
+		return nil, nil
+	}
+
+	resp, err := t.client.CreateEmbeddings(ctx, openai.EmbeddingRequestStrings{
+		Input: texts,
+		Model: openai.EmbeddingModel(t.embeddingModel),
+	})
+	if err != nil {
+		logger.Errorf("failed to CreateEmbeddings: %v", err)
+		return nil, fmt.Errorf("failed to CreateEmbeddings: %w", err)
+	}
+	if len(resp.Data) != len(texts) {
+		logger.Errorf("expected %d embeddings, got %d", len(texts), len(resp.Data))
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(resp.Data))
+	}
+
+	vectors := make([][]float32, len(texts))
+	for _, embedding := range resp.Data {
+		vectors[embedding.Index] = embedding.Embedding
+	}
+	return vectors, nil
+}
+
+// SetEmbeddingModel replaces the default text-embedding-004 model used by Embed.
+func (g *GeminiClient) SetEmbeddingModel(model string) {
+	g.embeddingModel = model
+}
+
+// Embed sends the texts in batches, each text cut to about GEMINI_EMBEDDINGS_MAX_TOKEN
+// tokens since longer ones are refused.
+func (g *GeminiClient) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	logger := foundation.Logger()
+
+	model := g.client.EmbeddingModel(g.embeddingModel)
+	vectors := make([][]float32, 0, len(texts))
+
+	for start := 0; start < len(texts); start += geminiEmbeddingBatchSize {
+		end := min(start+geminiEmbeddingBatchSize, len(texts))
+
+		batch := model.NewBatch()
+		for _, text := range texts[start:end] {
+			if len(text) > GEMINI_EMBEDDINGS_MAX_TOKEN*4 {
+				// cut on a rune boundary, a split rune is invalid utf-8
+				cut := GEMINI_EMBEDDINGS_MAX_TOKEN * 4
+				for cut > 0 && !utf8.RuneStart(text[cut]) {
+					cut--
+				}
+				text = text[:cut]
+			}
+			batch.AddContent(genai.Text(text))
+		}
+
+		resp, err := model.BatchEmbedContents(ctx, batch)
+		if err != nil {
+			logger.Errorf("failed to BatchEmbedContents: %v", err)
+			return nil, fmt.Errorf("failed to BatchEmbedContents: %w", err)
+		}
+		if len(resp.Embeddings) != end-start {
+			logger.Errorf("expected %d embeddings, got %d", end-start, len(resp.Embeddings))
+			return nil, fmt.Errorf("expected %d embeddings, got %d", end-start, len(resp.Embeddings))
+		}
+
+		for _, embedding := range resp.Embeddings {
+			vectors = append(vectors, embedding.Values)
+		}
+	}
+
+	return vectors, nil
+}
@@ -0,0 +1,98 @@
+package llm
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ApiError is returned by the clients when the provider answers with an error status.
+type ApiError struct {
+	Provider   string
+	StatusCode int
+	Message    string
+	// RetryAfter is the wait asked by the provider through the Retry-After header, if any.
+	RetryAfter time.Duration
+}
+
+func (e *ApiError) Error() string {
+	return e.Message
+}
+
+func newApiError(provider string, resp *http.Response, format string, args ...any) *ApiError {
+	return &ApiError{
+		Provider:   provider,
+		StatusCode: resp.StatusCode,
+		Message:    fmt.Sprintf(format, args...),
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+	}
+}
+
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return time.Until(date)
+	}
+	return 0
+}
+
+// HttpStatusCode returns the http status of a provider error returned by any of the clients,
+// or 0 if err does not carry one.
+func HttpStatusCode(err error) int {
+	var apiErr *ApiError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode
+	}
+
+	var openaiErr *openai.APIError
+	if errors.As(err, &openaiErr) {
+		return openaiErr.HTTPStatusCode
+	}
+	var openaiRequestErr *openai.RequestError
+	if errors.As(err, &openaiRequestErr) {
+		return openaiRequestErr.HTTPStatusCode
+	}
+
+	// gemini errors come from the google api client, over http or grpc
+	var httpCoder interface{ HTTPCode() int }
+	if errors.As(err, &httpCoder) && httpCoder.HTTPCode() > 0 {
+		return httpCoder.HTTPCode()
+	}
+	var grpcStatuser interface{ GRPCStatus() *status.Status }
+	if errors.As(err, &grpcStatuser) {
+		switch grpcStatuser.GRPCStatus().Code() {
+		case codes.Unauthenticated:
+			return http.StatusUnauthorized
+		case codes.PermissionDenied:
+			return http.StatusForbidden
+		case codes.ResourceExhausted:
+			return http.StatusTooManyRequests
+		case codes.InvalidArgument:
+			return http.StatusBadRequest
+		case codes.Unavailable:
+			return http.StatusServiceUnavailable
+		}
+	}
+
+	return 0
+}
+
+// RetryAfter returns the wait asked by the provider along with err, or 0 if unknown.
+func RetryAfter(err error) time.Duration {
+	var apiErr *ApiError
+	if errors.As(err, &apiErr) {
+		return apiErr.RetryAfter
+	}
+	return 0
+}
@@ -0,0 +1,70 @@
+// Package eval runs a dataset of prompts against several llm clients, scores the replies
+// and writes the comparison as an Excel workbook.
+package eval
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sieglu2/go_foundation/foundation"
+)
+
+// Case is one input of a dataset with the output expected for it.
+type Case struct {
+	ID       string `json:"id"`
+	System   string `json:"system,omitempty"`
+	Input    string `json:"input"`
+	Expected string `json:"expected"`
+}
+
+type Dataset []Case
+
+// LoadDataset reads a dataset from a json array of cases, or from json lines with one case
+// per line. Cases without an id are numbered from 1.
+func LoadDataset(path string) (Dataset, error) {
+	logger := foundation.Logger()
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		logger.Errorf("failed to ReadFile %s: %v", path, err)
+		return nil, fmt.Errorf("failed to ReadFile %s: %v", path, err)
+	}
+
+	var dataset Dataset
+	if strings.HasPrefix(strings.TrimSpace(string(content)), "[") {
+		if err := json.Unmarshal(content, &dataset); err != nil {
+			logger.Errorf("failed to Unmarshal dataset %s: %v", path, err)
+			return nil, fmt.Errorf("failed to Unmarshal dataset %s: %v", path, err)
+		}
+	} else {
+		scanner := bufio.NewScanner(strings.NewReader(string(content)))
+		scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+		for lineNumber := 1; scanner.Scan(); lineNumber++ {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" {
+				continue
+			}
+
+			var c Case
+			if err := json.Unmarshal([]byte(line), &c); err != nil {
+				logger.Errorf("failed to Unmarshal line %d of %s: %v", lineNumber, path, err)
+				return nil, fmt.Errorf("failed to Unmarshal line %d of %s: %v", lineNumber, path, err)
+			}
+			dataset = append(dataset, c)
+		}
+		if err := scanner.Err(); err != nil {
+			logger.Errorf("failed to read %s: %v", path, err)
+			return nil, fmt.Errorf("failed to read %s: %v", path, err)
+		}
+	}
+
+	for i := range dataset {
+		if dataset[i].ID == "" {
+			dataset[i].ID = fmt.Sprintf("%d", i+1)
+		}
+	}
+	return dataset, nil
+}
@@ -0,0 +1,189 @@
+package eval_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/sieglu2/go_foundation/llm"
+	"github.com/sieglu2/go_foundation/llm/eval"
+	"github.com/xuri/excelize/v2"
+)
+
+// fakeAnswerClient answers from a map of inputs, failing on unknown ones.
+type fakeAnswerClient struct {
+	answers map[string]string
+}
+
+func (f *fakeAnswerClient) ReplyMessage(ctx context.Context, messages []llm.LlmMessage) (string, error) {
+	reply, err := f.ReplyMessageDetail(ctx, messages)
+	if err != nil {
+		return "", err
+	}
+	return reply.Content, nil
+}
+
+func (f *fakeAnswerClient) ReplyMessageDetail(ctx context.Context, messages []llm.LlmMessage) (*llm.LlmReply, error) {
+	input := messages[len(messages)-1].Content
+	answer, ok := f.answers[input]
+	if !ok {
+		return nil, fmt.Errorf("no answer for %q", input)
+	}
+	return &llm.LlmReply{Content: answer, Usage: llm.LlmUsage{TotalTokens: 10}}, nil
+}
+
+func (f *fakeAnswerClient) Close() error {
+	return nil
+}
+
+func TestLoadDataset(t *testing.T) {
+	dir := t.TempDir()
+
+	jsonl := filepath.Join(dir, "dataset.jsonl")
+	os.WriteFile(jsonl, []byte(`{"input": "1+1", "expected": "2"}`+"\n\n"+`{"id": "b", "input": "2+2", "expected": "4"}`+"\n"), 0644)
+	dataset, err := eval.LoadDataset(jsonl)
+	if err != nil {
+		t.Fatalf("LoadDataset failed: %v", err)
+	}
+	if len(dataset) != 2 || dataset[0].ID != "1" || dataset[1].ID != "b" {
+		t.Fatalf("unexpected dataset: %+v", dataset)
+	}
+
+	array := filepath.Join(dir, "dataset.json")
+	os.WriteFile(array, []byte(`[{"input": "1+1", "expected": "2"}]`), 0644)
+	if dataset, err = eval.LoadDataset(array); err != nil || len(dataset) != 1 {
+		t.Fatalf("expected 1 case, got %+v: %v", dataset, err)
+	}
+}
+
+func TestScorers(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Exact", func(t *testing.T) {
+		score, _ := eval.ExactScorer(true).Score(ctx, eval.Case{Expected: "Paris"}, " paris\n")
+		if score.Value != 1 {
+			t.Fatalf("expected a match ignoring case and spaces, got %+v", score)
+		}
+		score, _ = eval.ExactScorer(false).Score(ctx, eval.Case{Expected: "Paris"}, "paris")
+		if score.Value != 0 {
+			t.Fatalf("expected no match, got %+v", score)
+		}
+	})
+
+	t.Run("Regex from the case", func(t *testing.T) {
+		score, err := eval.RegexScorer(nil).Score(ctx, eval.Case{Expected: `^\d+$`}, "42")
+		if err != nil || score.Value != 1 {
+			t.Fatalf("expected a match, got %+v: %v", score, err)
+		}
+		score, _ = eval.RegexScorer(regexp.MustCompile(`yes`)).Score(ctx, eval.Case{}, "no")
+		if score.Value != 0 {
+			t.Fatalf("expected no match, got %+v", score)
+		}
+	})
+
+	t.Run("Json fields", func(t *testing.T) {
+		c := eval.Case{Expected: `{"city": "Paris", "geo": {"country": "FR"}, "rank": 1}`}
+		output := "Here you go: {\"city\": \"Paris\", \"geo\": {\"country\": \"DE\"}, \"rank\": 1}"
+
+		score, err := eval.JSONFieldScorer("city", "geo.country").Score(ctx, c, output)
+		if err != nil || score.Value != 0.5 || !strings.Contains(score.Reason, "geo.country") {
+			t.Fatalf("expected half the fields to match, got %+v: %v", score, err)
+		}
+		score, _ = eval.JSONFieldScorer().Score(ctx, c, "not json")
+		if score.Value != 0 {
+			t.Fatalf("expected 0 without json, got %+v", score)
+		}
+	})
+
+	t.Run("Judge", func(t *testing.T) {
+		c := eval.Case{Input: "capital of France?", Expected: "Paris"}
+		client := llm.NewInterceptedClient(&fakeAnswerClient{}, func(ctx context.Context, messages []llm.LlmMessage, next llm.Invoker) (*llm.LlmReply, error) {
+			if !strings.Contains(messages[0].Content, "Lyon") {
+				t.Errorf("the judge prompt should hold the output")
+			}
+			return &llm.LlmReply{Content: "```json\n{\"score\": 3, \"reason\": \"wrong city\"}\n```"}, nil
+		})
+
+		score, err := eval.JudgeScorer(client, "").Score(ctx, c, "Lyon")
+		if err != nil || score.Value != 0.3 || score.Reason != "wrong city" {
+			t.Fatalf("expected the judge grade scaled to 0.3, got %+v: %v", score, err)
+		}
+	})
+}
+
+func TestRun(t *testing.T) {
+	dataset := eval.Dataset{
+		{ID: "add", Input: "1+1", Expected: "2"},
+		{ID: "mul", Input: "2*3", Expected: "6"},
+	}
+	targets := []eval.Target{
+		{Name: "good", Client: &fakeAnswerClient{answers: map[string]string{"1+1": "2", "2*3": "6"}}},
+		{Name: "bad", Client: &fakeAnswerClient{answers: map[string]string{"1+1": "3"}}},
+	}
+
+	report, err := eval.Run(context.Background(), dataset, targets, []eval.Scorer{eval.ExactScorer(false)}, eval.Config{Parallelism: 2})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if len(report.Results) != 4 {
+		t.Fatalf("expected 4 results, got %d", len(report.Results))
+	}
+
+	summaries := report.Summaries()
+	if summaries[0].Target != "good" || summaries[0].MeanScores["exact"] != 1 {
+		t.Fatalf("unexpected summary of good: %+v", summaries[0])
+	}
+	if summaries[1].Errors != 1 || summaries[1].MeanScores["exact"] != 0 {
+		t.Fatalf("unexpected summary of bad: %+v", summaries[1])
+	}
+	if best, _ := report.Best("exact"); best != "good" {
+		t.Fatalf("expected good to be best, got %s", best)
+	}
+
+	path := filepath.Join(t.TempDir(), "report.xlsx")
+	if err := report.WriteExcel(path); err != nil {
+		t.Fatalf("WriteExcel failed: %v", err)
+	}
+
+	workbook, err := excelize.OpenFile(path)
+	if err != nil {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+	defer workbook.Close()
+
+	rows, err := workbook.GetRows("Results")
+	if err != nil {
+		t.Fatalf("GetRows failed: %v", err)
+	}
+	if len(rows) != 5 || rows[0][0] != "Case" || rows[1][1] != "good" {
+		t.Fatalf("unexpected Results sheet: %v", rows)
+	}
+	if rows, _ := workbook.GetRows("Summary"); len(rows) != 3 {
+		t.Fatalf("expected a header and 2 summary rows, got %v", rows)
+	}
+}
+
+func TestRunScorerNames(t *testing.T) {
+	ctx := context.Background()
+	dataset := eval.Dataset{{ID: "yes", Input: "ok?", Expected: "yes"}}
+	targets := []eval.Target{{Name: "target", Client: &fakeAnswerClient{answers: map[string]string{"ok?": "yes"}}}}
+
+	scorers := []eval.Scorer{eval.RegexScorer(regexp.MustCompile(`yes`)), eval.RegexScorer(regexp.MustCompile(`no`))}
+	if _, err := eval.Run(ctx, dataset, targets, scorers, eval.Config{}); err == nil {
+		t.Fatalf("Run should fail on two scorers of the same name")
+	}
+
+	scorers[1] = eval.NamedScorer("regex-no", scorers[1])
+	report, err := eval.Run(ctx, dataset, targets, scorers, eval.Config{})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	scores := report.Results[0].Scores
+	if len(report.Scorers) != 2 || scores["regex"].Value != 1 || scores["regex-no"].Value != 0 {
+		t.Fatalf("expected both scores kept, got %v: %+v", report.Scorers, scores)
+	}
+}
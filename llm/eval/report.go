@@ -0,0 +1,119 @@
+package eval
+
+import (
+	"fmt"
+
+	"github.com/sieglu2/go_foundation/foundation"
+	"github.com/sieglu2/go_foundation/tools"
+)
+
+const (
+	summarySheet = "Summary"
+	resultsSheet = "Results"
+	scoresSheet  = "Scores"
+)
+
+type summaryRow struct {
+	Target        string  `excel:"Target"`
+	Scorer        string  `excel:"Scorer"`
+	MeanScore     float64 `excel:"Mean Score"`
+	Cases         int     `excel:"Cases"`
+	Errors        int     `excel:"Errors"`
+	MeanLatencyMs int64   `excel:"Mean Latency (ms)"`
+	TotalTokens   int     `excel:"Total Tokens"`
+}
+
+type resultRow struct {
+	Case      string `excel:"Case"`
+	Target    string `excel:"Target"`
+	Input     string `excel:"Input"`
+	Expected  string `excel:"Expected"`
+	Output    string `excel:"Output"`
+	Error     string `excel:"Error"`
+	LatencyMs int64  `excel:"Latency (ms)"`
+	Tokens    int    `excel:"Tokens"`
+}
+
+type scoreRow struct {
+	Case   string  `excel:"Case"`
+	Target string  `excel:"Target"`
+	Scorer string  `excel:"Scorer"`
+	Score  float64 `excel:"Score"`
+	Reason string  `excel:"Reason"`
+}
+
+// WriteExcel writes the report to path as a workbook with a Summary sheet of the mean
+// scores of every target and scorer, a Results sheet of the outputs and a Scores sheet
+// of every grade with its reason. The rows are long so they pivot by target or scorer.
+func (r *Report) WriteExcel(path string) error {
+	logger := foundation.Logger()
+
+	if len(r.Results) == 0 {
+		return tools.ErrNoDataToExport
+	}
+
+	writer, err := tools.CreateEmptyExcelSheet(path, []string{summarySheet, resultsSheet, scoresSheet})
+	if err != nil {
+		logger.Errorf("failed to CreateEmptyExcelSheet %s: %v", path, err)
+		return fmt.Errorf("failed to CreateEmptyExcelSheet %s: %v", path, err)
+	}
+
+	var summaryRows []any
+	for _, summary := range r.Summaries() {
+		for _, scorer := range r.Scorers {
+			summaryRows = append(summaryRows, summaryRow{
+				Target:        summary.Target,
+				Scorer:        scorer,
+				MeanScore:     summary.MeanScores[scorer],
+				Cases:         summary.Cases,
+				Errors:        summary.Errors,
+				MeanLatencyMs: summary.MeanLatency.Milliseconds(),
+				TotalTokens:   summary.Usage.TotalTokens,
+			})
+		}
+	}
+
+	var resultRows, scoreRows []any
+	for _, result := range r.Results {
+		resultRows = append(resultRows, resultRow{
+			Case:      result.Case.ID,
+			Target:    result.Target,
+			Input:     result.Case.Input,
+			Expected:  result.Case.Expected,
+			Output:    result.Output,
+			Error:     result.Error,
+			LatencyMs: result.Latency.Milliseconds(),
+			Tokens:    result.Usage.TotalTokens,
+		})
+		for _, scorer := range r.Scorers {
+			score := result.Scores[scorer]
+			scoreRows = append(scoreRows, scoreRow{
+				Case:   result.Case.ID,
+				Target: result.Target,
+				Scorer: scorer,
+				Score:  score.Value,
+				Reason: score.Reason,
+			})
+		}
+	}
+
+	for _, sheet := range []struct {
+		name string
+		rows []any
+	}{
+		{summarySheet, summaryRows},
+		{resultsSheet, resultRows},
+		{scoresSheet, scoreRows},
+	} {
+		if err := writer.AppendDataAsRows(sheet.name, "excel", sheet.rows); err != nil {
+			logger.Errorf("failed to write sheet %s: %v", sheet.name, err)
+			return fmt.Errorf("failed to write sheet %s: %v", sheet.name, err)
+		}
+	}
+
+	if err := writer.SaveFile(); err != nil {
+		logger.Errorf("failed to SaveFile %s: %v", path, err)
+		return fmt.Errorf("failed to SaveFile %s: %v", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+package eval
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/sieglu2/go_foundation/foundation"
+	"github.com/sieglu2/go_foundation/llm"
+)
+
+const DefaultParallelism = 4
+
+// Target is a client under evaluation, named in the report.
+type Target struct {
+	Name   string
+	Client llm.LlmClient
+}
+
+type Config struct {
+	// Parallelism is the number of cases run at once, DefaultParallelism if 0.
+	Parallelism int
+	// Timeout bounds every call to a target, no bound if 0.
+	Timeout time.Duration
+}
+
+// Result is the outcome of one case on one target.
+type Result struct {
+	Target  string
+	Case    Case
+	Output  string
+	Error   string
+	Scores  map[string]Score
+	Latency time.Duration
+	Usage   llm.LlmUsage
+}
+
+type Report struct {
+	Scorers []string
+	Results []Result
+}
+
+// Summary aggregates the results of a target.
+type Summary struct {
+	Target      string
+	Cases       int
+	Errors      int
+	MeanScores  map[string]float64
+	MeanLatency time.Duration
+	Usage       llm.LlmUsage
+}
+
+// Run runs every case of dataset on every target and scores the outputs. A failing call
+// or scorer is recorded in its Result, Run only fails on invalid arguments, such as two
+// scorers of the same name.
+func Run(ctx context.Context, dataset Dataset, targets []Target, scorers []Scorer, config Config) (*Report, error) {
+	logger := foundation.Logger()
+
+	if len(dataset) == 0 || len(targets) == 0 || len(scorers) == 0 {
+		logger.Errorf("dataset, targets and scorers must not be empty")
+		return nil, fmt.Errorf("dataset, targets and scorers must not be empty")
+	}
+	if config.Parallelism <= 0 {
+		config.Parallelism = DefaultParallelism
+	}
+
+	report := &Report{}
+	seen := make(map[string]bool, len(scorers))
+	for _, scorer := range scorers {
+		name := scorer.Name()
+		if seen[name] {
+			logger.Errorf("duplicate scorer name: %s", name)
+			return nil, fmt.Errorf("duplicate scorer name %s, rename one of them with NamedScorer", name)
+		}
+		seen[name] = true
+		report.Scorers = append(report.Scorers, name)
+	}
+
+	type job struct {
+		index  int
+		target Target
+		c      Case
+	}
+
+	results := make([]Result, len(dataset)*len(targets))
+	jobs := make(chan any, len(results))
+	for t, target := range targets {
+		for i, c := range dataset {
+			jobs <- job{index: t*len(dataset) + i, target: target, c: c}
+		}
+	}
+	close(jobs)
+
+	// every job writes its own index, the results need no lock
+	err := foundation.RunInParallel(config.Parallelism, 0, jobs, func(a any) error {
+		j := a.(job)
+		results[j.index] = runCase(ctx, j.target, j.c, scorers, config)
+		return nil
+	}, func(errs []error) error {
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	report.Results = results
+	return report, nil
+}
+
+func runCase(ctx context.Context, target Target, c Case, scorers []Scorer, config Config) Result {
+	logger := foundation.Logger()
+
+	result := Result{
+		Target: target.Name,
+		Case:   c,
+		Scores: map[string]Score{},
+	}
+
+	var messages []llm.LlmMessage
+	if c.System != "" {
+		messages = append(messages, llm.LlmMessage{Role: llm.RoleSystem, Content: c.System})
+	}
+	messages = append(messages, llm.LlmMessage{Role: llm.RoleUser, Content: c.Input})
+
+	callCtx := ctx
+	if config.Timeout > 0 {
+		var cancel context.CancelFunc
+		callCtx, cancel = context.WithTimeout(ctx, config.Timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	reply, err := llm.ReplyMessageDetail(callCtx, target.Client, messages)
+	result.Latency = time.Since(start)
+	if err != nil {
+		logger.Warnf("case %s failed on %s: %v", c.ID, target.Name, err)
+		result.Error = err.Error()
+		for _, scorer := range scorers {
+			result.Scores[scorer.Name()] = Score{Value: 0, Reason: "call failed"}
+		}
+		return result
+	}
+	result.Output = reply.Content
+	result.Usage = reply.Usage
+
+	for _, scorer := range scorers {
+		score, err := scorer.Score(ctx, c, reply.Content)
+		if err != nil {
+			logger.Warnf("scorer %s failed on case %s of %s: %v", scorer.Name(), c.ID, target.Name, err)
+			score = Score{Value: 0, Reason: fmt.Sprintf("scorer failed: %v", err)}
+		}
+		result.Scores[scorer.Name()] = score
+	}
+	return result
+}
+
+// Summaries aggregates the results per target, in the order of the targets.
+func (r *Report) Summaries() []Summary {
+	var order []string
+	summaries := map[string]*Summary{}
+	latencies := map[string]time.Duration{}
+
+	for _, result := range r.Results {
+		summary, ok := summaries[result.Target]
+		if !ok {
+			summary = &Summary{Target: result.Target, MeanScores: map[string]float64{}}
+			summaries[result.Target] = summary
+			order = append(order, result.Target)
+		}
+
+		summary.Cases++
+		if result.Error != "" {
+			summary.Errors++
+		}
+		for name, score := range result.Scores {
+			summary.MeanScores[name] += score.Value
+		}
+		latencies[result.Target] += result.Latency
+		summary.Usage.PromptTokens += result.Usage.PromptTokens
+		summary.Usage.CompletionTokens += result.Usage.CompletionTokens
+		summary.Usage.TotalTokens += result.Usage.TotalTokens
+	}
+
+	result := make([]Summary, 0, len(order))
+	for _, target := range order {
+		summary := summaries[target]
+		for name := range summary.MeanScores {
+			summary.MeanScores[name] /= float64(summary.Cases)
+		}
+		summary.MeanLatency = latencies[target] / time.Duration(summary.Cases)
+		result = append(result, *summary)
+	}
+	return result
+}
+
+// Best returns the target with the highest mean score of the given scorer.
+func (r *Report) Best(scorer string) (string, float64) {
+	summaries := r.Summaries()
+	sort.SliceStable(summaries, func(i, j int) bool {
+		return summaries[i].MeanScores[scorer] > summaries[j].MeanScores[scorer]
+	})
+	if len(summaries) == 0 {
+		return "", 0
+	}
+	return summaries[0].Target, summaries[0].MeanScores[scorer]
+}
@@ -0,0 +1,200 @@
+package eval
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/sieglu2/go_foundation/llm"
+	"github.com/sieglu2/go_foundation/llm/parse"
+)
+
+// Score is the grade of an output, from 0 to 1.
+type Score struct {
+	Value  float64
+	Reason string
+}
+
+type Scorer interface {
+	Name() string
+	Score(ctx context.Context, c Case, output string) (Score, error)
+}
+
+type namedScorer struct {
+	Scorer
+	name string
+}
+
+// NamedScorer gives scorer another name in the results and the report, so several scorers
+// of the same kind, such as two RegexScorer with different patterns, can run together.
+func NamedScorer(name string, scorer Scorer) Scorer {
+	return &namedScorer{Scorer: scorer, name: name}
+}
+
+func (s *namedScorer) Name() string {
+	return s.name
+}
+
+type exactScorer struct {
+	ignoreCase bool
+}
+
+// ExactScorer gives 1 when the output equals the expected one, surrounding spaces ignored.
+func ExactScorer(ignoreCase bool) Scorer {
+	return &exactScorer{ignoreCase: ignoreCase}
+}
+
+func (s *exactScorer) Name() string {
+	return "exact"
+}
+
+func (s *exactScorer) Score(ctx context.Context, c Case, output string) (Score, error) {
+	expected, actual := strings.TrimSpace(c.Expected), strings.TrimSpace(output)
+	if expected == actual || (s.ignoreCase && strings.EqualFold(expected, actual)) {
+		return Score{Value: 1}, nil
+	}
+	return Score{Value: 0}, nil
+}
+
+type regexScorer struct {
+	pattern *regexp.Regexp
+}
+
+// RegexScorer gives 1 when the output matches pattern, or the expected output of the case
+// taken as a regular expression when pattern is nil.
+func RegexScorer(pattern *regexp.Regexp) Scorer {
+	return &regexScorer{pattern: pattern}
+}
+
+func (s *regexScorer) Name() string {
+	return "regex"
+}
+
+func (s *regexScorer) Score(ctx context.Context, c Case, output string) (Score, error) {
+	pattern := s.pattern
+	if pattern == nil {
+		var err error
+		if pattern, err = regexp.Compile(c.Expected); err != nil {
+			return Score{}, fmt.Errorf("failed to compile the expected output of case %s: %v", c.ID, err)
+		}
+	}
+
+	if pattern.MatchString(output) {
+		return Score{Value: 1}, nil
+	}
+	return Score{Value: 0, Reason: fmt.Sprintf("no match for %s", pattern)}, nil
+}
+
+type jsonFieldScorer struct {
+	fields []string
+}
+
+// JSONFieldScorer compares the given fields, dot separated for nested objects, of the json
+// in the output with the ones of the expected json. The score is the share of fields that
+// match, all the expected fields when none are given.
+func JSONFieldScorer(fields ...string) Scorer {
+	return &jsonFieldScorer{fields: fields}
+}
+
+func (s *jsonFieldScorer) Name() string {
+	return "json"
+}
+
+func (s *jsonFieldScorer) Score(ctx context.Context, c Case, output string) (Score, error) {
+	var expected map[string]any
+	if err := json.Unmarshal([]byte(c.Expected), &expected); err != nil {
+		return Score{}, fmt.Errorf("failed to Unmarshal the expected output of case %s: %v", c.ID, err)
+	}
+
+	actual, err := parse.ParseJSON[map[string]any](output)
+	if err != nil {
+		return Score{Value: 0, Reason: err.Error()}, nil
+	}
+
+	fields := s.fields
+	if len(fields) == 0 {
+		for field := range expected {
+			fields = append(fields, field)
+		}
+	}
+	if len(fields) == 0 {
+		return Score{Value: 1}, nil
+	}
+
+	var mismatches []string
+	for _, field := range fields {
+		if !reflect.DeepEqual(lookupField(expected, field), lookupField(actual, field)) {
+			mismatches = append(mismatches, field)
+		}
+	}
+
+	score := Score{Value: float64(len(fields)-len(mismatches)) / float64(len(fields))}
+	if len(mismatches) > 0 {
+		score.Reason = "mismatched fields: " + strings.Join(mismatches, ", ")
+	}
+	return score, nil
+}
+
+func lookupField(value map[string]any, path string) any {
+	var current any = value
+	for _, name := range strings.Split(path, ".") {
+		object, ok := current.(map[string]any)
+		if !ok {
+			return nil
+		}
+		current = object[name]
+	}
+	return current
+}
+
+const judgePrompt = `You grade the answer of an assistant against the expected answer.
+%s
+Reply with json only: {"score": <0 to 10>, "reason": "<one sentence>"}
+
+Question:
+%s
+
+Expected answer:
+%s
+
+Assistant answer:
+%s`
+
+type judgeScorer struct {
+	judge  llm.LlmClient
+	rubric string
+}
+
+// JudgeScorer asks the judge client to grade the output from 0 to 10 against the expected
+// one, following the rubric if not empty. The grade is scaled to 0 to 1.
+func JudgeScorer(judge llm.LlmClient, rubric string) Scorer {
+	return &judgeScorer{judge: judge, rubric: rubric}
+}
+
+func (s *judgeScorer) Name() string {
+	return "judge"
+}
+
+func (s *judgeScorer) Score(ctx context.Context, c Case, output string) (Score, error) {
+	prompt := fmt.Sprintf(judgePrompt, s.rubric, c.Input, c.Expected, output)
+	reply, err := s.judge.ReplyMessage(ctx, []llm.LlmMessage{{Role: llm.RoleUser, Content: prompt}})
+	if err != nil {
+		return Score{}, fmt.Errorf("failed to ask the judge: %v", err)
+	}
+
+	grade, err := parse.ParseJSON[struct {
+		Score  float64 `json:"score"`
+		Reason string  `json:"reason"`
+	}](reply)
+	if err != nil {
+		return Score{}, fmt.Errorf("failed to parse the judge reply: %v", err)
+	}
+	if grade.Score < 0 || grade.Score > 10 {
+		return Score{}, fmt.Errorf("judge score %v is out of the 0 to 10 range", grade.Score)
+	}
+
+	return Score{Value: grade.Score / 10, Reason: grade.Reason}, nil
+}
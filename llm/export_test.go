@@ -0,0 +1,31 @@
+package llm
+
+import (
+	"context"
+
+	"github.com/google/generative-ai-go/genai"
+	"google.golang.org/api/option"
+)
+
+// The unexported helpers tested from llm_test.
+
+var NewGeminiBlockedError = newGeminiBlockedError
+
+var ParseRateLimitHeaders = parseRateLimitHeaders
+
+func (g *GeminiClient) SafetySettings() []*genai.SafetySetting {
+	return g.safetySettings()
+}
+
+// NewGeminiClientWithEndpoint creates a client of a fake gemini api served at endpoint.
+func NewGeminiClientWithEndpoint(ctx context.Context, endpoint string) (*GeminiClient, error) {
+	client, err := genai.NewClient(ctx, option.WithAPIKey("test-key"), option.WithEndpoint(endpoint))
+	if err != nil {
+		return nil, err
+	}
+	return &GeminiClient{
+		client:    client,
+		maxTokens: 100,
+		model:     defaultGeminiModel,
+	}, nil
+}
@@ -0,0 +1,86 @@
+package llm_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/sieglu2/go_foundation/llm"
+)
+
+// fakeClient is the LlmClient of the tests, safe for concurrent calls. Its first calls fail
+// with errs in order, the next ones reply with reply, or echo the last message if it is nil.
+// With block set, a call signals started and waits for block to be closed.
+type fakeClient struct {
+	errs  []error
+	reply func(call int, messages []llm.LlmMessage) (*llm.LlmReply, error)
+
+	started chan struct{}
+	block   chan struct{}
+
+	mu       sync.Mutex
+	calls    int
+	received []llm.LlmMessage
+	closed   atomic.Bool
+}
+
+// fixedReply makes every call of a fakeClient return a copy of reply.
+func fixedReply(reply llm.LlmReply) func(int, []llm.LlmMessage) (*llm.LlmReply, error) {
+	return func(int, []llm.LlmMessage) (*llm.LlmReply, error) {
+		copied := reply
+		return &copied, nil
+	}
+}
+
+func (f *fakeClient) ReplyMessage(ctx context.Context, messages []llm.LlmMessage) (string, error) {
+	reply, err := f.ReplyMessageDetail(ctx, messages)
+	if err != nil {
+		return "", err
+	}
+	return reply.Content, nil
+}
+
+func (f *fakeClient) ReplyMessageDetail(ctx context.Context, messages []llm.LlmMessage) (*llm.LlmReply, error) {
+	call := f.record(messages)
+	if f.block != nil {
+		f.started <- struct{}{}
+		<-f.block
+	}
+	if call <= len(f.errs) {
+		return nil, f.errs[call-1]
+	}
+	if f.reply != nil {
+		return f.reply(call, messages)
+	}
+	return &llm.LlmReply{
+		Content: messages[len(messages)-1].Content,
+		Usage:   llm.LlmUsage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15},
+	}, nil
+}
+
+func (f *fakeClient) Close() error {
+	f.closed.Store(true)
+	return nil
+}
+
+// record counts a call and keeps its messages, it returns the number of the call from 1.
+func (f *fakeClient) record(messages []llm.LlmMessage) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	f.received = messages
+	return f.calls
+}
+
+func (f *fakeClient) Calls() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls
+}
+
+// Received returns the messages of the last call.
+func (f *fakeClient) Received() []llm.LlmMessage {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.received
+}
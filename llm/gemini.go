@@ -5,6 +5,7 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/google/generative-ai-go/genai"
 	"github.com/sieglu2/go_foundation/foundation"
@@ -12,18 +13,18 @@ import (
 )
 
 const (
-	geminiSecretAccountName string = "my_google"
-	geminiSecretServiceName string = "gemini"
-
 	GEMINI_EMBEDDINGS_MAX_TOKEN = 2048
 
 	defaultGeminiModel = "gemini-1.5-pro"
 )
 
 type GeminiClient struct {
-	client    *genai.Client
-	maxTokens int32
-	model     string
+	client         *genai.Client
+	maxTokens      int32
+	model          string
+	embeddingModel string
+
+	safetyThresholds map[genai.HarmCategory]genai.HarmBlockThreshold
 }
 
 func NewGeminiClient(ctx context.Context, apiKey string) (*GeminiClient, error) {
@@ -36,9 +37,10 @@ func NewGeminiClientWithConfig(ctx context.Context, apiKey string, maxTokens int
 		return nil, err
 	}
 	return &GeminiClient{
-		client:    client,
-		maxTokens: maxTokens,
-		model:     model,
+		client:         client,
+		maxTokens:      maxTokens,
+		model:          model,
+		embeddingModel: defaultGeminiEmbeddingModel,
 	}, nil
 }
 
@@ -49,66 +51,180 @@ func (m *GeminiClient) Close() error {
 func (g *GeminiClient) ReplyMessage(
 	ctx context.Context, llmMessages []LlmMessage,
 ) (string, error) {
+	reply, err := g.ReplyMessageDetail(ctx, llmMessages)
+	if err != nil {
+		return "", err
+	}
+	return reply.Content, nil
+}
+
+func (g *GeminiClient) ReplyMessageDetail(
+	ctx context.Context, llmMessages []LlmMessage,
+) (*LlmReply, error) {
+	return g.sendMessage(ctx, llmMessages, 1)
+}
+
+// ReplyCandidates asks Gemini for n alternative replies in a single request. The chat
+// session always asks for one candidate, so a conversation with history is asked n times
+// in parallel instead, as is a request one of whose candidates was blocked.
+func (g *GeminiClient) ReplyCandidates(
+	ctx context.Context, llmMessages []LlmMessage, n int,
+) (*LlmReply, error) {
+	if n > 1 && !isGeminiSingleTurn(llmMessages) {
+		return replyCandidatesInParallel(ctx, g, llmMessages, n)
+	}
+	return g.sendMessage(ctx, llmMessages, n)
+}
+
+// isGeminiSingleTurn is whether the messages before the last one are all system messages,
+// the request then holding the last message only.
+func isGeminiSingleTurn(llmMessages []LlmMessage) bool {
+	for _, message := range llmMessages[:max(len(llmMessages)-1, 0)] {
+		if message.Role != RoleSystem {
+			return false
+		}
+	}
+	return true
+}
+
+func (g *GeminiClient) sendMessage(
+	ctx context.Context, llmMessages []LlmMessage, n int,
+) (*LlmReply, error) {
 	logger := foundation.Logger()
 
 	if len(llmMessages) == 0 {
 		logger.Errorf("empty messages array")
-		return "", fmt.Errorf("empty messages array")
+		return nil, fmt.Errorf("empty messages array")
 	}
 	currentMessage := llmMessages[len(llmMessages)-1]
 	if currentMessage.Role != RoleUser {
 		logger.Errorf("last message must have the user role")
-		return "", fmt.Errorf("last message must have the user role")
+		return nil, fmt.Errorf("last message must have the user role")
 	}
 
 	// manually get the last message out as the current message to fit into gemini's API mechanism.
+	allMessages := llmMessages
 	llmMessages = llmMessages[:len(llmMessages)-1]
 	currentContent, err := convertToContent(currentMessage)
 	if err != nil {
 		logger.Errorf("failed to convert LlmMessage to genai.Content: %v", err)
-		return "", fmt.Errorf("failed to convert LlmMessage to genai.Content: %v", err)
+		return nil, fmt.Errorf("failed to convert LlmMessage to genai.Content: %v", err)
 	}
 
-	contents, err := convertToGeminiContents(llmMessages)
-	if err != nil {
-		logger.Errorf("failed to convert to Gemini contents: %v", err)
-		return "", fmt.Errorf("failed to convert to Gemini contents: %v", err)
+	var contents []*genai.Content
+	if len(llmMessages) > 0 {
+		contents, err = convertToGeminiContents(llmMessages)
+		if err != nil {
+			logger.Errorf("failed to convert to Gemini contents: %v", err)
+			return nil, fmt.Errorf("failed to convert to Gemini contents: %v", err)
+		}
 	}
 
 	model := g.client.GenerativeModel(g.model)
 	model.SetMaxOutputTokens(g.maxTokens)
-	chat := model.StartChat()
-
-	// manually overwrite the history with manual saved history
-	chat.History = contents
+	model.SafetySettings = g.safetySettings()
 
 	logger.Infof("sending request to Gemini model: %s", g.model)
-	resp, err := chat.SendMessage(ctx, currentContent.Parts...)
+	var resp *genai.GenerateContentResponse
+	if n > 1 {
+		// a single turn, the history holds the system messages only
+		model.SetCandidateCount(int32(n))
+		if len(contents) > 0 {
+			model.SystemInstruction = &genai.Content{}
+			for _, content := range contents {
+				model.SystemInstruction.Parts = append(model.SystemInstruction.Parts, content.Parts...)
+			}
+		}
+		resp, err = model.GenerateContent(ctx, currentContent.Parts...)
+	} else {
+		chat := model.StartChat()
+		// manually overwrite the history with manual saved history
+		chat.History = contents
+		resp, err = chat.SendMessage(ctx, currentContent.Parts...)
+	}
+	var blockedErr *genai.BlockedError
+	if errors.As(err, &blockedErr) {
+		// the sdk drops the whole response when one of the candidates is blocked, they are
+		// asked one by one instead so the others are kept
+		if n > 1 && blockedErr.Candidate != nil && blockedErr.PromptFeedback == nil {
+			logger.Warnf("a candidate was blocked (%s), asking the %d candidates one by one", blockedErr.Candidate.FinishReason, n)
+			return replyCandidatesInParallel(ctx, g, allMessages, n)
+		}
+		err = newGeminiBlockedError(blockedErr.PromptFeedback, blockedErr.Candidate)
+		logger.Errorf("%v", err)
+		return nil, err
+	}
 	if err != nil {
 		logger.Errorf("failed to generate content: %v", err)
-		return "", fmt.Errorf("failed to generate content: %v", err)
+		return nil, fmt.Errorf("failed to generate content: %w", err)
 	}
 
+	if resp != nil && len(resp.Candidates) == 0 && resp.PromptFeedback != nil {
+		err := newGeminiBlockedError(resp.PromptFeedback, nil)
+		logger.Errorf("%v", err)
+		return nil, err
+	}
 	if resp == nil || len(resp.Candidates) == 0 {
 		logger.Errorf("empty response from Gemini")
-		return "", errors.New("empty response from Gemini")
+		return nil, errors.New("empty response from Gemini")
 	}
 
-	candidate := resp.Candidates[0]
-	if candidate.Content == nil || len(candidate.Content.Parts) == 0 {
+	// the blocked or empty candidates are dropped, the call fails only if all of them are
+	var usable []*genai.Candidate
+	for _, candidate := range resp.Candidates {
+		if candidate.Content != nil && len(candidate.Content.Parts) > 0 {
+			usable = append(usable, candidate)
+		}
+	}
+	if len(usable) == 0 {
+		candidate := resp.Candidates[0]
+		// reasons newer than the sdk, such as blocklist or prohibited content, end here
+		if candidate.FinishReason != genai.FinishReasonStop && candidate.FinishReason != genai.FinishReasonMaxTokens {
+			err := newGeminiBlockedError(resp.PromptFeedback, candidate)
+			logger.Errorf("%v", err)
+			return nil, err
+		}
 		logger.Errorf("empty content in response")
-		return "", errors.New("empty content in response")
+		return nil, errors.New("empty content in response")
+	}
+	if len(usable) < len(resp.Candidates) {
+		logger.Warnf("dropped %d blocked or empty candidates of %d", len(resp.Candidates)-len(usable), len(resp.Candidates))
 	}
 
-	textResponse := ""
-	for _, part := range candidate.Content.Parts {
-		if textPart, ok := part.(genai.Text); ok {
-			textResponse += string(textPart)
+	reply := &LlmReply{
+		Content:      geminiCandidateText(usable[0]),
+		Model:        g.model,
+		FinishReason: usable[0].FinishReason.String(),
+	}
+	for _, candidate := range usable {
+		reply.Candidates = append(reply.Candidates, LlmCandidate{
+			Content:      geminiCandidateText(candidate),
+			FinishReason: candidate.FinishReason.String(),
+		})
+	}
+	if resp.UsageMetadata != nil {
+		reply.Usage = LlmUsage{
+			PromptTokens:     int(resp.UsageMetadata.PromptTokenCount),
+			CompletionTokens: int(resp.UsageMetadata.CandidatesTokenCount),
+			TotalTokens:      int(resp.UsageMetadata.TotalTokenCount),
 		}
 	}
 
 	logger.Infof("received Gemini response")
-	return textResponse, nil
+	return reply, nil
+}
+
+func geminiCandidateText(candidate *genai.Candidate) string {
+	text := ""
+	if candidate.Content == nil {
+		return text
+	}
+	for _, part := range candidate.Content.Parts {
+		if textPart, ok := part.(genai.Text); ok {
+			text += string(textPart)
+		}
+	}
+	return text
 }
 
 func convertToGeminiContents(llmMessages []LlmMessage) ([]*genai.Content, error) {
@@ -165,10 +281,10 @@ func convertToContent(message LlmMessage) (*genai.Content, error) {
 	if len(message.B64Image) > 0 {
 		decoded, err := base64.StdEncoding.DecodeString(message.B64Image)
 		if err != nil {
-			logger.Errorf("failed to base64 decode png bytes: %v", err)
+			logger.Errorf("failed to base64 decode image bytes: %v", err)
 			return nil, err
 		}
-		imagePart := genai.ImageData("png", []byte(decoded))
+		imagePart := genai.ImageData(strings.TrimPrefix(message.imageMediaType(), "image/"), []byte(decoded))
 		content.Parts = append(content.Parts, imagePart)
 	}
 	return content, nil
@@ -0,0 +1,111 @@
+package llm
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// ErrContentBlocked is matched by the errors of the calls a provider refused for safety
+// or policy reasons.
+var ErrContentBlocked = errors.New("content blocked")
+
+type GeminiSafetyRating struct {
+	Category    string `json:"category"`
+	Probability string `json:"probability"`
+	Blocked     bool   `json:"blocked"`
+}
+
+// GeminiBlockedError reports why Gemini blocked the prompt or the reply, with the safety
+// ratings it gave to each.
+type GeminiBlockedError struct {
+	// PromptBlockReason is set when the prompt was blocked.
+	PromptBlockReason   string
+	PromptSafetyRatings []GeminiSafetyRating
+	// FinishReason is set when the reply was blocked.
+	FinishReason           string
+	CandidateSafetyRatings []GeminiSafetyRating
+}
+
+func (e *GeminiBlockedError) Error() string {
+	var reasons []string
+	if e.PromptBlockReason != "" {
+		reasons = append(reasons, fmt.Sprintf("prompt blocked: %s%s", e.PromptBlockReason, blockedCategories(e.PromptSafetyRatings)))
+	}
+	if e.FinishReason != "" {
+		reasons = append(reasons, fmt.Sprintf("reply blocked: %s%s", e.FinishReason, blockedCategories(e.CandidateSafetyRatings)))
+	}
+	if len(reasons) == 0 {
+		return "content blocked by Gemini"
+	}
+	return "content blocked by Gemini, " + strings.Join(reasons, ", ")
+}
+
+func (e *GeminiBlockedError) Is(target error) bool {
+	return target == ErrContentBlocked
+}
+
+func blockedCategories(ratings []GeminiSafetyRating) string {
+	var categories []string
+	for _, rating := range ratings {
+		if rating.Blocked {
+			categories = append(categories, fmt.Sprintf("%s=%s", rating.Category, rating.Probability))
+		}
+	}
+	if len(categories) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(categories, ", ") + ")"
+}
+
+// SetSafetyThreshold changes the probability of harm from which Gemini blocks the content
+// of category, e.g. genai.HarmBlockOnlyHigh for genai.HarmCategoryDangerousContent. The
+// categories not set keep Gemini's default threshold.
+func (g *GeminiClient) SetSafetyThreshold(category genai.HarmCategory, threshold genai.HarmBlockThreshold) {
+	if g.safetyThresholds == nil {
+		g.safetyThresholds = map[genai.HarmCategory]genai.HarmBlockThreshold{}
+	}
+	g.safetyThresholds[category] = threshold
+}
+
+func (g *GeminiClient) safetySettings() []*genai.SafetySetting {
+	var settings []*genai.SafetySetting
+	for category, threshold := range g.safetyThresholds {
+		settings = append(settings, &genai.SafetySetting{Category: category, Threshold: threshold})
+	}
+	sort.Slice(settings, func(i, j int) bool {
+		return settings[i].Category < settings[j].Category
+	})
+	return settings
+}
+
+// newGeminiBlockedError builds the error of a blocked prompt or candidate, either may be nil.
+func newGeminiBlockedError(feedback *genai.PromptFeedback, candidate *genai.Candidate) *GeminiBlockedError {
+	blockedErr := &GeminiBlockedError{}
+	if feedback != nil {
+		if feedback.BlockReason != genai.BlockReasonUnspecified {
+			blockedErr.PromptBlockReason = feedback.BlockReason.String()
+		}
+		blockedErr.PromptSafetyRatings = convertSafetyRatings(feedback.SafetyRatings)
+	}
+	if candidate != nil {
+		blockedErr.FinishReason = candidate.FinishReason.String()
+		blockedErr.CandidateSafetyRatings = convertSafetyRatings(candidate.SafetyRatings)
+	}
+	return blockedErr
+}
+
+func convertSafetyRatings(ratings []*genai.SafetyRating) []GeminiSafetyRating {
+	var converted []GeminiSafetyRating
+	for _, rating := range ratings {
+		converted = append(converted, GeminiSafetyRating{
+			Category:    rating.Category.String(),
+			Probability: rating.Probability.String(),
+			Blocked:     rating.Blocked,
+		})
+	}
+	return converted
+}
@@ -0,0 +1,152 @@
+package llm_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/generative-ai-go/genai"
+	"github.com/sieglu2/go_foundation/llm"
+)
+
+func TestGeminiBlockedError(t *testing.T) {
+	var err error = &llm.GeminiBlockedError{
+		FinishReason: "FinishReasonSafety",
+		CandidateSafetyRatings: []llm.GeminiSafetyRating{
+			{Category: "HarmCategoryHarassment", Probability: "HarmProbabilityLow"},
+			{Category: "HarmCategoryDangerousContent", Probability: "HarmProbabilityHigh", Blocked: true},
+		},
+	}
+	err = fmt.Errorf("failed to reply: %w", err)
+
+	if !errors.Is(err, llm.ErrContentBlocked) {
+		t.Fatalf("expected ErrContentBlocked, got: %v", err)
+	}
+	if !strings.Contains(err.Error(), "reply blocked: FinishReasonSafety (HarmCategoryDangerousContent=HarmProbabilityHigh)") {
+		t.Fatalf("the error should name the blocking rating, got: %v", err)
+	}
+
+	var blockedErr *llm.GeminiBlockedError
+	if !errors.As(err, &blockedErr) || len(blockedErr.CandidateSafetyRatings) != 2 {
+		t.Fatalf("expected the ratings in the error, got: %+v", blockedErr)
+	}
+}
+
+func TestNewGeminiBlockedError(t *testing.T) {
+	t.Run("Blocked prompt", func(t *testing.T) {
+		err := llm.NewGeminiBlockedError(&genai.PromptFeedback{
+			BlockReason: genai.BlockReasonSafety,
+			SafetyRatings: []*genai.SafetyRating{
+				{Category: genai.HarmCategoryHateSpeech, Probability: genai.HarmProbabilityHigh, Blocked: true},
+			},
+		}, nil)
+
+		if err.PromptBlockReason != genai.BlockReasonSafety.String() || err.FinishReason != "" {
+			t.Fatalf("unexpected error: %+v", err)
+		}
+		if len(err.PromptSafetyRatings) != 1 || !err.PromptSafetyRatings[0].Blocked ||
+			err.PromptSafetyRatings[0].Category != genai.HarmCategoryHateSpeech.String() {
+			t.Fatalf("unexpected prompt ratings: %+v", err.PromptSafetyRatings)
+		}
+		if !errors.Is(err, llm.ErrContentBlocked) {
+			t.Fatalf("expected ErrContentBlocked, got: %v", err)
+		}
+	})
+
+	t.Run("Blocked candidate", func(t *testing.T) {
+		err := llm.NewGeminiBlockedError(&genai.PromptFeedback{}, &genai.Candidate{
+			FinishReason: genai.FinishReasonSafety,
+			SafetyRatings: []*genai.SafetyRating{
+				{Category: genai.HarmCategoryHarassment, Probability: genai.HarmProbabilityLow},
+				{Category: genai.HarmCategoryDangerousContent, Probability: genai.HarmProbabilityHigh, Blocked: true},
+			},
+		})
+
+		if err.PromptBlockReason != "" || err.FinishReason != genai.FinishReasonSafety.String() {
+			t.Fatalf("unexpected error: %+v", err)
+		}
+		if len(err.CandidateSafetyRatings) != 2 || err.CandidateSafetyRatings[0].Blocked || !err.CandidateSafetyRatings[1].Blocked {
+			t.Fatalf("unexpected candidate ratings: %+v", err.CandidateSafetyRatings)
+		}
+		if !strings.Contains(err.Error(), genai.HarmCategoryDangerousContent.String()) {
+			t.Fatalf("the error should name the blocking rating, got: %v", err)
+		}
+	})
+}
+
+func TestGeminiSafetySettings(t *testing.T) {
+	client := &llm.GeminiClient{}
+	if settings := client.SafetySettings(); len(settings) != 0 {
+		t.Fatalf("expected the provider defaults, got %+v", settings)
+	}
+
+	client.SetSafetyThreshold(genai.HarmCategoryDangerousContent, genai.HarmBlockOnlyHigh)
+	client.SetSafetyThreshold(genai.HarmCategoryHarassment, genai.HarmBlockNone)
+	client.SetSafetyThreshold(genai.HarmCategoryDangerousContent, genai.HarmBlockLowAndAbove)
+
+	settings := client.SafetySettings()
+	if len(settings) != 2 {
+		t.Fatalf("expected 2 settings, got %+v", settings)
+	}
+	if settings[0].Category != genai.HarmCategoryHarassment || settings[0].Threshold != genai.HarmBlockNone {
+		t.Fatalf("unexpected first setting: %+v", settings[0])
+	}
+	if settings[1].Category != genai.HarmCategoryDangerousContent || settings[1].Threshold != genai.HarmBlockLowAndAbove {
+		t.Fatalf("expected the last threshold set to win, got %+v", settings[1])
+	}
+}
+
+func TestGeminiCandidatesWithBlockedOne(t *testing.T) {
+	// a request for several candidates gets one of them blocked, the single requests fail
+	// with a server error, as the streamed replies of the chat session are not served here
+	var mu sync.Mutex
+	var candidateCounts []int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			GenerationConfig struct {
+				CandidateCount int `json:"candidateCount"`
+			} `json:"generationConfig"`
+		}
+		json.NewDecoder(r.Body).Decode(&request)
+
+		mu.Lock()
+		candidateCounts = append(candidateCounts, request.GenerationConfig.CandidateCount)
+		mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		if request.GenerationConfig.CandidateCount <= 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"error": {"code": 500, "message": "single candidate", "status": "INTERNAL"}}`))
+			return
+		}
+		w.Write([]byte(`{"candidates": [
+			{"content": {"role": "model", "parts": [{"text": "ok"}]}, "finishReason": "STOP"},
+			{"finishReason": "SAFETY", "safetyRatings": [{"category": "HARM_CATEGORY_HARASSMENT", "probability": "HIGH", "blocked": true}]}
+		]}`))
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	client, err := llm.NewGeminiClientWithEndpoint(ctx, server.URL)
+	if err != nil {
+		t.Fatalf("NewGeminiClientWithEndpoint failed: %v", err)
+	}
+	defer client.Close()
+
+	_, err = llm.ReplyCandidates(ctx, client, []llm.LlmMessage{{Role: llm.RoleUser, Content: "hi"}}, 2)
+	if errors.Is(err, llm.ErrContentBlocked) {
+		t.Fatalf("one blocked candidate should not fail the call: %v", err)
+	}
+	if err == nil || !strings.Contains(err.Error(), "single candidate") {
+		t.Fatalf("expected the error of the single requests, got: %v", err)
+	}
+	if len(candidateCounts) != 3 || candidateCounts[0] != 2 || candidateCounts[1] != 1 || candidateCounts[2] != 1 {
+		t.Fatalf("expected a request for 2 candidates, then 2 single ones, got %v", candidateCounts)
+	}
+}
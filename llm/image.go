@@ -0,0 +1,402 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/sieglu2/go_foundation/foundation"
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	MediaTypePng  = "image/png"
+	MediaTypeJpeg = "image/jpeg"
+	MediaTypeGif  = "image/gif"
+	MediaTypeWebp = "image/webp"
+
+	// maxImageDownload caps the size of an image loaded from a url.
+	maxImageDownload = 64 * 1024 * 1024
+	// minImageDimension is the long edge below which Fit gives up shrinking.
+	minImageDimension = 64
+	// maxDecodedPixels caps the images decoded, a small file can declare huge dimensions
+	// taking gigabytes once decoded.
+	maxDecodedPixels = 50 * 1000 * 1000
+)
+
+var ErrUnsupportedImage = errors.New("unsupported image")
+
+var ErrImageTooLarge = errors.New("image too large")
+
+// jpegQualities are tried in order when recompressing an image to fit a size limit.
+var jpegQualities = []int{85, 75, 60, 45}
+
+// ImageLimits are what a provider accepts for an image input. Zero fields are unlimited.
+type ImageLimits struct {
+	// MaxDimension is the longest edge in pixels. Providers downscale larger images
+	// themselves, sending them only costs upload time and tokens.
+	MaxDimension int
+	MaxPixels    int
+	// MaxBytes is the size of the encoded image, before base64.
+	MaxBytes int
+	// MediaTypes are the accepted formats, others are converted.
+	MediaTypes []string
+}
+
+var defaultImageMediaTypes = []string{MediaTypePng, MediaTypeJpeg, MediaTypeGif, MediaTypeWebp}
+
+// ProviderImageLimits are the documented limits of the providers with vision, rounded
+// down where they are approximate.
+var ProviderImageLimits = map[string]ImageLimits{
+	ProviderClaude:  {MaxDimension: 1568, MaxPixels: 1568 * 1568, MaxBytes: 5 * 1024 * 1024, MediaTypes: defaultImageMediaTypes},
+	ProviderChatGpt: {MaxDimension: 2048, MaxBytes: 20 * 1024 * 1024, MediaTypes: defaultImageMediaTypes},
+	ProviderGemini:  {MaxDimension: 3072, MaxBytes: 15 * 1024 * 1024, MediaTypes: []string{MediaTypePng, MediaTypeJpeg, MediaTypeWebp}},
+	ProviderMinimax: {MaxDimension: 2048, MaxBytes: 10 * 1024 * 1024, MediaTypes: []string{MediaTypePng, MediaTypeJpeg}},
+}
+
+// ImageLimitsForProvider returns the limits of provider, the strictest ones for a provider
+// without known limits.
+func ImageLimitsForProvider(provider string) ImageLimits {
+	if limits, ok := ProviderImageLimits[provider]; ok {
+		return limits
+	}
+	return ProviderImageLimits[ProviderClaude]
+}
+
+// Image is an encoded image ready to be sent along a message.
+type Image struct {
+	Data      []byte
+	MediaType string
+}
+
+// NewImage wraps encoded image data, sniffing its format.
+func NewImage(data []byte) (*Image, error) {
+	logger := foundation.Logger()
+
+	mediaType := http.DetectContentType(data)
+	switch mediaType {
+	case MediaTypePng, MediaTypeJpeg, MediaTypeGif, MediaTypeWebp:
+		return &Image{Data: data, MediaType: mediaType}, nil
+	}
+	logger.Errorf("unsupported image of type %s", mediaType)
+	return nil, fmt.Errorf("%w of type %s", ErrUnsupportedImage, mediaType)
+}
+
+func LoadImageFile(path string) (*Image, error) {
+	logger := foundation.Logger()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		logger.Errorf("failed to ReadFile %s: %v", path, err)
+		return nil, fmt.Errorf("failed to ReadFile %s: %v", path, err)
+	}
+	return NewImage(data)
+}
+
+func LoadImageURL(ctx context.Context, url string) (*Image, error) {
+	logger := foundation.Logger()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		logger.Errorf("failed to NewRequestWithContext: %v", err)
+		return nil, fmt.Errorf("failed to NewRequestWithContext: %v", err)
+	}
+	client := &http.Client{Timeout: DefaultTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		logger.Errorf("failed to client.Do: %v", err)
+		return nil, fmt.Errorf("failed to client.Do: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		logger.Errorf("unexpected status code %d loading %s", resp.StatusCode, url)
+		return nil, fmt.Errorf("unexpected status code %d loading %s", resp.StatusCode, url)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxImageDownload+1))
+	if err != nil {
+		logger.Errorf("failed to io.ReadAll: %v", err)
+		return nil, fmt.Errorf("failed to io.ReadAll: %v", err)
+	}
+	if len(data) > maxImageDownload {
+		logger.Errorf("image at %s is over %d bytes", url, maxImageDownload)
+		return nil, fmt.Errorf("%w: image at %s is over %d bytes", ErrImageTooLarge, url, maxImageDownload)
+	}
+	return NewImage(data)
+}
+
+// Size returns the dimensions of the image without decoding it whole.
+func (i *Image) Size() (int, int, error) {
+	logger := foundation.Logger()
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(i.Data))
+	if err != nil {
+		logger.Errorf("failed to DecodeConfig %s: %v", i.MediaType, err)
+		return 0, 0, fmt.Errorf("%w: failed to DecodeConfig %s: %v", ErrUnsupportedImage, i.MediaType, err)
+	}
+	return config.Width, config.Height, nil
+}
+
+// Fit returns the image as is if it is within limits, or downscaled and recompressed until
+// it is. Images with transparency stay png when that fits, others become jpeg.
+func (i *Image) Fit(limits ImageLimits) (*Image, error) {
+	logger := foundation.Logger()
+
+	accepted := len(limits.MediaTypes) == 0 || containsString(limits.MediaTypes, i.MediaType)
+	if accepted && (limits.MaxBytes == 0 || len(i.Data) <= limits.MaxBytes) {
+		width, height, err := i.Size()
+		if err != nil {
+			return nil, err
+		}
+		if fitsDimensions(width, height, limits) {
+			return i, nil
+		}
+	}
+
+	img, err := i.decode()
+	if err != nil {
+		return nil, err
+	}
+	width, height := scaledDimensions(img.Bounds().Dx(), img.Bounds().Dy(), limits)
+	keepPng := hasTransparency(img) && (len(limits.MediaTypes) == 0 || containsString(limits.MediaTypes, MediaTypePng))
+
+	for {
+		scaled := scaleImage(img, width, height)
+		if fitted, ok := encodeWithin(scaled, keepPng, limits.MaxBytes); ok {
+			logger.Infof("fitted %s of %dx%d and %d bytes into %s of %dx%d and %d bytes",
+				i.MediaType, img.Bounds().Dx(), img.Bounds().Dy(), len(i.Data), fitted.MediaType, width, height, len(fitted.Data))
+			return fitted, nil
+		}
+
+		if max(width, height) <= minImageDimension {
+			logger.Errorf("image does not fit in %d bytes even at %dx%d", limits.MaxBytes, width, height)
+			return nil, fmt.Errorf("%w: does not fit in %d bytes even at %dx%d", ErrImageTooLarge, limits.MaxBytes, width, height)
+		}
+		width, height = max(1, width*3/4), max(1, height*3/4)
+	}
+}
+
+// Convert reencodes the image as mediaType, png or jpeg.
+func (i *Image) Convert(mediaType string) (*Image, error) {
+	logger := foundation.Logger()
+
+	if mediaType == i.MediaType {
+		return i, nil
+	}
+	img, err := i.decode()
+	if err != nil {
+		return nil, err
+	}
+
+	var buffer bytes.Buffer
+	switch mediaType {
+	case MediaTypePng:
+		err = png.Encode(&buffer, img)
+	case MediaTypeJpeg:
+		err = jpeg.Encode(&buffer, flatten(img), &jpeg.Options{Quality: jpegQualities[0]})
+	default:
+		logger.Errorf("cannot convert images to %s", mediaType)
+		return nil, fmt.Errorf("%w: cannot convert images to %s", ErrUnsupportedImage, mediaType)
+	}
+	if err != nil {
+		logger.Errorf("failed to encode %s: %v", mediaType, err)
+		return nil, fmt.Errorf("failed to encode %s: %v", mediaType, err)
+	}
+	return &Image{Data: buffer.Bytes(), MediaType: mediaType}, nil
+}
+
+// Base64 returns the image as expected by LlmMessage.B64Image.
+func (i *Image) Base64() string {
+	return base64.StdEncoding.EncodeToString(i.Data)
+}
+
+// Message returns a message of role with text and the image.
+func (i *Image) Message(role LlmRole, text string) LlmMessage {
+	return LlmMessage{
+		Role:           role,
+		Content:        text,
+		B64Image:       i.Base64(),
+		ImageMediaType: i.MediaType,
+	}
+}
+
+// PrepareImageMessage loads the image at source, a url or a file path, fits it to the
+// limits of provider and returns a user message with text and the image.
+func PrepareImageMessage(ctx context.Context, provider string, source string, text string) (LlmMessage, error) {
+	var img *Image
+	var err error
+	if isURL(source) {
+		img, err = LoadImageURL(ctx, source)
+	} else {
+		img, err = LoadImageFile(source)
+	}
+	if err != nil {
+		return LlmMessage{}, err
+	}
+
+	img, err = img.Fit(ImageLimitsForProvider(provider))
+	if err != nil {
+		return LlmMessage{}, err
+	}
+	return img.Message(RoleUser, text), nil
+}
+
+func (i *Image) decode() (image.Image, error) {
+	logger := foundation.Logger()
+
+	width, height, err := i.Size()
+	if err != nil {
+		return nil, err
+	}
+	if width*height > maxDecodedPixels {
+		logger.Errorf("image of %dx%d is over %d pixels", width, height, maxDecodedPixels)
+		return nil, fmt.Errorf("%w: %dx%d is over %d pixels", ErrImageTooLarge, width, height, maxDecodedPixels)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(i.Data))
+	if err != nil {
+		logger.Errorf("failed to Decode %s: %v", i.MediaType, err)
+		return nil, fmt.Errorf("%w: failed to Decode %s: %v", ErrUnsupportedImage, i.MediaType, err)
+	}
+	return img, nil
+}
+
+func isURL(source string) bool {
+	return strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://")
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func fitsDimensions(width int, height int, limits ImageLimits) bool {
+	if limits.MaxDimension > 0 && max(width, height) > limits.MaxDimension {
+		return false
+	}
+	return limits.MaxPixels == 0 || width*height <= limits.MaxPixels
+}
+
+// scaledDimensions shrinks width and height within limits, keeping the aspect ratio.
+func scaledDimensions(width int, height int, limits ImageLimits) (int, int) {
+	scale := 1.0
+	if limits.MaxDimension > 0 && max(width, height) > limits.MaxDimension {
+		scale = float64(limits.MaxDimension) / float64(max(width, height))
+	}
+	if limits.MaxPixels > 0 && float64(width*height)*scale*scale > float64(limits.MaxPixels) {
+		scale = math.Sqrt(float64(limits.MaxPixels) / float64(width*height))
+	}
+	if scale == 1 {
+		return width, height
+	}
+
+	scaledWidth := max(1, int(math.Round(float64(width)*scale)))
+	scaledHeight := max(1, int(math.Round(float64(height)*scale)))
+	if limits.MaxDimension > 0 {
+		scaledWidth, scaledHeight = min(scaledWidth, limits.MaxDimension), min(scaledHeight, limits.MaxDimension)
+	}
+	// rounding up may overshoot the pixel count by a row
+	if limits.MaxPixels > 0 && scaledWidth*scaledHeight > limits.MaxPixels {
+		scaledWidth = max(1, int(float64(width)*scale))
+		scaledHeight = max(1, int(float64(height)*scale))
+	}
+	return scaledWidth, scaledHeight
+}
+
+func encodeWithin(img image.Image, keepPng bool, maxBytes int) (*Image, bool) {
+	var buffer bytes.Buffer
+	if keepPng {
+		if err := png.Encode(&buffer, img); err == nil && (maxBytes == 0 || buffer.Len() <= maxBytes) {
+			return &Image{Data: buffer.Bytes(), MediaType: MediaTypePng}, true
+		}
+	}
+
+	flat := flatten(img)
+	for _, quality := range jpegQualities {
+		buffer.Reset()
+		if err := jpeg.Encode(&buffer, flat, &jpeg.Options{Quality: quality}); err != nil {
+			return nil, false
+		}
+		if maxBytes == 0 || buffer.Len() <= maxBytes {
+			return &Image{Data: buffer.Bytes(), MediaType: MediaTypeJpeg}, true
+		}
+	}
+	return nil, false
+}
+
+func hasTransparency(img image.Image) bool {
+	if opaque, ok := img.(interface{ Opaque() bool }); ok {
+		return !opaque.Opaque()
+	}
+	return true
+}
+
+// flatten draws img over white, as jpeg has no transparency.
+func flatten(img image.Image) image.Image {
+	if !hasTransparency(img) {
+		return img
+	}
+	flat := image.NewRGBA(img.Bounds())
+	draw.Draw(flat, flat.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(flat, flat.Bounds(), img, img.Bounds().Min, draw.Over)
+	return flat
+}
+
+// scaleImage downscales img to width x height, averaging the source pixels each
+// destination pixel covers.
+func scaleImage(img image.Image, width int, height int) image.Image {
+	bounds := img.Bounds()
+	if bounds.Dx() == width && bounds.Dy() == height {
+		return img
+	}
+
+	src := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(src, src.Bounds(), img, bounds.Min, draw.Src)
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+
+	for y := 0; y < height; y++ {
+		y0 := y * bounds.Dy() / height
+		y1 := max(y0+1, (y+1)*bounds.Dy()/height)
+		for x := 0; x < width; x++ {
+			x0 := x * bounds.Dx() / width
+			x1 := max(x0+1, (x+1)*bounds.Dx()/width)
+
+			var r, g, b, a, count int
+			for sy := y0; sy < y1; sy++ {
+				offset := src.PixOffset(x0, sy)
+				for sx := x0; sx < x1; sx++ {
+					r += int(src.Pix[offset])
+					g += int(src.Pix[offset+1])
+					b += int(src.Pix[offset+2])
+					a += int(src.Pix[offset+3])
+					offset += 4
+					count++
+				}
+			}
+
+			offset := dst.PixOffset(x, y)
+			dst.Pix[offset] = uint8(r / count)
+			dst.Pix[offset+1] = uint8(g / count)
+			dst.Pix[offset+2] = uint8(b / count)
+			dst.Pix[offset+3] = uint8(a / count)
+		}
+	}
+	return dst
+}
@@ -0,0 +1,194 @@
+package llm_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sieglu2/go_foundation/llm"
+)
+
+// noisyImage returns an image of random pixels, which compresses badly.
+func noisyImage(width int, height int, alpha uint8) *image.NRGBA {
+	random := rand.New(rand.NewSource(1))
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i] = uint8(random.Intn(256))
+		img.Pix[i+1] = uint8(random.Intn(256))
+		img.Pix[i+2] = uint8(random.Intn(256))
+		img.Pix[i+3] = alpha
+	}
+	return img
+}
+
+func encodePng(t *testing.T, img image.Image) []byte {
+	var buffer bytes.Buffer
+	if err := png.Encode(&buffer, img); err != nil {
+		t.Fatalf("failed to encode png: %v", err)
+	}
+	return buffer.Bytes()
+}
+
+func fit(t *testing.T, data []byte, limits llm.ImageLimits) (*llm.Image, int, int) {
+	img, err := llm.NewImage(data)
+	if err != nil {
+		t.Fatalf("failed to NewImage: %v", err)
+	}
+	fitted, err := img.Fit(limits)
+	if err != nil {
+		t.Fatalf("failed to Fit: %v", err)
+	}
+	width, height, err := fitted.Size()
+	if err != nil {
+		t.Fatalf("failed to Size: %v", err)
+	}
+	return fitted, width, height
+}
+
+func TestImageFit(t *testing.T) {
+	t.Run("Within limits", func(t *testing.T) {
+		data := encodePng(t, noisyImage(100, 50, 255))
+		fitted, _, _ := fit(t, data, llm.ImageLimitsForProvider(llm.ProviderClaude))
+		if !bytes.Equal(fitted.Data, data) || fitted.MediaType != llm.MediaTypePng {
+			t.Fatalf("expected the image unchanged, got %s of %d bytes", fitted.MediaType, len(fitted.Data))
+		}
+	})
+
+	t.Run("Downscaled to the provider", func(t *testing.T) {
+		data := encodePng(t, noisyImage(3000, 1000, 255))
+		fitted, width, height := fit(t, data, llm.ImageLimitsForProvider(llm.ProviderClaude))
+		if width != 1568 || height != 523 || fitted.MediaType != llm.MediaTypeJpeg {
+			t.Fatalf("expected a jpeg of 1568x523, got %s of %dx%d", fitted.MediaType, width, height)
+		}
+		if len(fitted.Data) > llm.ImageLimitsForProvider(llm.ProviderClaude).MaxBytes {
+			t.Fatalf("expected the bytes limit kept, got %d bytes", len(fitted.Data))
+		}
+	})
+
+	t.Run("Transparency stays png", func(t *testing.T) {
+		data := encodePng(t, noisyImage(400, 400, 128))
+		fitted, width, _ := fit(t, data, llm.ImageLimits{MaxDimension: 200})
+		if width != 200 || fitted.MediaType != llm.MediaTypePng {
+			t.Fatalf("expected a png 200 wide, got %s %d wide", fitted.MediaType, width)
+		}
+	})
+
+	t.Run("Shrunk to the bytes limit", func(t *testing.T) {
+		data := encodePng(t, noisyImage(800, 800, 255))
+		fitted, width, _ := fit(t, data, llm.ImageLimits{MaxBytes: 40 * 1024})
+		if len(fitted.Data) > 40*1024 || width >= 800 {
+			t.Fatalf("expected under 40KB and smaller, got %d bytes %d wide", len(fitted.Data), width)
+		}
+
+		img, _ := llm.NewImage(data)
+		if _, err := img.Fit(llm.ImageLimits{MaxBytes: 100}); !errors.Is(err, llm.ErrImageTooLarge) {
+			t.Fatalf("expected ErrImageTooLarge, got %v", err)
+		}
+	})
+
+	t.Run("Converted to an accepted format", func(t *testing.T) {
+		paletted := image.NewPaletted(image.Rect(0, 0, 10, 10), color.Palette{color.Black, color.White})
+		var buffer bytes.Buffer
+		if err := gif.Encode(&buffer, paletted, nil); err != nil {
+			t.Fatalf("failed to encode gif: %v", err)
+		}
+		fitted, width, _ := fit(t, buffer.Bytes(), llm.ImageLimitsForProvider(llm.ProviderGemini))
+		if fitted.MediaType == llm.MediaTypeGif || width != 10 {
+			t.Fatalf("expected the gif converted, got %s %d wide", fitted.MediaType, width)
+		}
+	})
+
+	t.Run("Webp converted", func(t *testing.T) {
+		// a 1x1 lossless webp
+		data, _ := base64.StdEncoding.DecodeString("UklGRhoAAABXRUJQVlA4TA0AAAAvAAAAEAcQERGIiP4HAA==")
+		fitted, width, height := fit(t, data, llm.ImageLimitsForProvider(llm.ProviderMinimax))
+		if fitted.MediaType == llm.MediaTypeWebp || width != 1 || height != 1 {
+			t.Fatalf("expected the webp converted, got %s of %dx%d", fitted.MediaType, width, height)
+		}
+	})
+
+	t.Run("Huge dimensions rejected before decoding", func(t *testing.T) {
+		var buffer bytes.Buffer
+		if err := gif.Encode(&buffer, image.NewPaletted(image.Rect(0, 0, 1, 1), color.Palette{color.Black}), nil); err != nil {
+			t.Fatalf("failed to encode gif: %v", err)
+		}
+		// the logical screen of the gif claims 65535x65535
+		data := buffer.Bytes()
+		copy(data[6:10], []byte{0xff, 0xff, 0xff, 0xff})
+		img, err := llm.NewImage(data)
+		if err != nil {
+			t.Fatalf("failed to NewImage: %v", err)
+		}
+		if _, err := img.Fit(llm.ImageLimitsForProvider(llm.ProviderClaude)); !errors.Is(err, llm.ErrImageTooLarge) {
+			t.Fatalf("expected ErrImageTooLarge, got %v", err)
+		}
+	})
+
+	t.Run("Unsupported", func(t *testing.T) {
+		if _, err := llm.NewImage([]byte("not an image")); !errors.Is(err, llm.ErrUnsupportedImage) {
+			t.Fatalf("expected ErrUnsupportedImage, got %v", err)
+		}
+	})
+}
+
+func TestImageMessage(t *testing.T) {
+	data := encodePng(t, noisyImage(10, 10, 255))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	img, err := llm.LoadImageURL(context.Background(), server.URL)
+	if err != nil {
+		t.Fatalf("failed to LoadImageURL: %v", err)
+	}
+	jpegImage, err := img.Convert(llm.MediaTypeJpeg)
+	if err != nil {
+		t.Fatalf("failed to Convert: %v", err)
+	}
+
+	message := jpegImage.Message(llm.RoleUser, "what is it?")
+	if message.ImageMediaType != llm.MediaTypeJpeg || message.B64Image != jpegImage.Base64() || message.Content != "what is it?" {
+		t.Fatalf("unexpected message: %+v", message)
+	}
+
+	message, err = llm.PrepareImageMessage(context.Background(), llm.ProviderChatGpt, server.URL, "and this?")
+	if err != nil || message.ImageMediaType != llm.MediaTypePng {
+		t.Fatalf("expected a png message, got %+v and %v", message.ImageMediaType, err)
+	}
+}
+
+func TestClaudeImageSource(t *testing.T) {
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		w.Write([]byte(`{"content": [{"type": "text", "text": "noise"}], "stop_reason": "end_turn"}`))
+	}))
+	defer server.Close()
+
+	img, err := llm.NewImage(encodePng(t, noisyImage(10, 10, 255)))
+	if err != nil {
+		t.Fatalf("failed to NewImage: %v", err)
+	}
+	client := llm.NewClaudeClient("key")
+	client.SetBaseURL(server.URL)
+	if _, err := client.ReplyMessage(context.Background(), []llm.LlmMessage{img.Message(llm.RoleUser, "what is it?")}); err != nil {
+		t.Fatalf("ReplyMessage failed: %v", err)
+	}
+
+	expected := `"source":{"type":"base64","media_type":"image/png","data":"` + img.Base64() + `"}`
+	if !strings.Contains(string(body), expected) {
+		t.Fatalf("expected the base64 source in %s", body)
+	}
+}
@@ -0,0 +1,206 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/sieglu2/go_foundation/foundation"
+)
+
+// Invoker sends the messages on to the rest of the chain, the wrapped client being the last.
+type Invoker func(ctx context.Context, messages []LlmMessage) (*LlmReply, error)
+
+// Interceptor runs around a call: it may inspect or replace the messages before calling
+// next, and inspect or replace the reply and error after. It must not modify the messages
+// it was given in place, they belong to the caller.
+type Interceptor func(ctx context.Context, messages []LlmMessage, next Invoker) (*LlmReply, error)
+
+// InterceptedClient is an LlmClient running its interceptors around every call of the
+// wrapped client. The first interceptor is the outermost one.
+type InterceptedClient struct {
+	client LlmClient
+	invoke Invoker
+}
+
+func NewInterceptedClient(client LlmClient, interceptors ...Interceptor) *InterceptedClient {
+	return &InterceptedClient{
+		client: client,
+		invoke: ChainInterceptors(func(ctx context.Context, messages []LlmMessage) (*LlmReply, error) {
+			return ReplyMessageDetail(ctx, client, messages)
+		}, interceptors...),
+	}
+}
+
+// ChainInterceptors returns an Invoker running interceptors in order around invoke.
+func ChainInterceptors(invoke Invoker, interceptors ...Interceptor) Invoker {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], invoke
+		invoke = func(ctx context.Context, messages []LlmMessage) (*LlmReply, error) {
+			return interceptor(ctx, messages, next)
+		}
+	}
+	return invoke
+}
+
+func (c *InterceptedClient) ReplyMessage(ctx context.Context, messages []LlmMessage) (string, error) {
+	reply, err := c.ReplyMessageDetail(ctx, messages)
+	if err != nil {
+		return "", err
+	}
+	return reply.Content, nil
+}
+
+func (c *InterceptedClient) ReplyMessageDetail(ctx context.Context, messages []LlmMessage) (*LlmReply, error) {
+	return c.invoke(ctx, messages)
+}
+
+func (c *InterceptedClient) Close() error {
+	return c.client.Close()
+}
+
+// LoggingInterceptor logs every call with its duration and token usage.
+func LoggingInterceptor() Interceptor {
+	return func(ctx context.Context, messages []LlmMessage, next Invoker) (*LlmReply, error) {
+		logger := foundation.Logger()
+
+		start := time.Now()
+		reply, err := next(ctx, messages)
+		if err != nil {
+			logger.Errorf("llm call with %d messages failed after %v: %v", len(messages), time.Since(start), err)
+			return nil, err
+		}
+
+		logger.Infof("llm call with %d messages to %s took %v, %d prompt and %d completion tokens",
+			len(messages), reply.Model, time.Since(start), reply.Usage.PromptTokens, reply.Usage.CompletionTokens)
+		return reply, nil
+	}
+}
+
+// RetryInterceptor retries a call failing with a 429, a 5xx or a network error up to
+// maxRetries times, waiting backoffWait doubled at every retry, or the wait asked by the
+// provider. Other errors, like a 400, are returned right away.
+func RetryInterceptor(maxRetries int, backoffWait time.Duration) Interceptor {
+	return func(ctx context.Context, messages []LlmMessage, next Invoker) (*LlmReply, error) {
+		logger := foundation.Logger()
+
+		wait := backoffWait
+		for attempt := 0; ; attempt++ {
+			reply, err := next(ctx, messages)
+			if err == nil || attempt >= maxRetries || !isRetryable(err) || ctx.Err() != nil {
+				return reply, err
+			}
+
+			delay := wait
+			if retryAfter := RetryAfter(err); retryAfter > delay {
+				delay = retryAfter
+			}
+			logger.Warnf("retrying llm call in %v, attempt %d of %d: %v", delay, attempt+1, maxRetries, err)
+
+			timer := time.NewTimer(delay)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, fmt.Errorf("%w, last error: %v", ctx.Err(), err)
+			case <-timer.C:
+			}
+			wait *= 2
+		}
+	}
+}
+
+func isRetryable(err error) bool {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return !errors.Is(err, context.Canceled)
+	}
+
+	statusCode := HttpStatusCode(err)
+	return statusCode == http.StatusTooManyRequests || statusCode >= http.StatusInternalServerError
+}
+
+const redactedText = "[REDACTED]"
+
+// DefaultRedactionPatterns match email addresses and the api keys of the usual providers.
+var DefaultRedactionPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`),
+	regexp.MustCompile(`sk-[A-Za-z0-9_-]{16,}`),
+	regexp.MustCompile(`AIza[A-Za-z0-9_-]{35}`),
+}
+
+// RedactionInterceptor replaces the text matching patterns in the messages with
+// [REDACTED] before they leave the process, DefaultRedactionPatterns if none are given.
+func RedactionInterceptor(patterns ...*regexp.Regexp) Interceptor {
+	if len(patterns) == 0 {
+		patterns = DefaultRedactionPatterns
+	}
+
+	return func(ctx context.Context, messages []LlmMessage, next Invoker) (*LlmReply, error) {
+		redacted := make([]LlmMessage, len(messages))
+		for i, message := range messages {
+			for _, pattern := range patterns {
+				message.Content = pattern.ReplaceAllString(message.Content, redactedText)
+			}
+			redacted[i] = message
+		}
+		return next(ctx, redacted)
+	}
+}
+
+// LlmMetrics accumulates the calls seen by a MetricsInterceptor.
+type LlmMetrics struct {
+	mu       sync.Mutex
+	calls    int
+	errors   int
+	usage    LlmUsage
+	duration time.Duration
+}
+
+type LlmMetricsSnapshot struct {
+	Calls    int
+	Errors   int
+	Usage    LlmUsage
+	Duration time.Duration
+}
+
+func (m *LlmMetrics) Snapshot() LlmMetricsSnapshot {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return LlmMetricsSnapshot{
+		Calls:    m.calls,
+		Errors:   m.errors,
+		Usage:    m.usage,
+		Duration: m.duration,
+	}
+}
+
+func (m *LlmMetrics) record(reply *LlmReply, err error, duration time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.calls++
+	m.duration += duration
+	if err != nil {
+		m.errors++
+		return
+	}
+	m.usage.PromptTokens += reply.Usage.PromptTokens
+	m.usage.CompletionTokens += reply.Usage.CompletionTokens
+	m.usage.TotalTokens += reply.Usage.TotalTokens
+}
+
+// MetricsInterceptor counts the calls, errors, tokens and time spent into metrics.
+func MetricsInterceptor(metrics *LlmMetrics) Interceptor {
+	return func(ctx context.Context, messages []LlmMessage, next Invoker) (*LlmReply, error) {
+		start := time.Now()
+		reply, err := next(ctx, messages)
+		metrics.record(reply, err, time.Since(start))
+		return reply, err
+	}
+}
@@ -0,0 +1,146 @@
+package llm_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sieglu2/go_foundation/llm"
+)
+
+func TestInterceptedClient(t *testing.T) {
+	ctx := context.Background()
+	messages := []llm.LlmMessage{{Role: llm.RoleUser, Content: "hello"}}
+
+	t.Run("Interceptors run in order around the client", func(t *testing.T) {
+		var trace []string
+		tracing := func(name string) llm.Interceptor {
+			return func(ctx context.Context, messages []llm.LlmMessage, next llm.Invoker) (*llm.LlmReply, error) {
+				trace = append(trace, name+" before")
+				reply, err := next(ctx, messages)
+				trace = append(trace, name+" after")
+				return reply, err
+			}
+		}
+
+		client := llm.NewInterceptedClient(&fakeClient{}, tracing("outer"), tracing("inner"))
+		if _, err := client.ReplyMessage(ctx, messages); err != nil {
+			t.Fatalf("ReplyMessage failed: %v", err)
+		}
+
+		expected := "outer before|inner before|inner after|outer after"
+		if strings.Join(trace, "|") != expected {
+			t.Fatalf("expected %s, got %s", expected, strings.Join(trace, "|"))
+		}
+	})
+
+	t.Run("Interceptors can modify messages and reply", func(t *testing.T) {
+		upper := func(ctx context.Context, messages []llm.LlmMessage, next llm.Invoker) (*llm.LlmReply, error) {
+			modified := append([]llm.LlmMessage{{Role: llm.RoleSystem, Content: "be brief"}}, messages...)
+			reply, err := next(ctx, modified)
+			if err != nil {
+				return nil, err
+			}
+			reply.Content = strings.ToUpper(reply.Content)
+			return reply, nil
+		}
+
+		fake := &fakeClient{}
+		content, err := llm.NewInterceptedClient(fake, upper).ReplyMessage(ctx, messages)
+		if err != nil {
+			t.Fatalf("ReplyMessage failed: %v", err)
+		}
+		if content != "HELLO" {
+			t.Fatalf("expected HELLO, got %s", content)
+		}
+		if len(fake.Received()) != 2 || fake.Received()[0].Role != llm.RoleSystem {
+			t.Fatalf("expected the system message to be added, got %+v", fake.Received())
+		}
+	})
+}
+
+func TestRetryInterceptor(t *testing.T) {
+	ctx := context.Background()
+	messages := []llm.LlmMessage{{Role: llm.RoleUser, Content: "hello"}}
+
+	t.Run("Retries throttled and server errors", func(t *testing.T) {
+		fake := &fakeClient{errs: []error{
+			&llm.ApiError{StatusCode: http.StatusTooManyRequests, Message: "slow down"},
+			&llm.ApiError{StatusCode: http.StatusBadGateway, Message: "bad gateway"},
+		}}
+		client := llm.NewInterceptedClient(fake, llm.RetryInterceptor(3, time.Millisecond))
+
+		if _, err := client.ReplyMessage(ctx, messages); err != nil {
+			t.Fatalf("ReplyMessage should succeed after retries: %v", err)
+		}
+		if fake.Calls() != 3 {
+			t.Fatalf("expected 3 calls, got %d", fake.Calls())
+		}
+	})
+
+	t.Run("Does not retry client errors", func(t *testing.T) {
+		fake := &fakeClient{errs: []error{&llm.ApiError{StatusCode: http.StatusBadRequest, Message: "bad request"}}}
+		client := llm.NewInterceptedClient(fake, llm.RetryInterceptor(3, time.Millisecond))
+
+		if _, err := client.ReplyMessage(ctx, messages); llm.HttpStatusCode(err) != http.StatusBadRequest {
+			t.Fatalf("expected the 400 error, got: %v", err)
+		}
+		if fake.Calls() != 1 {
+			t.Fatalf("expected 1 call, got %d", fake.Calls())
+		}
+	})
+
+	t.Run("Gives up after max retries", func(t *testing.T) {
+		throttled := &llm.ApiError{StatusCode: http.StatusTooManyRequests, Message: "slow down"}
+		fake := &fakeClient{errs: []error{throttled, throttled, throttled}}
+		client := llm.NewInterceptedClient(fake, llm.RetryInterceptor(2, time.Millisecond))
+
+		_, err := client.ReplyMessage(ctx, messages)
+		if !errors.Is(err, throttled) {
+			t.Fatalf("expected the last 429, got: %v", err)
+		}
+		if fake.Calls() != 3 {
+			t.Fatalf("expected 3 calls, got %d", fake.Calls())
+		}
+	})
+}
+
+func TestRedactionInterceptor(t *testing.T) {
+	fake := &fakeClient{}
+	client := llm.NewInterceptedClient(fake, llm.RedactionInterceptor())
+
+	messages := []llm.LlmMessage{{Role: llm.RoleUser, Content: "mail jane@example.com the key sk-abcdefghijklmnopqrstuvwx"}}
+	if _, err := client.ReplyMessage(context.Background(), messages); err != nil {
+		t.Fatalf("ReplyMessage failed: %v", err)
+	}
+
+	expected := "mail [REDACTED] the key [REDACTED]"
+	if fake.Received()[0].Content != expected {
+		t.Fatalf("expected %q, got %q", expected, fake.Received()[0].Content)
+	}
+	if !strings.Contains(messages[0].Content, "jane@example.com") {
+		t.Fatalf("the caller's messages should not be modified")
+	}
+}
+
+func TestMetricsInterceptor(t *testing.T) {
+	metrics := &llm.LlmMetrics{}
+	fake := &fakeClient{errs: []error{&llm.ApiError{StatusCode: http.StatusBadRequest, Message: "bad request"}}}
+	client := llm.NewInterceptedClient(fake, llm.MetricsInterceptor(metrics))
+
+	messages := []llm.LlmMessage{{Role: llm.RoleUser, Content: "hello"}}
+	for i := 0; i < 3; i++ {
+		client.ReplyMessage(context.Background(), messages)
+	}
+
+	snapshot := metrics.Snapshot()
+	if snapshot.Calls != 3 || snapshot.Errors != 1 {
+		t.Fatalf("expected 3 calls and 1 error, got %+v", snapshot)
+	}
+	if snapshot.Usage.TotalTokens != 30 {
+		t.Fatalf("expected 30 tokens, got %d", snapshot.Usage.TotalTokens)
+	}
+}
@@ -0,0 +1,420 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/sieglu2/go_foundation/foundation"
+	"github.com/sieglu2/go_foundation/llm/secret_key"
+)
+
+type KeySelection int
+
+const (
+	// KeySelectionRoundRobin spreads the calls over the keys in turn.
+	KeySelectionRoundRobin KeySelection = iota
+	// KeySelectionLeastRecentlyThrottled prefers the key whose last 429 is the oldest.
+	KeySelectionLeastRecentlyThrottled
+)
+
+const (
+	DefaultThrottledBench    = 30 * time.Second
+	DefaultUnauthorizedBench = time.Hour
+)
+
+var ErrNoKeyAvailable = errors.New("no api key available")
+
+var errKeyPoolClosed = fmt.Errorf("%w: the key pool is closed", ErrNoKeyAvailable)
+
+type KeyPoolConfig struct {
+	Selection KeySelection
+	// ThrottledBench is how long a key is benched after a 429 without Retry-After.
+	ThrottledBench time.Duration
+	// UnauthorizedBench is how long a key is benched after a 401 or 403.
+	UnauthorizedBench time.Duration
+	// ReloadInterval is how often the keys are reloaded from their source, so rotated keys
+	// are picked up without a restart. 0 disables it.
+	ReloadInterval time.Duration
+}
+
+type pooledKey struct {
+	apiKey        string
+	client        LlmClient
+	benchedUntil  time.Time
+	lastThrottled time.Time
+	lastUsed      time.Time
+	// inFlight counts the calls running on the client, a removed key's client is closed
+	// once it drops to 0.
+	inFlight int
+	removed  bool
+}
+
+// KeyPool is an LlmClient spreading the calls over several api keys of the same provider.
+// A key answered with 401/403 or 429 is benched and the call is retried with the next key.
+type KeyPool struct {
+	config    KeyPoolConfig
+	loadKeys  func(ctx context.Context) ([]string, error)
+	newClient func(ctx context.Context, apiKey string) (LlmClient, error)
+
+	// reloadMu serializes the reloads, which create the clients without holding mu
+	reloadMu sync.Mutex
+
+	mu     sync.Mutex
+	closed bool
+	keys   []*pooledKey
+	// retired are the removed keys still serving in-flight calls.
+	retired []*pooledKey
+	next    int
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+// NewKeyPoolForProvider creates a pool over the keys stored for the provider, several keys
+// being stored as one secret separated by commas, see secret_key.SplitSecretKeys.
+func NewKeyPoolForProvider(ctx context.Context, provider string, model string, config KeyPoolConfig) (*KeyPool, error) {
+	secret, ok := secret_key.LookupProvider(provider)
+	if !ok {
+		return nil, fmt.Errorf("unknown provider: %s", provider)
+	}
+
+	return NewKeyPool(ctx,
+		func(ctx context.Context) ([]string, error) {
+			return getSecretKeys(secret.AccountName, secret.ServiceName)
+		},
+		func(ctx context.Context, apiKey string) (LlmClient, error) {
+			return NewLlmClientWithApiKey(ctx, provider, apiKey, model)
+		},
+		config,
+	)
+}
+
+// NewKeyPool creates a pool over the keys returned by loadKeys, with one client per key
+// created by newClient.
+func NewKeyPool(
+	ctx context.Context,
+	loadKeys func(ctx context.Context) ([]string, error),
+	newClient func(ctx context.Context, apiKey string) (LlmClient, error),
+	config KeyPoolConfig,
+) (*KeyPool, error) {
+	if config.ThrottledBench <= 0 {
+		config.ThrottledBench = DefaultThrottledBench
+	}
+	if config.UnauthorizedBench <= 0 {
+		config.UnauthorizedBench = DefaultUnauthorizedBench
+	}
+
+	pool := &KeyPool{
+		config:    config,
+		loadKeys:  loadKeys,
+		newClient: newClient,
+		stop:      make(chan struct{}),
+	}
+
+	if err := pool.Reload(ctx); err != nil {
+		pool.Close()
+		return nil, err
+	}
+
+	if config.ReloadInterval > 0 {
+		pool.wg.Add(1)
+		go pool.reloadPeriodically()
+	}
+
+	return pool, nil
+}
+
+func (p *KeyPool) reloadPeriodically() {
+	defer p.wg.Done()
+	logger := foundation.Logger()
+
+	ticker := time.NewTicker(p.config.ReloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			if err := p.Reload(ctx); err != nil {
+				logger.Warnf("failed to reload api keys, keeping the current ones: %v", err)
+			}
+			cancel()
+		}
+	}
+}
+
+// Reload fetches the keys again. Known keys keep their state, new keys get a client and
+// removed keys stop being used.
+func (p *KeyPool) Reload(ctx context.Context) error {
+	p.reloadMu.Lock()
+	defer p.reloadMu.Unlock()
+
+	apiKeys, err := p.loadKeys(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load api keys: %v", err)
+	}
+	if len(apiKeys) == 0 {
+		return fmt.Errorf("no api keys loaded")
+	}
+
+	p.mu.Lock()
+	known := make(map[string]bool, len(p.keys))
+	for _, key := range p.keys {
+		known[key.apiKey] = true
+	}
+	p.mu.Unlock()
+
+	// the clients are created without the lock, so the calls go on while they dial
+	created := make(map[string]LlmClient)
+	for _, apiKey := range apiKeys {
+		if known[apiKey] || created[apiKey] != nil {
+			continue
+		}
+		client, err := p.newClient(ctx, apiKey)
+		if err != nil {
+			closeClients(created, "failed to close client")
+			return fmt.Errorf("failed to create client for key %s: %v", secret_key.MaskKey(apiKey), err)
+		}
+		created[apiKey] = client
+	}
+
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		closeClients(created, "failed to close client")
+		return errKeyPoolClosed
+	}
+
+	existing := make(map[string]*pooledKey, len(p.keys))
+	for _, key := range p.keys {
+		existing[key.apiKey] = key
+	}
+
+	keys := make([]*pooledKey, 0, len(apiKeys))
+	for _, apiKey := range apiKeys {
+		if key, ok := existing[apiKey]; ok {
+			keys = append(keys, key)
+			delete(existing, apiKey)
+		} else if client, ok := created[apiKey]; ok {
+			keys = append(keys, &pooledKey{
+				apiKey: apiKey,
+				client: client,
+			})
+			delete(created, apiKey)
+		}
+	}
+
+	// removed keys may still serve in-flight calls, their clients are closed after them
+	idle := make(map[string]LlmClient)
+	for apiKey, key := range existing {
+		key.removed = true
+		if key.inFlight == 0 {
+			idle[apiKey] = key.client
+		} else {
+			p.retired = append(p.retired, key)
+		}
+	}
+
+	p.keys = keys
+	p.mu.Unlock()
+
+	closeClients(idle, "failed to close client of a removed key")
+	return nil
+}
+
+func closeClients(clients map[string]LlmClient, message string) {
+	logger := foundation.Logger()
+	for _, client := range clients {
+		if err := client.Close(); err != nil {
+			logger.Warnf("%s: %v", message, err)
+		}
+	}
+}
+
+func (p *KeyPool) ReplyMessage(ctx context.Context, messages []LlmMessage) (string, error) {
+	reply, err := p.ReplyMessageDetail(ctx, messages)
+	if err != nil {
+		return "", err
+	}
+	return reply.Content, nil
+}
+
+func (p *KeyPool) ReplyMessageDetail(ctx context.Context, messages []LlmMessage) (*LlmReply, error) {
+	p.mu.Lock()
+	attempts := len(p.keys)
+	p.mu.Unlock()
+	if attempts == 0 {
+		return nil, errKeyPoolClosed
+	}
+
+	var lastErr error
+	for i := 0; i < attempts; i++ {
+		key, err := p.pick()
+		if err != nil {
+			if lastErr != nil {
+				return nil, fmt.Errorf("%w, last error: %v", err, lastErr)
+			}
+			return nil, err
+		}
+
+		reply, err := ReplyMessageDetail(ctx, key.client, messages)
+		p.release(key)
+		if err == nil {
+			return reply, nil
+		}
+		if !p.bench(key, err) || ctx.Err() != nil {
+			return nil, err
+		}
+		lastErr = err
+	}
+
+	return nil, lastErr
+}
+
+func (p *KeyPool) pick() (*pooledKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.keys) == 0 {
+		return nil, errKeyPoolClosed
+	}
+
+	now := time.Now()
+	var picked *pooledKey
+
+	switch p.config.Selection {
+	case KeySelectionLeastRecentlyThrottled:
+		for _, key := range p.keys {
+			if key.benchedUntil.After(now) {
+				continue
+			}
+			if picked == nil ||
+				key.lastThrottled.Before(picked.lastThrottled) ||
+				(key.lastThrottled.Equal(picked.lastThrottled) && key.lastUsed.Before(picked.lastUsed)) {
+				picked = key
+			}
+		}
+
+	default:
+		for i := 0; i < len(p.keys); i++ {
+			index := (p.next + i) % len(p.keys)
+			if !p.keys[index].benchedUntil.After(now) {
+				picked = p.keys[index]
+				p.next = index + 1
+				break
+			}
+		}
+	}
+
+	if picked == nil {
+		var earliest time.Time
+		for _, key := range p.keys {
+			if earliest.IsZero() || key.benchedUntil.Before(earliest) {
+				earliest = key.benchedUntil
+			}
+		}
+		return nil, fmt.Errorf("%w: all %d keys are benched, next one is back in %v",
+			ErrNoKeyAvailable, len(p.keys), earliest.Sub(now).Round(time.Second))
+	}
+
+	picked.lastUsed = now
+	picked.inFlight++
+	return picked, nil
+}
+
+// release ends a call picked on key, closing the client of a removed key after its last call.
+func (p *KeyPool) release(key *pooledKey) {
+	logger := foundation.Logger()
+
+	p.mu.Lock()
+	key.inFlight--
+	if !key.removed || key.inFlight > 0 {
+		p.mu.Unlock()
+		return
+	}
+	// a key no longer retired was closed with the pool
+	found := false
+	for i, retired := range p.retired {
+		if retired == key {
+			p.retired = append(p.retired[:i], p.retired[i+1:]...)
+			found = true
+			break
+		}
+	}
+	p.mu.Unlock()
+	if !found {
+		return
+	}
+
+	if err := key.client.Close(); err != nil {
+		logger.Warnf("failed to close client of a removed key: %v", err)
+	}
+}
+
+// bench takes a key out of rotation after an auth or rate limit error, it returns false for
+// errors another key would not fix.
+func (p *KeyPool) bench(key *pooledKey, err error) bool {
+	logger := foundation.Logger()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	statusCode := HttpStatusCode(err)
+
+	var duration time.Duration
+	switch statusCode {
+	case http.StatusTooManyRequests:
+		duration = RetryAfter(err)
+		if duration <= 0 {
+			duration = p.config.ThrottledBench
+		}
+		key.lastThrottled = now
+	case http.StatusUnauthorized, http.StatusForbidden:
+		duration = p.config.UnauthorizedBench
+	default:
+		return false
+	}
+
+	key.benchedUntil = now.Add(duration)
+	logger.Warnf("benching api key %s for %v after status %d: %v",
+		secret_key.MaskKey(key.apiKey), duration, statusCode, err)
+	return true
+}
+
+func (p *KeyPool) Close() error {
+	p.stopOnce.Do(func() {
+		close(p.stop)
+	})
+	p.wg.Wait()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+
+	var errs []error
+	for _, key := range p.keys {
+		if err := key.client.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, key := range p.retired {
+		if err := key.client.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	p.keys = nil
+	p.retired = nil
+
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to close clients: %v", errs)
+	}
+	return nil
+}
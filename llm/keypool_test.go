@@ -0,0 +1,236 @@
+package llm_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sieglu2/go_foundation/llm"
+)
+
+// newFakeKeyClient answers with its api key, or with the error set for it in errs.
+func newFakeKeyClient(apiKey string, errs *sync.Map) *fakeClient {
+	return &fakeClient{reply: func(call int, messages []llm.LlmMessage) (*llm.LlmReply, error) {
+		if err, ok := errs.Load(apiKey); ok {
+			return nil, err.(error)
+		}
+		return &llm.LlmReply{Content: apiKey}, nil
+	}}
+}
+
+func newTestKeyPool(t *testing.T, keys *[]string, errs *sync.Map, config llm.KeyPoolConfig) *llm.KeyPool {
+	var mu sync.Mutex
+	pool, err := llm.NewKeyPool(context.Background(),
+		func(ctx context.Context) ([]string, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			return append([]string(nil), *keys...), nil
+		},
+		func(ctx context.Context, apiKey string) (llm.LlmClient, error) {
+			return newFakeKeyClient(apiKey, errs), nil
+		},
+		config,
+	)
+	if err != nil {
+		t.Fatalf("NewKeyPool failed: %v", err)
+	}
+	t.Cleanup(func() { pool.Close() })
+	return pool
+}
+
+func reply(t *testing.T, pool *llm.KeyPool) string {
+	content, err := pool.ReplyMessage(context.Background(), []llm.LlmMessage{{Role: llm.RoleUser, Content: "hi"}})
+	if err != nil {
+		t.Fatalf("ReplyMessage failed: %v", err)
+	}
+	return content
+}
+
+func TestKeyPool(t *testing.T) {
+	t.Run("Round robin spreads calls over keys", func(t *testing.T) {
+		keys := []string{"key-a", "key-b", "key-c"}
+		pool := newTestKeyPool(t, &keys, &sync.Map{}, llm.KeyPoolConfig{})
+
+		for i, expected := range []string{"key-a", "key-b", "key-c", "key-a"} {
+			if got := reply(t, pool); got != expected {
+				t.Fatalf("call %d: expected %s, got %s", i, expected, got)
+			}
+		}
+	})
+
+	t.Run("Throttled key is benched and the call retried", func(t *testing.T) {
+		keys := []string{"key-a", "key-b"}
+		errs := &sync.Map{}
+		errs.Store("key-a", &llm.ApiError{StatusCode: http.StatusTooManyRequests, Message: "slow down"})
+		pool := newTestKeyPool(t, &keys, errs, llm.KeyPoolConfig{})
+
+		for i := 0; i < 3; i++ {
+			if got := reply(t, pool); got != "key-b" {
+				t.Fatalf("call %d: expected key-b, got %s", i, got)
+			}
+		}
+	})
+
+	t.Run("Other errors are not retried", func(t *testing.T) {
+		keys := []string{"key-a", "key-b"}
+		errs := &sync.Map{}
+		errs.Store("key-a", &llm.ApiError{StatusCode: http.StatusBadRequest, Message: "bad request"})
+		pool := newTestKeyPool(t, &keys, errs, llm.KeyPoolConfig{})
+
+		_, err := pool.ReplyMessage(context.Background(), []llm.LlmMessage{{Role: llm.RoleUser, Content: "hi"}})
+		if llm.HttpStatusCode(err) != http.StatusBadRequest {
+			t.Fatalf("expected the 400 error, got: %v", err)
+		}
+	})
+
+	t.Run("All keys benched fails fast", func(t *testing.T) {
+		keys := []string{"key-a", "key-b"}
+		errs := &sync.Map{}
+		errs.Store("key-a", &llm.ApiError{StatusCode: http.StatusUnauthorized, Message: "invalid key"})
+		errs.Store("key-b", &llm.ApiError{StatusCode: http.StatusTooManyRequests, Message: "slow down", RetryAfter: time.Minute})
+		pool := newTestKeyPool(t, &keys, errs, llm.KeyPoolConfig{})
+
+		_, err := pool.ReplyMessage(context.Background(), []llm.LlmMessage{{Role: llm.RoleUser, Content: "hi"}})
+		if err == nil {
+			t.Fatalf("ReplyMessage should fail when every key fails")
+		}
+
+		_, err = pool.ReplyMessage(context.Background(), []llm.LlmMessage{{Role: llm.RoleUser, Content: "hi"}})
+		if !errors.Is(err, llm.ErrNoKeyAvailable) {
+			t.Fatalf("expected ErrNoKeyAvailable, got: %v", err)
+		}
+	})
+
+	t.Run("Least recently throttled prefers keys never throttled", func(t *testing.T) {
+		keys := []string{"key-a", "key-b"}
+		errs := &sync.Map{}
+		errs.Store("key-a", &llm.ApiError{StatusCode: http.StatusTooManyRequests, Message: "slow down"})
+		pool := newTestKeyPool(t, &keys, errs, llm.KeyPoolConfig{
+			Selection:      llm.KeySelectionLeastRecentlyThrottled,
+			ThrottledBench: time.Millisecond,
+		})
+
+		if got := reply(t, pool); got != "key-b" {
+			t.Fatalf("expected key-b, got %s", got)
+		}
+		errs.Delete("key-a")
+		time.Sleep(5 * time.Millisecond)
+
+		// key-a is back from the bench but was throttled, key-b never was
+		for i := 0; i < 3; i++ {
+			if got := reply(t, pool); got != "key-b" {
+				t.Fatalf("call %d: expected key-b, got %s", i, got)
+			}
+		}
+	})
+
+	t.Run("Reload picks up rotated keys", func(t *testing.T) {
+		keys := []string{"key-a"}
+		pool := newTestKeyPool(t, &keys, &sync.Map{}, llm.KeyPoolConfig{})
+
+		if got := reply(t, pool); got != "key-a" {
+			t.Fatalf("expected key-a, got %s", got)
+		}
+
+		keys = []string{"key-b"}
+		if err := pool.Reload(context.Background()); err != nil {
+			t.Fatalf("Reload failed: %v", err)
+		}
+		if got := reply(t, pool); got != "key-b" {
+			t.Fatalf("expected key-b after reload, got %s", got)
+		}
+	})
+}
+
+func TestKeyPoolClosesClients(t *testing.T) {
+	ctx := context.Background()
+
+	var mu sync.Mutex
+	keys := []string{"key-a", "key-b"}
+	clients := map[string]*fakeClient{}
+	started, block := make(chan struct{}), make(chan struct{})
+	pool, err := llm.NewKeyPool(ctx,
+		func(ctx context.Context) ([]string, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			return append([]string(nil), keys...), nil
+		},
+		func(ctx context.Context, apiKey string) (llm.LlmClient, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if apiKey == "bad" {
+				return nil, errors.New("invalid key")
+			}
+			client := newFakeKeyClient(apiKey, &sync.Map{})
+			if apiKey == "key-a" {
+				client.started, client.block = started, block
+			}
+			clients[apiKey] = client
+			return client, nil
+		},
+		llm.KeyPoolConfig{},
+	)
+	if err != nil {
+		t.Fatalf("NewKeyPool failed: %v", err)
+	}
+	defer pool.Close()
+
+	client := func(apiKey string) *fakeClient {
+		mu.Lock()
+		defer mu.Unlock()
+		return clients[apiKey]
+	}
+	setKeys := func(newKeys ...string) {
+		mu.Lock()
+		defer mu.Unlock()
+		keys = newKeys
+	}
+
+	// key-a, first in turn, blocks until released
+	done := make(chan string)
+	go func() {
+		content, _ := pool.ReplyMessage(ctx, []llm.LlmMessage{{Role: llm.RoleUser, Content: "hi"}})
+		done <- content
+	}()
+	<-started
+
+	t.Run("Removed idle key closed on reload", func(t *testing.T) {
+		setKeys("key-a", "key-c")
+		if err := pool.Reload(ctx); err != nil {
+			t.Fatalf("Reload failed: %v", err)
+		}
+		if !client("key-b").closed.Load() {
+			t.Fatalf("the client of key-b should be closed")
+		}
+	})
+
+	t.Run("Removed busy key closed after its call", func(t *testing.T) {
+		setKeys("key-c")
+		if err := pool.Reload(ctx); err != nil {
+			t.Fatalf("Reload failed: %v", err)
+		}
+		if client("key-a").closed.Load() {
+			t.Fatalf("the client of key-a should wait for its call")
+		}
+		close(block)
+		if content := <-done; content != "key-a" {
+			t.Fatalf("expected key-a, got %s", content)
+		}
+		if !client("key-a").closed.Load() {
+			t.Fatalf("the client of key-a should be closed after its call")
+		}
+	})
+
+	t.Run("Partial reload closes its clients", func(t *testing.T) {
+		setKeys("key-c", "key-d", "bad")
+		if err := pool.Reload(ctx); err == nil {
+			t.Fatalf("Reload should fail on the bad key")
+		}
+		if !client("key-d").closed.Load() || client("key-c").closed.Load() {
+			t.Fatalf("expected only the client created by the failed reload closed")
+		}
+	})
+}
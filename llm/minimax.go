@@ -12,10 +12,8 @@ import (
 )
 
 const (
-	minimaxApiEndpoint = "https://api.minimaxi.chat/v1/text/chatcompletion_v2"
-
-	minimaxSecretAccountName string = "my_hailuoai"
-	minimaxSecretServiceName string = "minimax"
+	minimaxApiEndpoint  = "https://api.minimaxi.chat/v1/text/chatcompletion_v2"
+	defaultMinimaxModel = "MiniMax-Text-01"
 )
 
 type MinimaxClient struct {
@@ -72,7 +70,7 @@ type MinimaxUsage struct {
 }
 
 func NewMinimaxClient(apiKey string) *MinimaxClient {
-	return NewMinimaxClientWithConfig(apiKey, DefaultMaxTokens, "MiniMax-Text-01")
+	return NewMinimaxClientWithConfig(apiKey, DefaultMaxTokens, defaultMinimaxModel)
 }
 
 func NewMinimaxClientWithConfig(apiKey string, maxTokens int, model string) *MinimaxClient {
@@ -121,7 +119,7 @@ func convertToMinimaxMessages(llmMessages []LlmMessage) ([]MinimaxMessage, error
 				{
 					Type: "image_url",
 					ImageURL: &MinimaxImageURL{
-						URL: fmt.Sprintf("data:image/png;base64,%s", msg.B64Image),
+						URL: fmt.Sprintf("data:%s;base64,%s", msg.imageMediaType(), msg.B64Image),
 					},
 				},
 			}
@@ -135,12 +133,20 @@ func convertToMinimaxMessages(llmMessages []LlmMessage) ([]MinimaxMessage, error
 }
 
 func (m *MinimaxClient) ReplyMessage(ctx context.Context, llmMessages []LlmMessage) (string, error) {
+	reply, err := m.ReplyMessageDetail(ctx, llmMessages)
+	if err != nil {
+		return "", err
+	}
+	return reply.Content, nil
+}
+
+func (m *MinimaxClient) ReplyMessageDetail(ctx context.Context, llmMessages []LlmMessage) (*LlmReply, error) {
 	logger := foundation.Logger()
 
 	messages, err := convertToMinimaxMessages(llmMessages)
 	if err != nil {
 		logger.Errorf("failed to convertToMinimaxMessages: %v", err)
-		return "", fmt.Errorf("failed to convertToMinimaxMessages: %v", err)
+		return nil, fmt.Errorf("failed to convertToMinimaxMessages: %v", err)
 	}
 
 	request := MinimaxRequest{
@@ -151,13 +157,13 @@ func (m *MinimaxClient) ReplyMessage(ctx context.Context, llmMessages []LlmMessa
 	requestBody, err := json.Marshal(request)
 	if err != nil {
 		logger.Errorf("failed to marshal request: %v", err)
-		return "", fmt.Errorf("failed to marshal request: %v", err)
+		return nil, fmt.Errorf("failed to marshal request: %v", err)
 	}
 
 	req, err := http.NewRequestWithContext(ctx, "POST", minimaxApiEndpoint, bytes.NewBuffer(requestBody))
 	if err != nil {
 		logger.Errorf("failed to create request: %v", err)
-		return "", fmt.Errorf("failed to create request: %v", err)
+		return nil, fmt.Errorf("failed to create request: %v", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
@@ -166,35 +172,45 @@ func (m *MinimaxClient) ReplyMessage(ctx context.Context, llmMessages []LlmMessa
 	resp, err := m.client.Do(req)
 	if err != nil {
 		logger.Errorf("failed to send request: %v", err)
-		return "", fmt.Errorf("failed to send request: %v", err)
+		return nil, fmt.Errorf("failed to send request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		logger.Errorf("failed to read response body: %v", err)
-		return "", fmt.Errorf("failed to read response body: %v", err)
+		return nil, fmt.Errorf("failed to read response body: %v", err)
 	}
 
 	if resp.StatusCode != http.StatusOK {
 		logger.Errorf("received non-200 status code: %d, body: %s", resp.StatusCode, string(body))
-		return "", fmt.Errorf("API request failed with status code: %d", resp.StatusCode)
+		return nil, newApiError(ProviderMinimax, resp, "API request failed with status code: %d", resp.StatusCode)
 	}
 
 	var minimaxResponse MinimaxResponse
 	if err := json.Unmarshal(body, &minimaxResponse); err != nil {
 		logger.Errorf("failed to unmarshal response: %v", err)
-		return "", fmt.Errorf("failed to unmarshal response: %v", err)
+		return nil, fmt.Errorf("failed to unmarshal response: %v", err)
 	}
 
 	logger.Infof("received MiniMax response")
 
 	if len(minimaxResponse.Choices) == 0 {
 		logger.Errorf("empty resp.Choices")
-		return "", fmt.Errorf("no completion choices returned")
+		return nil, fmt.Errorf("no completion choices returned")
 	}
 
-	return minimaxResponse.Choices[0].Message.Content, nil
+	return &LlmReply{
+		Content:      minimaxResponse.Choices[0].Message.Content,
+		Model:        m.model,
+		RateLimit:    parseRateLimitHeaders(resp.Header),
+		FinishReason: minimaxResponse.Choices[0].FinishReason,
+		Usage: LlmUsage{
+			PromptTokens:     minimaxResponse.Usage.PromptTokens,
+			CompletionTokens: minimaxResponse.Usage.CompletionTokens,
+			TotalTokens:      minimaxResponse.Usage.TotalTokens,
+		},
+	}, nil
 }
 
 func getMinimaxRoleName(role LlmRole) string {
@@ -0,0 +1,95 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/sieglu2/go_foundation/foundation"
+	"google.golang.org/api/iterator"
+)
+
+// ModelLister is implemented by the clients whose provider lists the models available to
+// the api key.
+type ModelLister interface {
+	ListModels(ctx context.Context) ([]string, error)
+}
+
+var (
+	_ ModelLister = (*ClaudeClient)(nil)
+	_ ModelLister = (*ChatGptClient)(nil)
+	_ ModelLister = (*GeminiClient)(nil)
+	_ ModelLister = (*DeepseekClient)(nil)
+)
+
+// modelList is the response of the openai style models endpoints.
+type modelList struct {
+	Data []struct {
+		ID string `json:"id"`
+	} `json:"data"`
+}
+
+func (l *modelList) ids() []string {
+	ids := make([]string, 0, len(l.Data))
+	for _, model := range l.Data {
+		ids = append(ids, model.ID)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func (c *ClaudeClient) ListModels(ctx context.Context) ([]string, error) {
+	var models modelList
+	if err := c.callApi(ctx, http.MethodGet, c.baseURL+"/v1/models?limit=1000", nil, &models); err != nil {
+		return nil, err
+	}
+	return models.ids(), nil
+}
+
+func (t *ChatGptClient) ListModels(ctx context.Context) ([]string, error) {
+	logger := foundation.Logger()
+
+	models, err := t.client.ListModels(ctx)
+	if err != nil {
+		logger.Errorf("failed to ListModels: %v", err)
+		return nil, fmt.Errorf("failed to ListModels: %w", err)
+	}
+
+	ids := make([]string, 0, len(models.Models))
+	for _, model := range models.Models {
+		ids = append(ids, model.ID)
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+func (g *GeminiClient) ListModels(ctx context.Context) ([]string, error) {
+	logger := foundation.Logger()
+
+	var ids []string
+	models := g.client.ListModels(ctx)
+	for {
+		model, err := models.Next()
+		if errors.Is(err, iterator.Done) {
+			break
+		}
+		if err != nil {
+			logger.Errorf("failed to ListModels: %v", err)
+			return nil, fmt.Errorf("failed to ListModels: %w", err)
+		}
+		ids = append(ids, strings.TrimPrefix(model.Name, "models/"))
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+func (d *DeepseekClient) ListModels(ctx context.Context) ([]string, error) {
+	var models modelList
+	if _, err := d.callApi(ctx, http.MethodGet, "/models", nil, &models); err != nil {
+		return nil, err
+	}
+	return models.ids(), nil
+}
@@ -0,0 +1,96 @@
+// Package parse extracts the usual shapes out of llm replies: fenced code blocks, json
+// values, lists and key: value pairs, tolerating the prose models put around them.
+package parse
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrNotFound is wrapped by the errors returned when the text holds no such shape.
+var ErrNotFound = errors.New("not found")
+
+type CodeBlock struct {
+	Language string
+	Code     string
+	// Closed is false for a block cut before its closing fence, e.g. by the token limit.
+	Closed bool
+}
+
+// ExtractCodeBlocks returns the fenced code blocks of text in order. Fences of backticks
+// or tildes of any length are accepted, and a last block missing its closing fence is
+// returned with Closed set to false.
+func ExtractCodeBlocks(text string) []CodeBlock {
+	var blocks []CodeBlock
+	var current *CodeBlock
+	var fence string
+	var lines []string
+
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(strings.TrimRight(line, "\r"))
+
+		if current == nil {
+			if marker := fenceMarker(trimmed); marker != "" {
+				current = &CodeBlock{Language: strings.TrimSpace(trimmed[len(marker):])}
+				fence = marker
+				lines = nil
+			}
+			continue
+		}
+
+		if strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == "" {
+			current.Code = strings.Join(lines, "\n")
+			current.Closed = true
+			blocks = append(blocks, *current)
+			current = nil
+			continue
+		}
+		lines = append(lines, strings.TrimRight(line, "\r"))
+	}
+
+	if current != nil {
+		current.Code = strings.Join(lines, "\n")
+		blocks = append(blocks, *current)
+	}
+	return blocks
+}
+
+// fenceMarker returns the opening fence of line, e.g. ``` or ~~~~, or "" if line does not
+// open a block.
+func fenceMarker(line string) string {
+	for _, char := range []string{"`", "~"} {
+		count := len(line) - len(strings.TrimLeft(line, char))
+		if count >= 3 {
+			return line[:count]
+		}
+	}
+	return ""
+}
+
+// ExtractCodeBlock returns the first fenced code block of the given language, compared
+// case-insensitively, or the first block at all if language is empty.
+func ExtractCodeBlock(text string, language string) (CodeBlock, error) {
+	blocks := ExtractCodeBlocks(text)
+	for _, block := range blocks {
+		if language == "" || strings.EqualFold(block.Language, language) {
+			return block, nil
+		}
+	}
+
+	if language == "" {
+		return CodeBlock{}, fmt.Errorf("%w: no fenced code block in %s", ErrNotFound, snippet(text))
+	}
+	return CodeBlock{}, fmt.Errorf("%w: no %s code block among %d blocks in %s", ErrNotFound, language, len(blocks), snippet(text))
+}
+
+// snippet quotes the start of text for error messages.
+func snippet(text string) string {
+	const maxLength = 80
+
+	text = strings.TrimSpace(text)
+	if len(text) > maxLength {
+		return fmt.Sprintf("%q...", text[:maxLength])
+	}
+	return fmt.Sprintf("%q", text)
+}
@@ -0,0 +1,161 @@
+package parse
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ExtractJSON finds the json object or array in text and unmarshals it into v. The json
+// fenced blocks are tried first, then the other blocks, then the balanced {...} or [...]
+// spans of the raw text. Trailing commas are tolerated.
+func ExtractJSON(text string, v any) error {
+	candidates := jsonCandidates(text)
+	if len(candidates) == 0 {
+		return fmt.Errorf("%w: no json object or array in %s", ErrNotFound, snippet(text))
+	}
+
+	var firstErr error
+	for _, candidate := range candidates {
+		err := json.Unmarshal([]byte(removeTrailingCommas(candidate)), v)
+		if err == nil {
+			return nil
+		}
+		if firstErr == nil {
+			firstErr = describeJSONError(candidate, err)
+		}
+	}
+	return firstErr
+}
+
+// ParseJSON is ExtractJSON returning the value as T.
+func ParseJSON[T any](text string) (T, error) {
+	var value T
+	err := ExtractJSON(text, &value)
+	return value, err
+}
+
+func jsonCandidates(text string) []string {
+	var candidates []string
+	var others []string
+	for _, block := range ExtractCodeBlocks(text) {
+		if strings.EqualFold(block.Language, "json") {
+			candidates = append(candidates, jsonSpans(block.Code)...)
+		} else {
+			others = append(others, jsonSpans(block.Code)...)
+		}
+	}
+	candidates = append(candidates, others...)
+	return append(candidates, jsonSpans(text)...)
+}
+
+// jsonSpans returns the balanced {...} and [...] spans of text, outermost only, and the
+// rest of the text from the first opening bracket that is never closed, so truncated json
+// gets a descriptive error. A bracket never closed, such as one in prose, is skipped and
+// the scan goes on after it.
+func jsonSpans(text string) []string {
+	var spans []string
+	unclosed := false
+	for start := 0; start < len(text); start++ {
+		if text[start] != '{' && text[start] != '[' {
+			continue
+		}
+
+		end := matchBracket(text, start)
+		if end < 0 {
+			if !unclosed {
+				spans = append(spans, text[start:])
+				unclosed = true
+			}
+			continue
+		}
+		spans = append(spans, text[start:end+1])
+		start = end
+	}
+	return spans
+}
+
+// matchBracket returns the index of the bracket closing the one at start, skipping the
+// brackets inside json strings, or -1 if it is never closed.
+func matchBracket(text string, start int) int {
+	var stack []byte
+	inString, escaped := false, false
+
+	for i := start; i < len(text); i++ {
+		char := text[i]
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case char == '\\':
+				escaped = true
+			case char == '"':
+				inString = false
+			}
+			continue
+		}
+
+		switch char {
+		case '"':
+			inString = true
+		case '{':
+			stack = append(stack, '}')
+		case '[':
+			stack = append(stack, ']')
+		case '}', ']':
+			if len(stack) == 0 || stack[len(stack)-1] != char {
+				return -1
+			}
+			stack = stack[:len(stack)-1]
+			if len(stack) == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// removeTrailingCommas drops the commas directly followed by a closing bracket, outside
+// of json strings.
+func removeTrailingCommas(text string) string {
+	var builder strings.Builder
+	inString, escaped := false, false
+
+	for i := 0; i < len(text); i++ {
+		char := text[i]
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case char == '\\':
+				escaped = true
+			case char == '"':
+				inString = false
+			}
+			builder.WriteByte(char)
+			continue
+		}
+
+		if char == '"' {
+			inString = true
+		}
+		if char == ',' {
+			rest := strings.TrimLeft(text[i+1:], " \t\r\n")
+			if strings.HasPrefix(rest, "}") || strings.HasPrefix(rest, "]") {
+				continue
+			}
+		}
+		builder.WriteByte(char)
+	}
+	return builder.String()
+}
+
+func describeJSONError(candidate string, err error) error {
+	if syntaxErr, ok := err.(*json.SyntaxError); ok {
+		if matchBracket(candidate, 0) < 0 {
+			return fmt.Errorf("json starting with %s is truncated or unbalanced: %v", snippet(candidate), err)
+		}
+		return fmt.Errorf("invalid json at offset %d of %s: %v", syntaxErr.Offset, snippet(candidate), err)
+	}
+	return fmt.Errorf("failed to Unmarshal json %s: %v", snippet(candidate), err)
+}
@@ -0,0 +1,64 @@
+package parse
+
+import (
+	"fmt"
+	"strings"
+)
+
+type KeyValue struct {
+	Key   string
+	Value string
+}
+
+// ExtractKeyValues returns the "key: value" lines of text in order. Bullets and markdown
+// emphasis around the key, like "- **Name**: value", are dropped. Lines with an empty value,
+// like "Here is the summary:", or a key longer than 5 words are taken for prose and skipped.
+func ExtractKeyValues(text string) ([]KeyValue, error) {
+	var pairs []KeyValue
+	for _, line := range strings.Split(text, "\n") {
+		match := listItemPattern.FindStringSubmatch(line)
+		if match != nil {
+			line = match[1]
+		}
+
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+
+		// a colon of a url is not a separator
+		if strings.HasPrefix(value, "//") {
+			continue
+		}
+
+		key = strings.TrimSpace(strings.Trim(strings.TrimSpace(key), "*_`"))
+		value = strings.TrimSpace(strings.Trim(strings.TrimSpace(value), "*_"))
+		if key == "" || value == "" || len(strings.Fields(key)) > 5 {
+			continue
+		}
+		pairs = append(pairs, KeyValue{Key: key, Value: value})
+	}
+
+	if len(pairs) == 0 {
+		return nil, fmt.Errorf("%w: no key: value line in %s", ErrNotFound, snippet(text))
+	}
+	return pairs, nil
+}
+
+// ExtractKeyValueMap is ExtractKeyValues as a map keyed by the lower case keys, the first
+// value winning for keys given twice.
+func ExtractKeyValueMap(text string) (map[string]string, error) {
+	pairs, err := ExtractKeyValues(text)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		key := strings.ToLower(pair.Key)
+		if _, ok := values[key]; !ok {
+			values[key] = pair.Value
+		}
+	}
+	return values, nil
+}
@@ -0,0 +1,38 @@
+package parse
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var listItemPattern = regexp.MustCompile(`^\s*(?:\d{1,3}[.)]|[-*+•])\s+(.*)$`)
+
+// ExtractList returns the items of the first numbered or bulleted list of text, e.g.
+// "1. first", "2) second" or "- item". Indented lines following an item are joined to it,
+// and blank lines between items do not end the list.
+func ExtractList(text string) ([]string, error) {
+	var items []string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimRight(line, "\r")
+
+		if match := listItemPattern.FindStringSubmatch(line); match != nil {
+			items = append(items, strings.TrimSpace(match[1]))
+			continue
+		}
+		if len(items) == 0 || strings.TrimSpace(line) == "" {
+			continue
+		}
+		if strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") {
+			items[len(items)-1] += " " + strings.TrimSpace(line)
+			continue
+		}
+		// prose after the list ends it
+		break
+	}
+
+	if len(items) == 0 {
+		return nil, fmt.Errorf("%w: no numbered or bulleted list in %s", ErrNotFound, snippet(text))
+	}
+	return items, nil
+}
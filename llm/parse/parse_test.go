@@ -0,0 +1,181 @@
+package parse_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/sieglu2/go_foundation/llm/parse"
+)
+
+func TestExtractCodeBlocks(t *testing.T) {
+	t.Run("Blocks with prose around", func(t *testing.T) {
+		text := "Here is the fix:\n```go\nfunc main() {}\n```\nand the test:\n~~~~python\nassert True\n~~~~\nDone."
+
+		blocks := parse.ExtractCodeBlocks(text)
+		if len(blocks) != 2 {
+			t.Fatalf("expected 2 blocks, got %+v", blocks)
+		}
+		if blocks[0].Language != "go" || blocks[0].Code != "func main() {}" || !blocks[0].Closed {
+			t.Fatalf("unexpected first block: %+v", blocks[0])
+		}
+		if blocks[1].Language != "python" || blocks[1].Code != "assert True" {
+			t.Fatalf("unexpected second block: %+v", blocks[1])
+		}
+	})
+
+	t.Run("Nested shorter fence stays in the block", func(t *testing.T) {
+		text := "````markdown\n```go\nx := 1\n```\n````"
+
+		block, err := parse.ExtractCodeBlock(text, "markdown")
+		if err != nil {
+			t.Fatalf("ExtractCodeBlock failed: %v", err)
+		}
+		if block.Code != "```go\nx := 1\n```" {
+			t.Fatalf("unexpected code: %q", block.Code)
+		}
+	})
+
+	t.Run("Unclosed last block", func(t *testing.T) {
+		block, err := parse.ExtractCodeBlock("```sql\nSELECT 1\nFROM", "")
+		if err != nil {
+			t.Fatalf("ExtractCodeBlock failed: %v", err)
+		}
+		if block.Closed || block.Code != "SELECT 1\nFROM" {
+			t.Fatalf("unexpected block: %+v", block)
+		}
+	})
+
+	t.Run("Missing language", func(t *testing.T) {
+		_, err := parse.ExtractCodeBlock("```go\nx\n```", "rust")
+		if !errors.Is(err, parse.ErrNotFound) || !strings.Contains(err.Error(), "rust") {
+			t.Fatalf("expected a not found error naming rust, got: %v", err)
+		}
+	})
+}
+
+func TestExtractJSON(t *testing.T) {
+	type answer struct {
+		Name  string   `json:"name"`
+		Score int      `json:"score"`
+		Tags  []string `json:"tags"`
+	}
+
+	t.Run("Object in prose with trailing commas", func(t *testing.T) {
+		text := `Sure! Here is the result {"name": "a, b", "score": 3, "tags": ["x", "y",],} hope it helps`
+
+		value, err := parse.ParseJSON[answer](text)
+		if err != nil {
+			t.Fatalf("ParseJSON failed: %v", err)
+		}
+		if value.Name != "a, b" || value.Score != 3 || len(value.Tags) != 2 {
+			t.Fatalf("unexpected value: %+v", value)
+		}
+	})
+
+	t.Run("Json fence wins over other brackets", func(t *testing.T) {
+		text := "Note [1]: see below.\n```json\n{\"name\": \"fenced\", \"score\": 1}\n```"
+
+		value, err := parse.ParseJSON[answer](text)
+		if err != nil {
+			t.Fatalf("ParseJSON failed: %v", err)
+		}
+		if value.Name != "fenced" {
+			t.Fatalf("expected the fenced object, got %+v", value)
+		}
+	})
+
+	t.Run("Skips brackets that are not the value", func(t *testing.T) {
+		text := "Using {placeholder} syntax: [{\"name\": \"first\"}, {\"name\": \"second\"}]"
+
+		values, err := parse.ParseJSON[[]answer](text)
+		if err != nil {
+			t.Fatalf("ParseJSON failed: %v", err)
+		}
+		if len(values) != 2 || values[1].Name != "second" {
+			t.Fatalf("unexpected values: %+v", values)
+		}
+	})
+
+	t.Run("Brackets inside strings", func(t *testing.T) {
+		value, err := parse.ParseJSON[answer](`{"name": "has } and \" inside", "score": 2}`)
+		if err != nil {
+			t.Fatalf("ParseJSON failed: %v", err)
+		}
+		if value.Name != `has } and " inside` {
+			t.Fatalf("unexpected name: %q", value.Name)
+		}
+	})
+
+	t.Run("Unclosed bracket in prose skipped", func(t *testing.T) {
+		var v map[string]int
+		if err := parse.ExtractJSON(`Options are [x, y and then here it is: {"a": 1}`, &v); err != nil || v["a"] != 1 {
+			t.Fatalf("expected the object after the bracket, got %v: %v", v, err)
+		}
+	})
+
+	t.Run("Truncated json", func(t *testing.T) {
+		_, err := parse.ParseJSON[answer](`{"name": "cut", "score": `)
+		if err == nil || !strings.Contains(err.Error(), "truncated") {
+			t.Fatalf("expected a truncated error, got: %v", err)
+		}
+	})
+
+	t.Run("No json", func(t *testing.T) {
+		_, err := parse.ParseJSON[answer]("no structured data here")
+		if !errors.Is(err, parse.ErrNotFound) {
+			t.Fatalf("expected ErrNotFound, got: %v", err)
+		}
+	})
+}
+
+func TestExtractList(t *testing.T) {
+	t.Run("Numbered list with continuation", func(t *testing.T) {
+		text := "The steps are:\n1. Install\n2) Configure the\n   environment\n\n3. Run\nThat is all."
+
+		items, err := parse.ExtractList(text)
+		if err != nil {
+			t.Fatalf("ExtractList failed: %v", err)
+		}
+		expected := []string{"Install", "Configure the environment", "Run"}
+		if strings.Join(items, "|") != strings.Join(expected, "|") {
+			t.Fatalf("expected %v, got %v", expected, items)
+		}
+	})
+
+	t.Run("Bulleted list", func(t *testing.T) {
+		items, err := parse.ExtractList("- red\n* green\n• blue")
+		if err != nil {
+			t.Fatalf("ExtractList failed: %v", err)
+		}
+		if strings.Join(items, "|") != "red|green|blue" {
+			t.Fatalf("unexpected items: %v", items)
+		}
+	})
+
+	t.Run("No list", func(t *testing.T) {
+		if _, err := parse.ExtractList("just a sentence"); !errors.Is(err, parse.ErrNotFound) {
+			t.Fatalf("expected ErrNotFound, got: %v", err)
+		}
+	})
+}
+
+func TestExtractKeyValues(t *testing.T) {
+	text := "Here is the summary:\n- **Name**: Ada\n**Role:** engineer\nSee https://example.com for more\nname: duplicate"
+
+	pairs, err := parse.ExtractKeyValues(text)
+	if err != nil {
+		t.Fatalf("ExtractKeyValues failed: %v", err)
+	}
+	if len(pairs) != 3 || pairs[0] != (parse.KeyValue{Key: "Name", Value: "Ada"}) || pairs[1] != (parse.KeyValue{Key: "Role", Value: "engineer"}) {
+		t.Fatalf("unexpected pairs: %+v", pairs)
+	}
+
+	values, err := parse.ExtractKeyValueMap(text)
+	if err != nil {
+		t.Fatalf("ExtractKeyValueMap failed: %v", err)
+	}
+	if values["name"] != "Ada" {
+		t.Fatalf("expected the first name to win, got %q", values["name"])
+	}
+}
@@ -0,0 +1,28 @@
+// Code generated by providergen from provider.json. DO NOT EDIT.
+
+package llm
+
+const (
+	ProviderChatGpt  = "chatgpt"
+	ProviderClaude   = "claude"
+	ProviderDeepseek = "deepseek"
+	ProviderGemini   = "gemini"
+	ProviderMinimax  = "minimax"
+)
+
+const (
+	chatgptSecretAccountName string = "my_openai"
+	chatgptSecretServiceName string = "chatgpt"
+
+	claudeSecretAccountName string = "my_anthropic"
+	claudeSecretServiceName string = "claude"
+
+	deepseekSecretAccountName string = "my_deepseek"
+	deepseekSecretServiceName string = "deepseek"
+
+	geminiSecretAccountName string = "my_google"
+	geminiSecretServiceName string = "gemini"
+
+	minimaxSecretAccountName string = "my_hailuoai"
+	minimaxSecretServiceName string = "minimax"
+)
@@ -0,0 +1,286 @@
+package llm
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sieglu2/go_foundation/foundation"
+	"golang.org/x/time/rate"
+)
+
+// LlmRateLimit is the rate limit state reported by the provider in its response headers.
+// HasRemainingRequests and HasRemainingTokens tell a remaining count of 0 from a missing
+// header, some gateways only sending the limits.
+type LlmRateLimit struct {
+	LimitRequests        int           `json:"limit_requests"`
+	RemainingRequests    int           `json:"remaining_requests"`
+	HasRemainingRequests bool          `json:"has_remaining_requests"`
+	ResetRequests        time.Duration `json:"reset_requests"`
+	LimitTokens          int           `json:"limit_tokens"`
+	RemainingTokens      int           `json:"remaining_tokens"`
+	HasRemainingTokens   bool          `json:"has_remaining_tokens"`
+	ResetTokens          time.Duration `json:"reset_tokens"`
+}
+
+// parseRateLimitHeaders reads the openai style x-ratelimit-* headers, also sent by the
+// compatible apis, and the anthropic-ratelimit-* headers. It returns nil if there are none.
+func parseRateLimitHeaders(header http.Header) *LlmRateLimit {
+	for _, names := range [][6]string{
+		{
+			"x-ratelimit-limit-requests", "x-ratelimit-remaining-requests", "x-ratelimit-reset-requests",
+			"x-ratelimit-limit-tokens", "x-ratelimit-remaining-tokens", "x-ratelimit-reset-tokens",
+		},
+		{
+			"anthropic-ratelimit-requests-limit", "anthropic-ratelimit-requests-remaining", "anthropic-ratelimit-requests-reset",
+			"anthropic-ratelimit-tokens-limit", "anthropic-ratelimit-tokens-remaining", "anthropic-ratelimit-tokens-reset",
+		},
+	} {
+		if header.Get(names[0]) == "" && header.Get(names[3]) == "" {
+			continue
+		}
+
+		limitRequests, _ := strconv.Atoi(header.Get(names[0]))
+		remainingRequests, remainingRequestsErr := strconv.Atoi(header.Get(names[1]))
+		limitTokens, _ := strconv.Atoi(header.Get(names[3]))
+		remainingTokens, remainingTokensErr := strconv.Atoi(header.Get(names[4]))
+		return &LlmRateLimit{
+			LimitRequests:        limitRequests,
+			RemainingRequests:    remainingRequests,
+			HasRemainingRequests: remainingRequestsErr == nil,
+			ResetRequests:        parseRateLimitReset(header.Get(names[2])),
+			LimitTokens:          limitTokens,
+			RemainingTokens:      remainingTokens,
+			HasRemainingTokens:   remainingTokensErr == nil,
+			ResetTokens:          parseRateLimitReset(header.Get(names[5])),
+		}
+	}
+	return nil
+}
+
+// parseRateLimitReset accepts a duration like openai's "6m0s" or a timestamp like anthropic's.
+func parseRateLimitReset(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if duration, err := time.ParseDuration(value); err == nil {
+		return duration
+	}
+	if date, err := time.Parse(time.RFC3339, value); err == nil {
+		return time.Until(date)
+	}
+	return 0
+}
+
+// RateLimits are the limits of a provider account, 0 meaning unlimited.
+type RateLimits struct {
+	RequestsPerMinute int
+	TokensPerMinute   int
+}
+
+// DefaultRateLimits are conservative limits of the entry tiers of the providers. They are
+// adapted from the rate limit headers once the provider reports its real ones.
+var DefaultRateLimits = map[string]RateLimits{
+	ProviderChatGpt:  {RequestsPerMinute: 500, TokensPerMinute: 30000},
+	ProviderClaude:   {RequestsPerMinute: 50, TokensPerMinute: 40000},
+	ProviderDeepseek: {RequestsPerMinute: 60, TokensPerMinute: 0},
+	ProviderGemini:   {RequestsPerMinute: 60, TokensPerMinute: 1000000},
+	ProviderMinimax:  {RequestsPerMinute: 120, TokensPerMinute: 0},
+}
+
+// RateLimiter holds a token bucket for the requests and one for the tokens of a provider
+// account. The buckets hold 10 seconds worth of the limits, so callers starting together
+// are spread instead of hitting the provider at once.
+type RateLimiter struct {
+	requests *rate.Limiter
+	tokens   *rate.Limiter
+
+	mu          sync.Mutex
+	limits      RateLimits
+	pausedUntil time.Time
+}
+
+func NewRateLimiter(limits RateLimits) *RateLimiter {
+	return &RateLimiter{
+		requests: rate.NewLimiter(perMinute(limits.RequestsPerMinute)),
+		tokens:   rate.NewLimiter(perMinute(limits.TokensPerMinute)),
+		limits:   limits,
+	}
+}
+
+func (r *RateLimiter) Limits() RateLimits {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.limits
+}
+
+func (r *RateLimiter) SetLimits(limits RateLimits) {
+	r.mu.Lock()
+	r.limits = limits
+	r.mu.Unlock()
+
+	limit, burst := perMinute(limits.RequestsPerMinute)
+	r.requests.SetLimit(limit)
+	r.requests.SetBurst(burst)
+
+	limit, burst = perMinute(limits.TokensPerMinute)
+	r.tokens.SetLimit(limit)
+	r.tokens.SetBurst(burst)
+}
+
+// perMinute returns the rate and burst of a bucket holding 10 seconds of the limit.
+func perMinute(limit int) (rate.Limit, int) {
+	if limit <= 0 {
+		return rate.Inf, 0
+	}
+	return rate.Limit(float64(limit) / 60), max(1, limit/6)
+}
+
+// Pause blocks every caller for duration, e.g. after a 429 with Retry-After.
+func (r *RateLimiter) Pause(duration time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	until := time.Now().Add(duration)
+	if until.After(r.pausedUntil) {
+		r.pausedUntil = until
+	}
+}
+
+// Wait blocks until a request of the given number of tokens fits in the limits, or ctx is done.
+func (r *RateLimiter) Wait(ctx context.Context, tokens int) error {
+	r.mu.Lock()
+	pause := time.Until(r.pausedUntil)
+	r.mu.Unlock()
+
+	if pause > 0 {
+		timer := time.NewTimer(pause)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	if err := r.requests.Wait(ctx); err != nil {
+		return err
+	}
+	return r.tokens.WaitN(ctx, r.clampTokens(tokens))
+}
+
+// consume charges tokens used beyond what Wait was given, delaying the next callers
+// instead of the current one.
+func (r *RateLimiter) consume(tokens int) {
+	if tokens > 0 {
+		r.tokens.ReserveN(time.Now(), r.clampTokens(tokens))
+	}
+}
+
+// clampTokens keeps a call larger than the bucket from failing, it then waits for a full bucket.
+func (r *RateLimiter) clampTokens(tokens int) int {
+	if burst := r.tokens.Burst(); r.tokens.Limit() != rate.Inf && tokens > burst {
+		return burst
+	}
+	return tokens
+}
+
+// Update adapts the limits to the ones reported by the provider, and pauses until the
+// reset when the provider reports nothing remains. A missing remaining count never pauses.
+func (r *RateLimiter) Update(rateLimit *LlmRateLimit) {
+	if rateLimit == nil {
+		return
+	}
+
+	limits := r.Limits()
+	if rateLimit.LimitRequests > 0 {
+		limits.RequestsPerMinute = rateLimit.LimitRequests
+	}
+	if rateLimit.LimitTokens > 0 {
+		limits.TokensPerMinute = rateLimit.LimitTokens
+	}
+	if limits != r.Limits() {
+		r.SetLimits(limits)
+	}
+
+	if rateLimit.LimitRequests > 0 && rateLimit.HasRemainingRequests && rateLimit.RemainingRequests == 0 && rateLimit.ResetRequests > 0 {
+		r.Pause(rateLimit.ResetRequests)
+	}
+	if rateLimit.LimitTokens > 0 && rateLimit.HasRemainingTokens && rateLimit.RemainingTokens == 0 && rateLimit.ResetTokens > 0 {
+		r.Pause(rateLimit.ResetTokens)
+	}
+}
+
+var (
+	keyRateLimitersMu sync.Mutex
+	keyRateLimiters   = map[string]*RateLimiter{}
+)
+
+// KeyRateLimiter returns the rate limiter of an api key of a provider, shared by every
+// RateLimitedClient of that key, starting from DefaultRateLimits.
+func KeyRateLimiter(provider string, apiKey string) *RateLimiter {
+	keyRateLimitersMu.Lock()
+	defer keyRateLimitersMu.Unlock()
+
+	id := provider + "/" + apiKey
+	limiter, ok := keyRateLimiters[id]
+	if !ok {
+		limiter = NewRateLimiter(DefaultRateLimits[provider])
+		keyRateLimiters[id] = limiter
+	}
+	return limiter
+}
+
+// RateLimitedClient is an LlmClient waiting for its RateLimiter before every call, and
+// feeding it the rate limits and 429s reported by the provider.
+type RateLimitedClient struct {
+	client  LlmClient
+	limiter *RateLimiter
+}
+
+func NewRateLimitedClient(client LlmClient, limiter *RateLimiter) *RateLimitedClient {
+	return &RateLimitedClient{
+		client:  client,
+		limiter: limiter,
+	}
+}
+
+func (r *RateLimitedClient) ReplyMessage(ctx context.Context, messages []LlmMessage) (string, error) {
+	reply, err := r.ReplyMessageDetail(ctx, messages)
+	if err != nil {
+		return "", err
+	}
+	return reply.Content, nil
+}
+
+func (r *RateLimitedClient) ReplyMessageDetail(ctx context.Context, messages []LlmMessage) (*LlmReply, error) {
+	logger := foundation.Logger()
+
+	estimate := EstimateTokens(messages)
+	if err := r.limiter.Wait(ctx, estimate); err != nil {
+		return nil, err
+	}
+
+	reply, err := ReplyMessageDetail(ctx, r.client, messages)
+	if err != nil {
+		if HttpStatusCode(err) == http.StatusTooManyRequests {
+			retryAfter := RetryAfter(err)
+			if retryAfter <= 0 {
+				retryAfter = time.Second
+			}
+			logger.Warnf("rate limited by the provider, pausing for %v", retryAfter)
+			r.limiter.Pause(retryAfter)
+		}
+		return nil, err
+	}
+
+	r.limiter.consume(reply.Usage.TotalTokens - estimate)
+	r.limiter.Update(reply.RateLimit)
+	return reply, nil
+}
+
+func (r *RateLimitedClient) Close() error {
+	return r.client.Close()
+}
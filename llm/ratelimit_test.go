@@ -0,0 +1,150 @@
+package llm_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/sieglu2/go_foundation/llm"
+)
+
+func waitWithTimeout(limiter *llm.RateLimiter, tokens int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	return limiter.Wait(ctx, tokens)
+}
+
+func TestRateLimiter(t *testing.T) {
+	t.Run("Requests beyond the burst wait", func(t *testing.T) {
+		// 60 requests per minute gives a burst of 10 and then one per second
+		limiter := llm.NewRateLimiter(llm.RateLimits{RequestsPerMinute: 60})
+
+		for i := 0; i < 10; i++ {
+			if err := waitWithTimeout(limiter, 0); err != nil {
+				t.Fatalf("request %d should pass within the burst: %v", i, err)
+			}
+		}
+		if err := waitWithTimeout(limiter, 0); err == nil {
+			t.Fatalf("request beyond the burst should not pass within 100ms")
+		}
+	})
+
+	t.Run("Tokens beyond the burst wait", func(t *testing.T) {
+		limiter := llm.NewRateLimiter(llm.RateLimits{TokensPerMinute: 600})
+
+		if err := waitWithTimeout(limiter, 100); err != nil {
+			t.Fatalf("100 tokens should pass within the burst: %v", err)
+		}
+		if err := waitWithTimeout(limiter, 50); err == nil {
+			t.Fatalf("tokens beyond the burst should not pass within 100ms")
+		}
+	})
+
+	t.Run("Unlimited never waits", func(t *testing.T) {
+		limiter := llm.NewRateLimiter(llm.RateLimits{})
+		for i := 0; i < 1000; i++ {
+			if err := waitWithTimeout(limiter, 100000); err != nil {
+				t.Fatalf("unlimited limiter should not wait: %v", err)
+			}
+		}
+	})
+
+	t.Run("Pause blocks until the context is done", func(t *testing.T) {
+		limiter := llm.NewRateLimiter(llm.RateLimits{})
+		limiter.Pause(time.Hour)
+
+		if err := waitWithTimeout(limiter, 0); err != context.DeadlineExceeded {
+			t.Fatalf("expected context.DeadlineExceeded, got: %v", err)
+		}
+	})
+
+	t.Run("Update adopts the reported limits", func(t *testing.T) {
+		limiter := llm.NewRateLimiter(llm.RateLimits{RequestsPerMinute: 60, TokensPerMinute: 1000})
+		limiter.Update(&llm.LlmRateLimit{LimitRequests: 500, RemainingRequests: 499, LimitTokens: 30000, RemainingTokens: 29000})
+
+		limits := limiter.Limits()
+		if limits.RequestsPerMinute != 500 || limits.TokensPerMinute != 30000 {
+			t.Fatalf("expected the reported limits, got %+v", limits)
+		}
+	})
+
+	t.Run("Update pauses when nothing remains", func(t *testing.T) {
+		limiter := llm.NewRateLimiter(llm.RateLimits{})
+		limiter.Update(&llm.LlmRateLimit{LimitTokens: 30000, RemainingTokens: 0, HasRemainingTokens: true, ResetTokens: time.Minute})
+
+		if err := waitWithTimeout(limiter, 0); err != context.DeadlineExceeded {
+			t.Fatalf("expected context.DeadlineExceeded, got: %v", err)
+		}
+	})
+
+	t.Run("Missing remaining header does not pause", func(t *testing.T) {
+		header := http.Header{}
+		header.Set("x-ratelimit-limit-requests", "60")
+		header.Set("x-ratelimit-reset-requests", "1m0s")
+		rateLimit := llm.ParseRateLimitHeaders(header)
+		if rateLimit == nil || rateLimit.LimitRequests != 60 || rateLimit.HasRemainingRequests {
+			t.Fatalf("unexpected rate limit: %+v", rateLimit)
+		}
+
+		limiter := llm.NewRateLimiter(llm.RateLimits{RequestsPerMinute: 60})
+		limiter.Update(rateLimit)
+		if err := waitWithTimeout(limiter, 0); err != nil {
+			t.Fatalf("expected no pause, got: %v", err)
+		}
+
+		header.Set("x-ratelimit-remaining-requests", "0")
+		limiter.Update(llm.ParseRateLimitHeaders(header))
+		if err := waitWithTimeout(limiter, 0); err != context.DeadlineExceeded {
+			t.Fatalf("expected context.DeadlineExceeded, got: %v", err)
+		}
+	})
+}
+
+func TestRateLimitedClient(t *testing.T) {
+	messages := []llm.LlmMessage{{Role: llm.RoleUser, Content: "hi"}}
+
+	t.Run("Reported rate limits are fed to the limiter", func(t *testing.T) {
+		limiter := llm.NewRateLimiter(llm.RateLimits{})
+		client := llm.NewRateLimitedClient(&fakeClient{
+			reply: fixedReply(llm.LlmReply{Content: "ok", RateLimit: &llm.LlmRateLimit{LimitRequests: 100, RemainingRequests: 99}}),
+		}, limiter)
+
+		if _, err := client.ReplyMessage(context.Background(), messages); err != nil {
+			t.Fatalf("ReplyMessage failed: %v", err)
+		}
+		if limiter.Limits().RequestsPerMinute != 100 {
+			t.Fatalf("expected 100 requests per minute, got %+v", limiter.Limits())
+		}
+	})
+
+	t.Run("429 pauses the following calls", func(t *testing.T) {
+		limiter := llm.NewRateLimiter(llm.RateLimits{})
+		client := llm.NewRateLimitedClient(&fakeClient{
+			errs: []error{&llm.ApiError{StatusCode: http.StatusTooManyRequests, Message: "slow down", RetryAfter: time.Minute}},
+		}, limiter)
+
+		if _, err := client.ReplyMessage(context.Background(), messages); llm.HttpStatusCode(err) != http.StatusTooManyRequests {
+			t.Fatalf("expected the 429 error, got: %v", err)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		if _, err := client.ReplyMessage(ctx, messages); err != context.DeadlineExceeded {
+			t.Fatalf("expected the paused call to time out, got: %v", err)
+		}
+	})
+
+	t.Run("Key limiters are shared per provider and key", func(t *testing.T) {
+		first := llm.KeyRateLimiter(llm.ProviderClaude, "sk-rate-test")
+		if first != llm.KeyRateLimiter(llm.ProviderClaude, "sk-rate-test") {
+			t.Fatalf("same provider and key should share the limiter")
+		}
+		if first == llm.KeyRateLimiter(llm.ProviderChatGpt, "sk-rate-test") {
+			t.Fatalf("different providers should not share the limiter")
+		}
+		if first.Limits() != llm.DefaultRateLimits[llm.ProviderClaude] {
+			t.Fatalf("expected the claude default limits, got %+v", first.Limits())
+		}
+	})
+}
@@ -0,0 +1,289 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sieglu2/go_foundation/foundation"
+)
+
+// DefaultMaxDecisions is how many recent decisions a RouterClient keeps.
+const DefaultMaxDecisions = 100
+
+// DefaultClassifierPrompt asks the classifier to grade the prompt it is given after it.
+const DefaultClassifierPrompt = "Classify how hard it is to answer the following request well. " +
+	"Reply with exactly one word, easy or hard, and nothing else."
+
+var ErrNoRoute = errors.New("no route")
+
+// RouteRule picks the route of a call. Route returns "" to leave the call to the next
+// rule; an error fails the call.
+type RouteRule struct {
+	Name  string
+	Route func(ctx context.Context, messages []LlmMessage) (string, error)
+}
+
+// RouteDecision records how a call was routed and how it went.
+type RouteDecision struct {
+	Route string `json:"route"`
+	// Rule is the name of the rule picking the route, empty for the default route.
+	Rule     string        `json:"rule,omitempty"`
+	Model    string        `json:"model,omitempty"`
+	Usage    LlmUsage      `json:"usage"`
+	Duration time.Duration `json:"duration"`
+	Error    string        `json:"error,omitempty"`
+	Time     time.Time     `json:"time"`
+}
+
+type RouterConfig struct {
+	// Routes are the clients by route name, e.g. "cheap" and "strong".
+	Routes map[string]LlmClient
+	// Rules are tried in order, the first one picking a route wins.
+	Rules []RouteRule
+	// Default is the route of the calls no rule picked.
+	Default string
+	// MaxDecisions is how many recent decisions are kept, DefaultMaxDecisions if 0.
+	MaxDecisions int
+	// OnDecision is called with every decision once the call is done.
+	OnDecision func(decision RouteDecision)
+}
+
+// RouterClient is an LlmClient sending every call to the client of the route picked by
+// its rules, so call sites keep a single client while easy prompts go to cheap models.
+type RouterClient struct {
+	config RouterConfig
+
+	mu        sync.Mutex
+	decisions []RouteDecision
+	counts    map[string]int
+}
+
+func NewRouterClient(config RouterConfig) (*RouterClient, error) {
+	logger := foundation.Logger()
+
+	if _, ok := config.Routes[config.Default]; !ok {
+		logger.Errorf("default route %q is not among the routes", config.Default)
+		return nil, fmt.Errorf("%w: default route %q is not among the routes", ErrNoRoute, config.Default)
+	}
+	if config.MaxDecisions <= 0 {
+		config.MaxDecisions = DefaultMaxDecisions
+	}
+	return &RouterClient{
+		config: config,
+		counts: map[string]int{},
+	}, nil
+}
+
+func (r *RouterClient) ReplyMessage(ctx context.Context, messages []LlmMessage) (string, error) {
+	reply, err := r.ReplyMessageDetail(ctx, messages)
+	if err != nil {
+		return "", err
+	}
+	return reply.Content, nil
+}
+
+func (r *RouterClient) ReplyMessageDetail(ctx context.Context, messages []LlmMessage) (*LlmReply, error) {
+	logger := foundation.Logger()
+
+	route, rule, err := r.route(ctx, messages)
+	if err != nil {
+		return nil, err
+	}
+	logger.Infof("routing llm call to %s by rule %q", route, rule)
+
+	start := time.Now()
+	reply, err := ReplyMessageDetail(ctx, r.config.Routes[route], messages)
+
+	decision := RouteDecision{
+		Route:    route,
+		Rule:     rule,
+		Duration: time.Since(start),
+		Time:     start,
+	}
+	if err != nil {
+		decision.Error = err.Error()
+	} else {
+		decision.Model = reply.Model
+		decision.Usage = reply.Usage
+	}
+	r.record(decision)
+
+	return reply, err
+}
+
+// Close closes the clients of every route. Clients used by rules, like a classifier, are
+// the caller's to close.
+func (r *RouterClient) Close() error {
+	var errs []error
+	for _, client := range r.config.Routes {
+		if err := client.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Decisions returns the recent decisions, oldest first.
+func (r *RouterClient) Decisions() []RouteDecision {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]RouteDecision(nil), r.decisions...)
+}
+
+// RouteCounts returns the number of calls sent to every route since the client was
+// created.
+func (r *RouterClient) RouteCounts() map[string]int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	counts := make(map[string]int, len(r.counts))
+	for route, count := range r.counts {
+		counts[route] = count
+	}
+	return counts
+}
+
+func (r *RouterClient) route(ctx context.Context, messages []LlmMessage) (string, string, error) {
+	logger := foundation.Logger()
+
+	for _, rule := range r.config.Rules {
+		route, err := rule.Route(ctx, messages)
+		if err != nil {
+			logger.Errorf("failed to route by rule %s: %v", rule.Name, err)
+			return "", "", fmt.Errorf("failed to route by rule %s: %w", rule.Name, err)
+		}
+		if route == "" {
+			continue
+		}
+		if _, ok := r.config.Routes[route]; !ok {
+			logger.Errorf("rule %s picked unknown route %q", rule.Name, route)
+			return "", "", fmt.Errorf("%w: rule %s picked unknown route %q", ErrNoRoute, rule.Name, route)
+		}
+		return route, rule.Name, nil
+	}
+	return r.config.Default, "", nil
+}
+
+func (r *RouterClient) record(decision RouteDecision) {
+	r.mu.Lock()
+	r.counts[decision.Route]++
+	r.decisions = append(r.decisions, decision)
+	if len(r.decisions) > r.config.MaxDecisions {
+		r.decisions = r.decisions[len(r.decisions)-r.config.MaxDecisions:]
+	}
+	r.mu.Unlock()
+
+	if r.config.OnDecision != nil {
+		r.config.OnDecision(decision)
+	}
+}
+
+// PromptTokensRule routes the calls whose messages are estimated at minTokens or more.
+func PromptTokensRule(minTokens int, route string) RouteRule {
+	return RouteRule{
+		Name: fmt.Sprintf("prompt_tokens>=%d", minTokens),
+		Route: func(ctx context.Context, messages []LlmMessage) (string, error) {
+			if EstimateTokens(messages) >= minTokens {
+				return route, nil
+			}
+			return "", nil
+		},
+	}
+}
+
+// ImageRule routes the calls having an image.
+func ImageRule(route string) RouteRule {
+	return RouteRule{
+		Name: "image",
+		Route: func(ctx context.Context, messages []LlmMessage) (string, error) {
+			for _, message := range messages {
+				if message.B64Image != "" {
+					return route, nil
+				}
+			}
+			return "", nil
+		},
+	}
+}
+
+type routeTagsContextKey struct{}
+
+// WithRouteTags returns a context whose calls carry tags for TagRule, e.g. "legal" for the
+// calls of a feature needing the strong model. Tags of enclosing contexts still apply.
+func WithRouteTags(ctx context.Context, tags ...string) context.Context {
+	return context.WithValue(ctx, routeTagsContextKey{}, append(RouteTags(ctx), tags...))
+}
+
+// RouteTags returns the tags set on the context with WithRouteTags.
+func RouteTags(ctx context.Context) []string {
+	tags, _ := ctx.Value(routeTagsContextKey{}).([]string)
+	// copy so sibling contexts never share the backing array
+	return append([]string(nil), tags...)
+}
+
+// TagRule routes the calls whose context carries tag.
+func TagRule(tag string, route string) RouteRule {
+	return RouteRule{
+		Name: "tag:" + tag,
+		Route: func(ctx context.Context, messages []LlmMessage) (string, error) {
+			for _, t := range RouteTags(ctx) {
+				if t == tag {
+					return route, nil
+				}
+			}
+			return "", nil
+		},
+	}
+}
+
+// ClassifierRule asks classifier, usually a cheap model, to grade the last user message
+// with prompt, DefaultClassifierPrompt if empty, and routes by its answer through routes,
+// e.g. {"easy": "cheap", "hard": "strong"}. An answer not in routes, or a failed call,
+// leaves the call to the next rule, so the classifier never makes a call fail.
+func ClassifierRule(classifier LlmClient, prompt string, routes map[string]string) RouteRule {
+	if prompt == "" {
+		prompt = DefaultClassifierPrompt
+	}
+
+	return RouteRule{
+		Name: "classifier",
+		Route: func(ctx context.Context, messages []LlmMessage) (string, error) {
+			logger := foundation.Logger()
+
+			request := lastUserContent(messages)
+			if request == "" {
+				return "", nil
+			}
+
+			answer, err := classifier.ReplyMessage(ctx, []LlmMessage{
+				{Role: RoleSystem, Content: prompt},
+				{Role: RoleUser, Content: request},
+			})
+			if err != nil {
+				logger.Warnf("failed to classify the call, leaving it to the next rule: %v", err)
+				return "", nil
+			}
+
+			label := strings.ToLower(strings.Trim(strings.TrimSpace(answer), ".!\"'"))
+			route, ok := routes[label]
+			if !ok {
+				logger.Warnf("classifier answered %q, leaving the call to the next rule", answer)
+			}
+			return route, nil
+		},
+	}
+}
+
+func lastUserContent(messages []LlmMessage) string {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == RoleUser {
+			return messages[i].Content
+		}
+	}
+	return ""
+}
@@ -0,0 +1,82 @@
+package llm_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/sieglu2/go_foundation/llm"
+)
+
+func TestRouterClient(t *testing.T) {
+	ctx := context.Background()
+
+	newRouter := func(t *testing.T, rules ...llm.RouteRule) (*llm.RouterClient, *fakeClient, *fakeClient) {
+		cheap, strong := &fakeClient{}, &fakeClient{}
+		router, err := llm.NewRouterClient(llm.RouterConfig{
+			Routes:       map[string]llm.LlmClient{"cheap": cheap, "strong": strong},
+			Rules:        rules,
+			Default:      "cheap",
+			MaxDecisions: 2,
+		})
+		if err != nil {
+			t.Fatalf("failed to NewRouterClient: %v", err)
+		}
+		return router, cheap, strong
+	}
+	user := func(content string) []llm.LlmMessage {
+		return []llm.LlmMessage{{Role: llm.RoleUser, Content: content}}
+	}
+
+	t.Run("Rules in order", func(t *testing.T) {
+		router, cheap, strong := newRouter(t,
+			llm.ImageRule("strong"),
+			llm.PromptTokensRule(100, "strong"),
+			llm.TagRule("legal", "strong"),
+		)
+
+		router.ReplyMessage(ctx, user("hi"))
+		router.ReplyMessage(ctx, user(strings.Repeat("long ", 100)))
+		router.ReplyMessage(ctx, []llm.LlmMessage{{Role: llm.RoleUser, Content: "what is it?", B64Image: "aGk="}})
+		router.ReplyMessage(llm.WithRouteTags(ctx, "legal"), user("hi"))
+		if cheap.Calls() != 1 || strong.Calls() != 3 {
+			t.Fatalf("expected 1 cheap and 3 strong calls, got %d and %d", cheap.Calls(), strong.Calls())
+		}
+
+		counts := router.RouteCounts()
+		if counts["cheap"] != 1 || counts["strong"] != 3 {
+			t.Fatalf("unexpected counts: %v", counts)
+		}
+		decisions := router.Decisions()
+		if len(decisions) != 2 || decisions[0].Rule != "image" || decisions[1].Rule != "tag:legal" || decisions[1].Usage.TotalTokens != 15 {
+			t.Fatalf("expected the last 2 decisions, got %+v", decisions)
+		}
+	})
+
+	t.Run("Classifier", func(t *testing.T) {
+		// the scripted client echoes the request, so it classifies "hard" as hard
+		classifier := &fakeClient{}
+		router, cheap, strong := newRouter(t, llm.ClassifierRule(classifier, "", map[string]string{"easy": "cheap", "hard": "strong"}))
+
+		router.ReplyMessage(ctx, user("Hard"))
+		router.ReplyMessage(ctx, user("anything else"))
+		if strong.Calls() != 1 || cheap.Calls() != 1 || classifier.Calls() != 2 {
+			t.Fatalf("expected 1 strong and 1 cheap call, got %d and %d", strong.Calls(), cheap.Calls())
+		}
+		if classifier.Received()[0].Content != llm.DefaultClassifierPrompt {
+			t.Fatalf("expected the default prompt, got %q", classifier.Received()[0].Content)
+		}
+	})
+
+	t.Run("Unknown routes", func(t *testing.T) {
+		if _, err := llm.NewRouterClient(llm.RouterConfig{Default: "missing"}); !errors.Is(err, llm.ErrNoRoute) {
+			t.Fatalf("expected ErrNoRoute, got %v", err)
+		}
+
+		router, _, _ := newRouter(t, llm.TagRule("x", "missing"))
+		if _, err := router.ReplyMessage(llm.WithRouteTags(ctx, "x"), user("hi")); !errors.Is(err, llm.ErrNoRoute) {
+			t.Fatalf("expected ErrNoRoute, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,131 @@
+// providergen generates the Go declarations of the providers in provider.json: the provider
+// list of package secret_key and the provider name and secret name constants of package llm.
+//
+// It is run by go generate in package secret_key:
+//
+//	go run ./internal/providergen -in provider.json -out providers_gen.go -llm-out ../providers_gen.go
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+type provider struct {
+	Name        string `json:"-"`
+	AccountName string `json:"account_name"`
+	ServiceName string `json:"service_name"`
+	// GoName is the exported form of Name, e.g. ChatGpt; Name with a capital first letter
+	// when empty.
+	GoName string `json:"go_name,omitempty"`
+}
+
+var providerNameRegex = regexp.MustCompile(`^[a-z][a-z0-9]*$`)
+
+const header = "// Code generated by providergen from provider.json. DO NOT EDIT.\n\n"
+
+func main() {
+	in := flag.String("in", "provider.json", "provider definitions")
+	out := flag.String("out", "providers_gen.go", "generated file of package secret_key")
+	llmOut := flag.String("llm-out", "../providers_gen.go", "generated file of package llm")
+	flag.Parse()
+
+	data, err := os.ReadFile(*in)
+	if err != nil {
+		log.Fatalf("failed to read %s: %v", *in, err)
+	}
+
+	providers, err := parseProviders(data)
+	if err != nil {
+		log.Fatalf("failed to parse %s: %v", *in, err)
+	}
+
+	secretKeySource, err := renderSecretKey(providers)
+	if err != nil {
+		log.Fatalf("failed to render %s: %v", *out, err)
+	}
+	llmSource, err := renderLlm(providers)
+	if err != nil {
+		log.Fatalf("failed to render %s: %v", *llmOut, err)
+	}
+
+	if err := os.WriteFile(*out, secretKeySource, 0644); err != nil {
+		log.Fatalf("failed to write %s: %v", *out, err)
+	}
+	if err := os.WriteFile(*llmOut, llmSource, 0644); err != nil {
+		log.Fatalf("failed to write %s: %v", *llmOut, err)
+	}
+}
+
+func parseProviders(data []byte) ([]provider, error) {
+	var byName map[string]provider
+	if err := json.Unmarshal(data, &byName); err != nil {
+		return nil, err
+	}
+
+	providers := make([]provider, 0, len(byName))
+	for name, p := range byName {
+		if !providerNameRegex.MatchString(name) {
+			return nil, fmt.Errorf("provider name %q must be lowercase alphanumeric", name)
+		}
+		if p.AccountName == "" || p.ServiceName == "" {
+			return nil, fmt.Errorf("provider %s needs both account_name and service_name", name)
+		}
+
+		p.Name = name
+		if p.GoName == "" {
+			p.GoName = strings.ToUpper(name[:1]) + name[1:]
+		}
+		providers = append(providers, p)
+	}
+
+	sort.Slice(providers, func(i, j int) bool {
+		return providers[i].Name < providers[j].Name
+	})
+	return providers, nil
+}
+
+func renderSecretKey(providers []provider) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(header)
+	buf.WriteString("package secret_key\n\n")
+	buf.WriteString("var providers = []Provider{\n")
+	for _, p := range providers {
+		fmt.Fprintf(&buf, "{Name: %q, AccountName: %q, ServiceName: %q},\n", p.Name, p.AccountName, p.ServiceName)
+	}
+	buf.WriteString("}\n")
+
+	return format.Source(buf.Bytes())
+}
+
+func renderLlm(providers []provider) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(header)
+	buf.WriteString("package llm\n\n")
+
+	buf.WriteString("const (\n")
+	for _, p := range providers {
+		fmt.Fprintf(&buf, "Provider%s = %q\n", p.GoName, p.Name)
+	}
+	buf.WriteString(")\n\n")
+
+	buf.WriteString("const (\n")
+	for i, p := range providers {
+		if i > 0 {
+			buf.WriteString("\n")
+		}
+		fmt.Fprintf(&buf, "%sSecretAccountName string = %q\n", p.Name, p.AccountName)
+		fmt.Fprintf(&buf, "%sSecretServiceName string = %q\n", p.Name, p.ServiceName)
+	}
+	buf.WriteString(")\n")
+
+	return format.Source(buf.Bytes())
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+// TestGeneratedFilesAreUpToDate fails when provider.json and the generated files drift apart,
+// run `go generate ./llm/secret_key` to fix it.
+func TestGeneratedFilesAreUpToDate(t *testing.T) {
+	data, err := os.ReadFile("../../provider.json")
+	if err != nil {
+		t.Fatalf("failed to read provider.json: %v", err)
+	}
+
+	providers, err := parseProviders(data)
+	if err != nil {
+		t.Fatalf("failed to parse provider.json: %v", err)
+	}
+
+	for path, render := range map[string]func([]provider) ([]byte, error){
+		"../../providers_gen.go":    renderSecretKey,
+		"../../../providers_gen.go": renderLlm,
+	} {
+		expected, err := render(providers)
+		if err != nil {
+			t.Fatalf("failed to render %s: %v", path, err)
+		}
+
+		actual, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", path, err)
+		}
+
+		if !bytes.Equal(expected, actual) {
+			t.Errorf("%s is out of date with provider.json, run go generate ./llm/secret_key", path)
+		}
+	}
+}
+
+func TestParseProviders(t *testing.T) {
+	t.Run("Go name defaults to the capitalized name", func(t *testing.T) {
+		providers, err := parseProviders([]byte(`{
+			"claude": {"account_name": "my_anthropic", "service_name": "claude"},
+			"chatgpt": {"account_name": "my_openai", "service_name": "chatgpt", "go_name": "ChatGpt"}
+		}`))
+		if err != nil {
+			t.Fatalf("parseProviders failed: %v", err)
+		}
+		if len(providers) != 2 || providers[0].GoName != "ChatGpt" || providers[1].GoName != "Claude" {
+			t.Fatalf("unexpected providers: %+v", providers)
+		}
+	})
+
+	t.Run("Missing secret names are rejected", func(t *testing.T) {
+		if _, err := parseProviders([]byte(`{"claude": {"account_name": "my_anthropic"}}`)); err == nil {
+			t.Fatalf("parseProviders should fail without service_name")
+		}
+	})
+
+	t.Run("Names that are not identifiers are rejected", func(t *testing.T) {
+		if _, err := parseProviders([]byte(`{"open-ai": {"account_name": "a", "service_name": "s"}}`)); err == nil {
+			t.Fatalf("parseProviders should fail for open-ai")
+		}
+	})
+}
@@ -0,0 +1,49 @@
+package secret_key
+
+import (
+	"strings"
+)
+
+// provider.json is the single definition of the providers: the provider list below, the
+// secret name constants of package llm and the key tooling are all generated from it.
+//go:generate go run ./internal/providergen -in provider.json -out providers_gen.go -llm-out ../providers_gen.go
+
+type Provider struct {
+	Name        string
+	AccountName string
+	ServiceName string
+}
+
+// Providers returns the providers declared in provider.json, sorted by name.
+func Providers() []Provider {
+	return append([]Provider(nil), providers...)
+}
+
+func LookupProvider(name string) (Provider, bool) {
+	for _, provider := range providers {
+		if provider.Name == name {
+			return provider, true
+		}
+	}
+	return Provider{}, false
+}
+
+// lookupProviderBySecret finds the provider of an account/service pair.
+func lookupProviderBySecret(accountName, serviceName string) (Provider, bool) {
+	for _, provider := range providers {
+		if provider.AccountName == accountName && provider.ServiceName == serviceName {
+			return provider, true
+		}
+	}
+	return Provider{}, false
+}
+
+// providerEnvVarName returns the environment variable holding the key of an account/service
+// pair, e.g. CLAUDE_API_KEY. Unknown pairs fall back to the service name.
+func providerEnvVarName(accountName, serviceName string) string {
+	name := serviceName
+	if provider, ok := lookupProviderBySecret(accountName, serviceName); ok {
+		name = provider.Name
+	}
+	return strings.ToUpper(name) + "_API_KEY"
+}
@@ -0,0 +1,11 @@
+// Code generated by providergen from provider.json. DO NOT EDIT.
+
+package secret_key
+
+var providers = []Provider{
+	{Name: "chatgpt", AccountName: "my_openai", ServiceName: "chatgpt"},
+	{Name: "claude", AccountName: "my_anthropic", ServiceName: "claude"},
+	{Name: "deepseek", AccountName: "my_deepseek", ServiceName: "deepseek"},
+	{Name: "gemini", AccountName: "my_google", ServiceName: "gemini"},
+	{Name: "minimax", AccountName: "my_hailuoai", ServiceName: "minimax"},
+}
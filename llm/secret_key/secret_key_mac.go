@@ -11,7 +11,27 @@ import (
 	"github.com/sieglu2/go_foundation/foundation"
 )
 
-func GetSecretKey(ctx context.Context, accountName, serviceName string) (string, error) {
+func defaultProviders() []SecretProvider {
+	return []SecretProvider{
+		NewEnvProvider(),
+		defaultVaultProvider(),
+		defaultFileProvider(),
+		NewKeychainProvider(),
+	}
+}
+
+// KeychainProvider reads generic passwords from the macOS keychain.
+type KeychainProvider struct{}
+
+func NewKeychainProvider() *KeychainProvider {
+	return &KeychainProvider{}
+}
+
+func (k *KeychainProvider) Name() string {
+	return "keychain"
+}
+
+func (k *KeychainProvider) GetSecretKey(ctx context.Context, accountName, serviceName string) (string, error) {
 	logger := foundation.Logger()
 
 	cmd := exec.CommandContext(ctx, "security", "find-generic-password",
@@ -26,3 +46,27 @@ func GetSecretKey(ctx context.Context, accountName, serviceName string) (string,
 
 	return strings.TrimSpace(string(output)), nil
 }
+
+func StoreSecretKey(ctx context.Context, accountName, serviceName, key string) error {
+	cmd := exec.CommandContext(ctx, "security", "add-generic-password", "-U",
+		"-a", accountName, "-s", serviceName, "-w", key)
+
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to store secret for %s/%s: %v, %s",
+			accountName, serviceName, err, strings.TrimSpace(string(output)))
+	}
+
+	return nil
+}
+
+func DeleteSecretKey(ctx context.Context, accountName, serviceName string) error {
+	cmd := exec.CommandContext(ctx, "security", "delete-generic-password",
+		"-a", accountName, "-s", serviceName)
+
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to delete secret for %s/%s: %v, %s",
+			accountName, serviceName, err, strings.TrimSpace(string(output)))
+	}
+
+	return nil
+}
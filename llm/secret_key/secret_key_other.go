@@ -4,10 +4,42 @@ package secret_key
 
 import (
 	"context"
-	"fmt"
-	"runtime"
 )
 
-func GetSecretKey(ctx context.Context, accountName, serviceName string) (string, error) {
-	return "", fmt.Errorf("unsupported operating system: %s", runtime.GOOS)
+// The key is looked up in the environment variable of the provider, then the vault, the
+// secrets file, the Secret Service (secret-tool) and finally pass.
+func defaultProviders() []SecretProvider {
+	return []SecretProvider{
+		NewEnvProvider(),
+		defaultVaultProvider(),
+		defaultFileProvider(),
+		NewKeychainProvider(),
+		NewCommandProvider("pass", "show", "go_foundation/${service}/${account}"),
+	}
+}
+
+// KeychainProvider reads secrets from the Secret Service (gnome-keyring, kwallet) through
+// secret-tool, e.g. stored with
+//
+//	secret-tool store --label=claude service claude account my_anthropic
+type KeychainProvider struct{}
+
+func NewKeychainProvider() *KeychainProvider {
+	return &KeychainProvider{}
+}
+
+func (k *KeychainProvider) Name() string {
+	return "secret-tool"
+}
+
+func (k *KeychainProvider) GetSecretKey(ctx context.Context, accountName, serviceName string) (string, error) {
+	return runSecretCommand(ctx, "secret-tool", "lookup", "service", serviceName, "account", accountName)
+}
+
+func StoreSecretKey(ctx context.Context, accountName, serviceName, key string) error {
+	return defaultFileProvider().StoreSecretKey(accountName, serviceName, key)
+}
+
+func DeleteSecretKey(ctx context.Context, accountName, serviceName string) error {
+	return defaultFileProvider().DeleteSecretKey(accountName, serviceName)
 }
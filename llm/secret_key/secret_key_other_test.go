@@ -0,0 +1,104 @@
+//go:build !darwin && !windows
+
+package secret_key_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sieglu2/go_foundation/llm/secret_key"
+)
+
+// isolate points the config dir to a temp dir and hides the secret helpers.
+func isolate(t *testing.T) string {
+	configDir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configDir)
+	t.Setenv("PATH", "")
+	t.Setenv("CLAUDE_API_KEY", "")
+	t.Setenv(secret_key.VaultPassphraseEnv, "")
+	return filepath.Join(configDir, "go_foundation", "secrets.json")
+}
+
+func TestGetSecretKey(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Store then get from file", func(t *testing.T) {
+		isolate(t)
+
+		if err := secret_key.StoreSecretKey(ctx, "my_anthropic", "claude", "file-key"); err != nil {
+			t.Fatalf("StoreSecretKey failed: %v", err)
+		}
+
+		key, err := secret_key.GetSecretKey(ctx, "my_anthropic", "claude")
+		if err != nil {
+			t.Fatalf("GetSecretKey failed: %v", err)
+		}
+		if key != "file-key" {
+			t.Fatalf("expected file-key, got %s", key)
+		}
+	})
+
+	t.Run("Environment variable wins over file", func(t *testing.T) {
+		isolate(t)
+
+		if err := secret_key.StoreSecretKey(ctx, "my_anthropic", "claude", "file-key"); err != nil {
+			t.Fatalf("StoreSecretKey failed: %v", err)
+		}
+		t.Setenv("CLAUDE_API_KEY", "env-key")
+
+		key, err := secret_key.GetSecretKey(ctx, "my_anthropic", "claude")
+		if err != nil {
+			t.Fatalf("GetSecretKey failed: %v", err)
+		}
+		if key != "env-key" {
+			t.Fatalf("expected env-key, got %s", key)
+		}
+	})
+
+	t.Run("File readable by others is rejected", func(t *testing.T) {
+		path := isolate(t)
+
+		if err := secret_key.StoreSecretKey(ctx, "my_anthropic", "claude", "file-key"); err != nil {
+			t.Fatalf("StoreSecretKey failed: %v", err)
+		}
+		if err := os.Chmod(path, 0644); err != nil {
+			t.Fatalf("Chmod failed: %v", err)
+		}
+
+		_, err := secret_key.GetSecretKey(ctx, "my_anthropic", "claude")
+		if err == nil || !strings.Contains(err.Error(), "expected 0600") {
+			t.Fatalf("expected permission error, got: %v", err)
+		}
+	})
+
+	t.Run("Error lists every source tried", func(t *testing.T) {
+		isolate(t)
+
+		_, err := secret_key.GetSecretKey(ctx, "my_anthropic", "claude")
+		if err == nil {
+			t.Fatalf("GetSecretKey should fail without any secret")
+		}
+		for _, source := range []string{"env: CLAUDE_API_KEY", "vault:", "file:", "secret-tool:", "pass:"} {
+			if !strings.Contains(err.Error(), source) {
+				t.Errorf("error should mention %q, got: %v", source, err)
+			}
+		}
+	})
+
+	t.Run("Delete removes the entry", func(t *testing.T) {
+		isolate(t)
+
+		if err := secret_key.StoreSecretKey(ctx, "my_anthropic", "claude", "file-key"); err != nil {
+			t.Fatalf("StoreSecretKey failed: %v", err)
+		}
+		if err := secret_key.DeleteSecretKey(ctx, "my_anthropic", "claude"); err != nil {
+			t.Fatalf("DeleteSecretKey failed: %v", err)
+		}
+		if _, err := secret_key.GetSecretKey(ctx, "my_anthropic", "claude"); err == nil {
+			t.Fatalf("GetSecretKey should fail after delete")
+		}
+	})
+}
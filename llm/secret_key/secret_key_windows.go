@@ -6,48 +6,87 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"os/exec"
 	"strings"
 )
 
-func GetSecretKey(ctx context.Context, accountName, serviceName string) (string, error) {
-	// Define known account/service pairs
-	knownPairs := map[string]struct {
-		account string
-		service string
-	}{
-		"chatgpt": {
-			account: "my_openai",
-			service: "chatgpt",
-		},
-		"claude": {
-			account: "my_anthropic",
-			service: "claude",
-		},
-		"deepseek": {
-			account: "my_deepseek",
-			service: "deepseek",
-		},
-	}
-
-	// Find which provider this account/service pair belongs to
-	var provider string
-	for p, pair := range knownPairs {
-		if pair.account == accountName && pair.service == serviceName {
-			provider = p
-			break
+func defaultProviders() []SecretProvider {
+	return []SecretProvider{
+		NewEnvProvider(),
+		defaultVaultProvider(),
+		defaultFileProvider(),
+		NewKeychainProvider(),
+	}
+}
+
+// KeychainProvider reads the user environment variables persisted in the registry by
+// StoreSecretKey, so keys stored after the process started are visible as well.
+type KeychainProvider struct{}
+
+func NewKeychainProvider() *KeychainProvider {
+	return &KeychainProvider{}
+}
+
+func (k *KeychainProvider) Name() string {
+	return "registry"
+}
+
+func (k *KeychainProvider) GetSecretKey(ctx context.Context, accountName, serviceName string) (string, error) {
+	envVarName, err := getEnvVarName(accountName, serviceName)
+	if err != nil {
+		return "", err
+	}
+
+	cmd := exec.CommandContext(ctx, "reg", "query", `HKCU\Environment`, "/v", envVarName)
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("no API key found in registry value %s", envVarName)
+	}
+
+	// the value line looks like: "    CLAUDE_API_KEY    REG_SZ    sk-..."
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 3 && strings.EqualFold(fields[0], envVarName) {
+			return strings.Join(fields[2:], " "), nil
 		}
 	}
 
-	if provider == "" {
-		return "", fmt.Errorf("unknown account/service pair: %s/%s", accountName, serviceName)
+	return "", fmt.Errorf("failed to parse registry value %s", envVarName)
+}
+
+func StoreSecretKey(ctx context.Context, accountName, serviceName, key string) error {
+	envVarName, err := getEnvVarName(accountName, serviceName)
+	if err != nil {
+		return err
+	}
+
+	// setx persists the variable for the current user, new processes will see it.
+	cmd := exec.CommandContext(ctx, "setx", envVarName, key)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to setx %s: %v, %s", envVarName, err, strings.TrimSpace(string(output)))
 	}
 
-	// Get the environment variable
-	envVarName := strings.ToUpper(provider) + "_API_KEY"
-	key := os.Getenv(envVarName)
-	if key == "" {
-		return "", fmt.Errorf("no API key found in environment variable %s", envVarName)
+	return os.Setenv(envVarName, key)
+}
+
+func DeleteSecretKey(ctx context.Context, accountName, serviceName string) error {
+	envVarName, err := getEnvVarName(accountName, serviceName)
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.CommandContext(ctx, "reg", "delete", `HKCU\Environment`, "/v", envVarName, "/f")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to delete %s: %v, %s", envVarName, err, strings.TrimSpace(string(output)))
+	}
+
+	return os.Unsetenv(envVarName)
+}
+
+func getEnvVarName(accountName, serviceName string) (string, error) {
+	if _, ok := lookupProviderBySecret(accountName, serviceName); !ok {
+		return "", fmt.Errorf("unknown account/service pair: %s/%s", accountName, serviceName)
 	}
 
-	return key, nil
+	return providerEnvVarName(accountName, serviceName), nil
 }
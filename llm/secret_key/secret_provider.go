@@ -0,0 +1,379 @@
+package secret_key
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/sieglu2/go_foundation/foundation"
+)
+
+// SecretProvider looks up the secret key of an account/service pair.
+type SecretProvider interface {
+	// Name identifies the provider in error messages.
+	Name() string
+	GetSecretKey(ctx context.Context, accountName, serviceName string) (string, error)
+}
+
+var (
+	defaultProviderOnce sync.Once
+	defaultProvider     *ChainProvider
+)
+
+// DefaultProvider returns the chain used by GetSecretKey on this operating system.
+func DefaultProvider() *ChainProvider {
+	defaultProviderOnce.Do(func() {
+		defaultProvider = NewChainProvider(defaultProviders()...)
+	})
+	return defaultProvider
+}
+
+func GetSecretKey(ctx context.Context, accountName, serviceName string) (string, error) {
+	return DefaultProvider().GetSecretKey(ctx, accountName, serviceName)
+}
+
+// MaskKey keeps only the ends of a key so it can be told apart without being leaked.
+func MaskKey(key string) string {
+	if len(key) <= 8 {
+		return strings.Repeat("*", len(key))
+	}
+	return key[:4] + strings.Repeat("*", 4) + key[len(key)-4:]
+}
+
+// SplitSecretKeys splits a secret holding several keys of the same account, separated by
+// commas or whitespace, e.g. CLAUDE_API_KEY="sk-1,sk-2". Duplicates are dropped.
+func SplitSecretKeys(secret string) []string {
+	fields := strings.FieldsFunc(secret, func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	})
+
+	keys := make([]string, 0, len(fields))
+	seen := map[string]bool{}
+	for _, key := range fields {
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// ChainProvider tries its providers in order and returns the first key found.
+type ChainProvider struct {
+	providers []SecretProvider
+}
+
+func NewChainProvider(providers ...SecretProvider) *ChainProvider {
+	return &ChainProvider{
+		providers: providers,
+	}
+}
+
+func (c *ChainProvider) Name() string {
+	names := make([]string, 0, len(c.providers))
+	for _, provider := range c.providers {
+		names = append(names, provider.Name())
+	}
+	return fmt.Sprintf("chain(%s)", strings.Join(names, ","))
+}
+
+func (c *ChainProvider) GetSecretKey(ctx context.Context, accountName, serviceName string) (string, error) {
+	tried := make([]string, 0, len(c.providers))
+	for _, provider := range c.providers {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+
+		key, err := provider.GetSecretKey(ctx, accountName, serviceName)
+		if err == nil && key != "" {
+			return key, nil
+		}
+		if err == nil {
+			err = fmt.Errorf("empty key")
+		}
+		tried = append(tried, fmt.Sprintf("%s: %v", provider.Name(), err))
+	}
+
+	return "", fmt.Errorf("no secret found for %s/%s, tried: %s",
+		accountName, serviceName, strings.Join(tried, "; "))
+}
+
+// EnvProvider reads the <PROVIDER>_API_KEY environment variable, e.g. CLAUDE_API_KEY.
+type EnvProvider struct{}
+
+func NewEnvProvider() *EnvProvider {
+	return &EnvProvider{}
+}
+
+func (e *EnvProvider) Name() string {
+	return "env"
+}
+
+func (e *EnvProvider) GetSecretKey(ctx context.Context, accountName, serviceName string) (string, error) {
+	envVarName := providerEnvVarName(accountName, serviceName)
+	key := strings.TrimSpace(os.Getenv(envVarName))
+	if key == "" {
+		return "", fmt.Errorf("%s is not set", envVarName)
+	}
+	return key, nil
+}
+
+// FileProvider reads a json file of service -> account -> key. The file must be readable by
+// its owner only.
+type FileProvider struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewFileProvider reads the given file, or DefaultSecretsFilePath if path is empty.
+func NewFileProvider(path string) *FileProvider {
+	return &FileProvider{
+		path: path,
+	}
+}
+
+// DefaultSecretsFilePath is the secrets file under the user config directory.
+func DefaultSecretsFilePath() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to UserConfigDir: %v", err)
+	}
+	return filepath.Join(configDir, "go_foundation", "secrets.json"), nil
+}
+
+func (f *FileProvider) Name() string {
+	return "file"
+}
+
+func (f *FileProvider) filePath() (string, error) {
+	if f.path != "" {
+		return f.path, nil
+	}
+	return DefaultSecretsFilePath()
+}
+
+func (f *FileProvider) GetSecretKey(ctx context.Context, accountName, serviceName string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	path, err := f.filePath()
+	if err != nil {
+		return "", err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", fmt.Errorf("%s does not exist", path)
+		}
+		return "", fmt.Errorf("failed to stat %s: %v", path, err)
+	}
+	// same rule as ssh: a secrets file others can read is not trusted
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0077 != 0 {
+		return "", fmt.Errorf("%s has permissions %04o, expected 0600 (chmod 600 %s)",
+			path, info.Mode().Perm(), path)
+	}
+
+	secrets, err := f.load(path)
+	if err != nil {
+		return "", err
+	}
+
+	key := strings.TrimSpace(secrets[serviceName][accountName])
+	if key == "" {
+		return "", fmt.Errorf("no entry for %s/%s in %s", accountName, serviceName, path)
+	}
+
+	return key, nil
+}
+
+func (f *FileProvider) StoreSecretKey(accountName, serviceName, key string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	path, err := f.filePath()
+	if err != nil {
+		return err
+	}
+
+	secrets, err := f.load(path)
+	if err != nil {
+		return err
+	}
+
+	if secrets[serviceName] == nil {
+		secrets[serviceName] = map[string]string{}
+	}
+	secrets[serviceName][accountName] = key
+
+	return f.save(path, secrets)
+}
+
+func (f *FileProvider) DeleteSecretKey(accountName, serviceName string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	path, err := f.filePath()
+	if err != nil {
+		return err
+	}
+
+	secrets, err := f.load(path)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := secrets[serviceName][accountName]; !ok {
+		return fmt.Errorf("no secret for %s/%s in %s", accountName, serviceName, path)
+	}
+	delete(secrets[serviceName], accountName)
+	if len(secrets[serviceName]) == 0 {
+		delete(secrets, serviceName)
+	}
+
+	return f.save(path, secrets)
+}
+
+func (f *FileProvider) load(path string) (map[string]map[string]string, error) {
+	secrets := map[string]map[string]string{}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return secrets, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+
+	if err := json.Unmarshal(data, &secrets); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+
+	return secrets, nil
+}
+
+func (f *FileProvider) save(path string, secrets map[string]map[string]string) error {
+	data, err := json.MarshalIndent(secrets, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal secrets: %v", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create %s: %v", filepath.Dir(path), err)
+	}
+
+	// write to a temp file first so a crash never leaves a truncated secrets file behind
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %v", tmpPath, err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to rename %s: %v", tmpPath, err)
+	}
+
+	return nil
+}
+
+// CommandProvider runs an external command and takes the first line of its output as the
+// key. ${account} and ${service} in the arguments are replaced by the pair looked up, e.g.
+//
+//	NewCommandProvider("pass", "show", "go_foundation/${service}/${account}")
+type CommandProvider struct {
+	command string
+	args    []string
+}
+
+func NewCommandProvider(command string, args ...string) *CommandProvider {
+	return &CommandProvider{
+		command: command,
+		args:    args,
+	}
+}
+
+func (c *CommandProvider) Name() string {
+	return c.command
+}
+
+func (c *CommandProvider) GetSecretKey(ctx context.Context, accountName, serviceName string) (string, error) {
+	replacements := map[string]string{
+		"account": accountName,
+		"service": serviceName,
+	}
+	args := make([]string, 0, len(c.args))
+	for _, arg := range c.args {
+		args = append(args, foundation.ReplaceNamedPlaceholders(arg, replacements))
+	}
+
+	return runSecretCommand(ctx, c.command, args...)
+}
+
+// runSecretCommand runs a secret helper command and returns the first line of its output.
+func runSecretCommand(ctx context.Context, name string, args ...string) (string, error) {
+	if _, err := exec.LookPath(name); err != nil {
+		return "", fmt.Errorf("not installed")
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stderr = &stderr
+
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("%v, %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	key, _, _ := strings.Cut(string(output), "\n")
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return "", fmt.Errorf("no entry found")
+	}
+
+	return key, nil
+}
+
+// StaticProvider serves keys from memory, for tests or keys fetched elsewhere at startup.
+type StaticProvider struct {
+	mu   sync.RWMutex
+	keys map[string]string
+}
+
+func NewStaticProvider() *StaticProvider {
+	return &StaticProvider{
+		keys: map[string]string{},
+	}
+}
+
+func (s *StaticProvider) Set(accountName, serviceName, key string) *StaticProvider {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.keys[accountName+"/"+serviceName] = key
+	return s
+}
+
+func (s *StaticProvider) Name() string {
+	return "static"
+}
+
+func (s *StaticProvider) GetSecretKey(ctx context.Context, accountName, serviceName string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	key, ok := s.keys[accountName+"/"+serviceName]
+	if !ok {
+		return "", fmt.Errorf("no entry for %s/%s", accountName, serviceName)
+	}
+	return key, nil
+}
+
+func defaultFileProvider() *FileProvider {
+	return NewFileProvider("")
+}
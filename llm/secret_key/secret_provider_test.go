@@ -0,0 +1,144 @@
+package secret_key_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/sieglu2/go_foundation/llm/secret_key"
+)
+
+func TestChainProvider(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("First provider with the key wins", func(t *testing.T) {
+		first := secret_key.NewStaticProvider().Set("my_anthropic", "claude", "first-key")
+		second := secret_key.NewStaticProvider().Set("my_anthropic", "claude", "second-key")
+		chain := secret_key.NewChainProvider(first, second)
+
+		key, err := chain.GetSecretKey(ctx, "my_anthropic", "claude")
+		if err != nil {
+			t.Fatalf("GetSecretKey failed: %v", err)
+		}
+		if key != "first-key" {
+			t.Fatalf("expected first-key, got %s", key)
+		}
+	})
+
+	t.Run("Falls through providers without the key", func(t *testing.T) {
+		empty := secret_key.NewStaticProvider()
+		second := secret_key.NewStaticProvider().Set("my_openai", "chatgpt", "second-key")
+		chain := secret_key.NewChainProvider(empty, second)
+
+		key, err := chain.GetSecretKey(ctx, "my_openai", "chatgpt")
+		if err != nil {
+			t.Fatalf("GetSecretKey failed: %v", err)
+		}
+		if key != "second-key" {
+			t.Fatalf("expected second-key, got %s", key)
+		}
+	})
+
+	t.Run("Error names every provider tried", func(t *testing.T) {
+		t.Setenv("GEMINI_API_KEY", "")
+		chain := secret_key.NewChainProvider(secret_key.NewEnvProvider(), secret_key.NewStaticProvider())
+
+		_, err := chain.GetSecretKey(ctx, "my_google", "gemini")
+		if err == nil {
+			t.Fatalf("GetSecretKey should fail")
+		}
+		for _, expected := range []string{"env: GEMINI_API_KEY is not set", "static: no entry"} {
+			if !strings.Contains(err.Error(), expected) {
+				t.Errorf("error should contain %q, got: %v", expected, err)
+			}
+		}
+	})
+
+	t.Run("Canceled context stops the chain", func(t *testing.T) {
+		canceledCtx, cancel := context.WithCancel(ctx)
+		cancel()
+
+		chain := secret_key.NewChainProvider(secret_key.NewStaticProvider().Set("a", "s", "key"))
+		if _, err := chain.GetSecretKey(canceledCtx, "a", "s"); err != context.Canceled {
+			t.Fatalf("expected context.Canceled, got: %v", err)
+		}
+	})
+}
+
+func TestSplitSecretKeys(t *testing.T) {
+	keys := secret_key.SplitSecretKeys(" sk-1, sk-2\nsk-3,,sk-1 ")
+	expected := []string{"sk-1", "sk-2", "sk-3"}
+	if strings.Join(keys, "|") != strings.Join(expected, "|") {
+		t.Fatalf("expected %v, got %v", expected, keys)
+	}
+}
+
+func TestEnvProvider(t *testing.T) {
+	t.Setenv("DEEPSEEK_API_KEY", " env-key ")
+
+	key, err := secret_key.NewEnvProvider().GetSecretKey(context.Background(), "my_deepseek", "deepseek")
+	if err != nil {
+		t.Fatalf("GetSecretKey failed: %v", err)
+	}
+	if key != "env-key" {
+		t.Fatalf("expected env-key, got %q", key)
+	}
+}
+
+func TestFileProvider(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "nested", "secrets.json")
+	provider := secret_key.NewFileProvider(path)
+
+	if err := provider.StoreSecretKey("my_hailuoai", "minimax", "file-key"); err != nil {
+		t.Fatalf("StoreSecretKey failed: %v", err)
+	}
+
+	key, err := provider.GetSecretKey(ctx, "my_hailuoai", "minimax")
+	if err != nil {
+		t.Fatalf("GetSecretKey failed: %v", err)
+	}
+	if key != "file-key" {
+		t.Fatalf("expected file-key, got %s", key)
+	}
+
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("Stat failed: %v", err)
+		}
+		if info.Mode().Perm() != 0600 {
+			t.Fatalf("expected permissions 0600, got %04o", info.Mode().Perm())
+		}
+	}
+
+	if err := provider.DeleteSecretKey("my_hailuoai", "minimax"); err != nil {
+		t.Fatalf("DeleteSecretKey failed: %v", err)
+	}
+	if _, err := provider.GetSecretKey(ctx, "my_hailuoai", "minimax"); err == nil {
+		t.Fatalf("GetSecretKey should fail after delete")
+	}
+}
+
+func TestCommandProvider(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("echo is a shell builtin on windows")
+	}
+
+	provider := secret_key.NewCommandProvider("echo", "${service}-${account}")
+	key, err := provider.GetSecretKey(context.Background(), "my_openai", "chatgpt")
+	if err != nil {
+		t.Fatalf("GetSecretKey failed: %v", err)
+	}
+	if key != "chatgpt-my_openai" {
+		t.Fatalf("expected chatgpt-my_openai, got %s", key)
+	}
+
+	missing := secret_key.NewCommandProvider("definitely-not-a-secret-helper")
+	if _, err := missing.GetSecretKey(context.Background(), "a", "s"); err == nil {
+		t.Fatalf("GetSecretKey should fail for a missing command")
+	}
+}
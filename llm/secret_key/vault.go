@@ -0,0 +1,373 @@
+package secret_key
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	// VaultPassphraseEnv holds the passphrase used to open the vault without a prompt.
+	VaultPassphraseEnv = "GO_FOUNDATION_VAULT_PASSPHRASE"
+
+	vaultVersion = 1
+	vaultKdf     = "scrypt"
+	vaultKeyLen  = 32
+	vaultSaltLen = 16
+)
+
+var (
+	ErrVaultExists     = errors.New("vault already exists")
+	ErrVaultPassphrase = errors.New("wrong passphrase or corrupted vault")
+)
+
+type vaultKdfParams struct {
+	N int `json:"n"`
+	R int `json:"r"`
+	P int `json:"p"`
+}
+
+var defaultVaultKdfParams = vaultKdfParams{N: 1 << 15, R: 8, P: 1}
+
+const (
+	// maxVaultKdfMemory caps the memory scrypt uses, 128*N*r bytes, 8 times the default.
+	maxVaultKdfMemory = 256 * 1024 * 1024
+	// maxVaultKdfWork caps the work scrypt does, N*r*p, 16 times the default.
+	maxVaultKdfWork = 1 << 22
+)
+
+// validate rejects parameters outside the bounds, as the header is only authenticated once
+// the key is derived: a tampered vault could otherwise exhaust cpu and memory.
+func (p vaultKdfParams) validate() error {
+	if p.N < 2 || p.N&(p.N-1) != 0 || p.R < 1 || p.P < 1 || p.R > 64 || p.P > 64 {
+		return fmt.Errorf("invalid vault kdf parameters n=%d r=%d p=%d", p.N, p.R, p.P)
+	}
+	if 128*p.N*p.R > maxVaultKdfMemory || p.N*p.R*p.P > maxVaultKdfWork {
+		return fmt.Errorf("vault kdf parameters n=%d r=%d p=%d exceed the allowed cost", p.N, p.R, p.P)
+	}
+	return nil
+}
+
+// vaultFile is the on-disk format: only the kdf parameters are in clear, the entries are
+// sealed with AES-256-GCM under a key derived from the passphrase.
+type vaultFile struct {
+	Version    int            `json:"version"`
+	Kdf        string         `json:"kdf"`
+	KdfParams  vaultKdfParams `json:"kdf_params"`
+	Salt       []byte         `json:"salt"`
+	Nonce      []byte         `json:"nonce"`
+	Ciphertext []byte         `json:"ciphertext"`
+}
+
+type VaultEntry struct {
+	AccountName string    `json:"account_name"`
+	ServiceName string    `json:"service_name"`
+	Key         string    `json:"key"`
+	CreatedAt   time.Time `json:"created_at"`
+	RotatedAt   time.Time `json:"rotated_at,omitempty"`
+}
+
+// Vault is an encrypted secrets file, decrypted in memory once opened.
+type Vault struct {
+	path       string
+	passphrase string
+	kdfParams  vaultKdfParams
+	entries    []VaultEntry
+}
+
+// DefaultVaultPath is the vault file under the user config directory.
+func DefaultVaultPath() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to UserConfigDir: %v", err)
+	}
+	return filepath.Join(configDir, "go_foundation", "vault.json"), nil
+}
+
+// CreateVault writes a new empty vault, it fails with ErrVaultExists if path exists.
+func CreateVault(path, passphrase string) (*Vault, error) {
+	if passphrase == "" {
+		return nil, fmt.Errorf("empty passphrase")
+	}
+	if _, err := os.Stat(path); err == nil {
+		return nil, fmt.Errorf("%w: %s", ErrVaultExists, path)
+	}
+
+	vault := &Vault{
+		path:       path,
+		passphrase: passphrase,
+		kdfParams:  defaultVaultKdfParams,
+	}
+	if err := vault.Save(); err != nil {
+		return nil, err
+	}
+	return vault, nil
+}
+
+func OpenVault(path, passphrase string) (*Vault, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read vault: %v", err)
+	}
+
+	var file vaultFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse vault %s: %v", path, err)
+	}
+	if file.Version != vaultVersion || file.Kdf != vaultKdf {
+		return nil, fmt.Errorf("unsupported vault version %d with kdf %s", file.Version, file.Kdf)
+	}
+	if err := file.KdfParams.validate(); err != nil {
+		return nil, err
+	}
+	if len(file.Salt) != vaultSaltLen {
+		return nil, fmt.Errorf("invalid vault salt of %d bytes", len(file.Salt))
+	}
+
+	aead, err := newVaultCipher(passphrase, file.Salt, file.KdfParams)
+	if err != nil {
+		return nil, err
+	}
+	if len(file.Nonce) != aead.NonceSize() {
+		return nil, ErrVaultPassphrase
+	}
+
+	plaintext, err := aead.Open(nil, file.Nonce, file.Ciphertext, vaultAdditionalData(file))
+	if err != nil {
+		return nil, ErrVaultPassphrase
+	}
+
+	var entries []VaultEntry
+	if err := json.Unmarshal(plaintext, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse vault entries: %v", err)
+	}
+
+	return &Vault{
+		path:       path,
+		passphrase: passphrase,
+		kdfParams:  file.KdfParams,
+		entries:    entries,
+	}, nil
+}
+
+// Save encrypts the entries with a fresh salt and nonce and replaces the vault file.
+func (v *Vault) Save() error {
+	salt := make([]byte, vaultSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return fmt.Errorf("failed to generate salt: %v", err)
+	}
+
+	aead, err := newVaultCipher(v.passphrase, salt, v.kdfParams)
+	if err != nil {
+		return err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("failed to generate nonce: %v", err)
+	}
+
+	entries := v.entries
+	if entries == nil {
+		entries = []VaultEntry{}
+	}
+	plaintext, err := json.Marshal(entries)
+	if err != nil {
+		return fmt.Errorf("failed to marshal vault entries: %v", err)
+	}
+
+	file := vaultFile{
+		Version:   vaultVersion,
+		Kdf:       vaultKdf,
+		KdfParams: v.kdfParams,
+		Salt:      salt,
+		Nonce:     nonce,
+	}
+	file.Ciphertext = aead.Seal(nil, nonce, plaintext, vaultAdditionalData(file))
+
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal vault: %v", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(v.path), 0700); err != nil {
+		return fmt.Errorf("failed to create %s: %v", filepath.Dir(v.path), err)
+	}
+
+	tmpPath := v.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %v", tmpPath, err)
+	}
+	if err := os.Rename(tmpPath, v.path); err != nil {
+		return fmt.Errorf("failed to rename %s: %v", tmpPath, err)
+	}
+
+	return nil
+}
+
+func (v *Vault) Get(accountName, serviceName string) (string, bool) {
+	for _, entry := range v.entries {
+		if entry.AccountName == accountName && entry.ServiceName == serviceName {
+			return entry.Key, true
+		}
+	}
+	return "", false
+}
+
+// Add adds an entry, or replaces the key of an existing one. Call Save to persist it.
+func (v *Vault) Add(accountName, serviceName, key string) {
+	if v.Rotate(accountName, serviceName, key) == nil {
+		return
+	}
+
+	v.entries = append(v.entries, VaultEntry{
+		AccountName: accountName,
+		ServiceName: serviceName,
+		Key:         key,
+		CreatedAt:   time.Now().UTC(),
+	})
+}
+
+// Rotate replaces the key of an existing entry. Call Save to persist it.
+func (v *Vault) Rotate(accountName, serviceName, key string) error {
+	for i := range v.entries {
+		if v.entries[i].AccountName == accountName && v.entries[i].ServiceName == serviceName {
+			v.entries[i].Key = key
+			v.entries[i].RotatedAt = time.Now().UTC()
+			return nil
+		}
+	}
+	return fmt.Errorf("no entry for %s/%s in vault", accountName, serviceName)
+}
+
+func (v *Vault) Delete(accountName, serviceName string) error {
+	for i := range v.entries {
+		if v.entries[i].AccountName == accountName && v.entries[i].ServiceName == serviceName {
+			v.entries = append(v.entries[:i], v.entries[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("no entry for %s/%s in vault", accountName, serviceName)
+}
+
+// Entries returns a copy of the entries sorted by service and account.
+func (v *Vault) Entries() []VaultEntry {
+	entries := append([]VaultEntry(nil), v.entries...)
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].ServiceName != entries[j].ServiceName {
+			return entries[i].ServiceName < entries[j].ServiceName
+		}
+		return entries[i].AccountName < entries[j].AccountName
+	})
+	return entries
+}
+
+func newVaultCipher(passphrase string, salt []byte, params vaultKdfParams) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, params.N, params.R, params.P, vaultKeyLen)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive vault key: %v", err)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %v", err)
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// vaultAdditionalData binds the clear header to the ciphertext so it cannot be tampered with.
+func vaultAdditionalData(file vaultFile) []byte {
+	return []byte(fmt.Sprintf("go_foundation-vault:%d:%s:%d:%d:%d",
+		file.Version, file.Kdf, file.KdfParams.N, file.KdfParams.R, file.KdfParams.P))
+}
+
+// VaultProvider reads keys from a vault file. The vault is decrypted once and reopened only
+// when the file changes.
+type VaultProvider struct {
+	path       string
+	passphrase func() (string, error)
+
+	mu      sync.Mutex
+	vault   *Vault
+	modTime time.Time
+}
+
+// NewVaultProvider reads the vault at path, or DefaultVaultPath if path is empty, opening
+// it with the passphrase returned by passphrase.
+func NewVaultProvider(path string, passphrase func() (string, error)) *VaultProvider {
+	return &VaultProvider{
+		path:       path,
+		passphrase: passphrase,
+	}
+}
+
+// PassphraseFromEnv reads the vault passphrase from VaultPassphraseEnv.
+func PassphraseFromEnv() (string, error) {
+	passphrase := os.Getenv(VaultPassphraseEnv)
+	if passphrase == "" {
+		return "", fmt.Errorf("%s is not set", VaultPassphraseEnv)
+	}
+	return passphrase, nil
+}
+
+func (p *VaultProvider) Name() string {
+	return "vault"
+}
+
+func (p *VaultProvider) GetSecretKey(ctx context.Context, accountName, serviceName string) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	path := p.path
+	if path == "" {
+		defaultPath, err := DefaultVaultPath()
+		if err != nil {
+			return "", err
+		}
+		path = defaultPath
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", fmt.Errorf("%s does not exist", path)
+		}
+		return "", fmt.Errorf("failed to stat %s: %v", path, err)
+	}
+
+	if p.vault == nil || p.vault.path != path || !info.ModTime().Equal(p.modTime) {
+		passphrase, err := p.passphrase()
+		if err != nil {
+			return "", err
+		}
+
+		vault, err := OpenVault(path, passphrase)
+		if err != nil {
+			return "", err
+		}
+		p.vault = vault
+		p.modTime = info.ModTime()
+	}
+
+	key, ok := p.vault.Get(accountName, serviceName)
+	if !ok {
+		return "", fmt.Errorf("no entry for %s/%s in %s", accountName, serviceName, path)
+	}
+	return key, nil
+}
+
+func defaultVaultProvider() *VaultProvider {
+	return NewVaultProvider("", PassphraseFromEnv)
+}
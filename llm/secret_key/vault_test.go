@@ -0,0 +1,164 @@
+package secret_key_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sieglu2/go_foundation/llm/secret_key"
+)
+
+func TestVault(t *testing.T) {
+	t.Run("Entries survive save and open", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "vault.json")
+
+		vault, err := secret_key.CreateVault(path, "passphrase")
+		if err != nil {
+			t.Fatalf("CreateVault failed: %v", err)
+		}
+		vault.Add("my_anthropic", "claude", "claude-key")
+		vault.Add("my_openai", "chatgpt", "openai-key")
+		if err := vault.Save(); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+
+		reopened, err := secret_key.OpenVault(path, "passphrase")
+		if err != nil {
+			t.Fatalf("OpenVault failed: %v", err)
+		}
+		if key, ok := reopened.Get("my_anthropic", "claude"); !ok || key != "claude-key" {
+			t.Fatalf("expected claude-key, got %q (found: %v)", key, ok)
+		}
+		if entries := reopened.Entries(); len(entries) != 2 || entries[0].ServiceName != "chatgpt" {
+			t.Fatalf("expected 2 entries sorted by service, got %+v", entries)
+		}
+	})
+
+	t.Run("Keys are not stored in clear", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "vault.json")
+
+		vault, err := secret_key.CreateVault(path, "passphrase")
+		if err != nil {
+			t.Fatalf("CreateVault failed: %v", err)
+		}
+		vault.Add("my_anthropic", "claude", "very-secret-key")
+		if err := vault.Save(); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("ReadFile failed: %v", err)
+		}
+		if bytes.Contains(data, []byte("very-secret-key")) || bytes.Contains(data, []byte("my_anthropic")) {
+			t.Fatalf("vault file leaks its entries: %s", data)
+		}
+	})
+
+	t.Run("Wrong passphrase is rejected", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "vault.json")
+
+		if _, err := secret_key.CreateVault(path, "passphrase"); err != nil {
+			t.Fatalf("CreateVault failed: %v", err)
+		}
+		if _, err := secret_key.OpenVault(path, "not-the-passphrase"); !errors.Is(err, secret_key.ErrVaultPassphrase) {
+			t.Fatalf("expected ErrVaultPassphrase, got: %v", err)
+		}
+	})
+
+	t.Run("Tampered kdf parameters are rejected before deriving", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "vault.json")
+
+		if _, err := secret_key.CreateVault(path, "passphrase"); err != nil {
+			t.Fatalf("CreateVault failed: %v", err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("failed to read vault: %v", err)
+		}
+		for _, params := range [][2]string{
+			{`"n": 32768`, `"n": 1073741824`},
+			{`"n": 32768`, `"n": 1000`},
+			{`"p": 1`, `"p": 0`},
+		} {
+			tampered := bytes.Replace(data, []byte(params[0]), []byte(params[1]), 1)
+			if bytes.Equal(tampered, data) {
+				t.Fatalf("%s not found in the vault", params[0])
+			}
+			if err := os.WriteFile(path, tampered, 0600); err != nil {
+				t.Fatalf("failed to write vault: %v", err)
+			}
+			if _, err := secret_key.OpenVault(path, "passphrase"); err == nil || errors.Is(err, secret_key.ErrVaultPassphrase) {
+				t.Fatalf("expected %s rejected, got: %v", params[1], err)
+			}
+		}
+	})
+
+	t.Run("Create does not overwrite", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "vault.json")
+
+		if _, err := secret_key.CreateVault(path, "passphrase"); err != nil {
+			t.Fatalf("CreateVault failed: %v", err)
+		}
+		if _, err := secret_key.CreateVault(path, "passphrase"); !errors.Is(err, secret_key.ErrVaultExists) {
+			t.Fatalf("expected ErrVaultExists, got: %v", err)
+		}
+	})
+
+	t.Run("Rotate requires an existing entry", func(t *testing.T) {
+		vault, err := secret_key.CreateVault(filepath.Join(t.TempDir(), "vault.json"), "passphrase")
+		if err != nil {
+			t.Fatalf("CreateVault failed: %v", err)
+		}
+		if err := vault.Rotate("my_google", "gemini", "new-key"); err == nil {
+			t.Fatalf("Rotate should fail for a missing entry")
+		}
+
+		vault.Add("my_google", "gemini", "old-key")
+		if err := vault.Rotate("my_google", "gemini", "new-key"); err != nil {
+			t.Fatalf("Rotate failed: %v", err)
+		}
+		entry := vault.Entries()[0]
+		if entry.Key != "new-key" || entry.RotatedAt.IsZero() {
+			t.Fatalf("expected rotated entry, got %+v", entry)
+		}
+	})
+}
+
+func TestVaultProvider(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "vault.json")
+
+	vault, err := secret_key.CreateVault(path, "passphrase")
+	if err != nil {
+		t.Fatalf("CreateVault failed: %v", err)
+	}
+	vault.Add("my_deepseek", "deepseek", "deepseek-key")
+	if err := vault.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	t.Setenv(secret_key.VaultPassphraseEnv, "passphrase")
+	provider := secret_key.NewVaultProvider(path, secret_key.PassphraseFromEnv)
+
+	key, err := provider.GetSecretKey(ctx, "my_deepseek", "deepseek")
+	if err != nil {
+		t.Fatalf("GetSecretKey failed: %v", err)
+	}
+	if key != "deepseek-key" {
+		t.Fatalf("expected deepseek-key, got %s", key)
+	}
+
+	if _, err := provider.GetSecretKey(ctx, "my_hailuoai", "minimax"); err == nil {
+		t.Fatalf("GetSecretKey should fail for a missing entry")
+	}
+
+	t.Setenv(secret_key.VaultPassphraseEnv, "")
+	missing := secret_key.NewVaultProvider(path, secret_key.PassphraseFromEnv)
+	if _, err := missing.GetSecretKey(ctx, "my_deepseek", "deepseek"); err == nil {
+		t.Fatalf("GetSecretKey should fail without passphrase")
+	}
+}
@@ -0,0 +1,174 @@
+package vectorstore
+
+import (
+	"fmt"
+	"sort"
+)
+
+const kmeansIterations = 10
+
+// ivfIndex is an inverted file index: the vectors are clustered around centroids by
+// k-means and a search only scores the vectors of the clusters closest to the query.
+type ivfIndex struct {
+	centroids [][]float32
+	lists     []map[int]struct{}
+	// assignment is the list of every document index.
+	assignment map[int]int
+	probes     int
+}
+
+// BuildApproximateIndex clusters the documents into lists and makes Search score only the
+// documents of the probes lists closest to the query, trading some recall for speed on
+// large stores. Around sqrt(Len()) lists and a few probes is a common start. Documents
+// added later join their closest list without a rebuild.
+func (s *Store) BuildApproximateIndex(lists int, probes int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if lists <= 0 || probes <= 0 {
+		return fmt.Errorf("lists and probes must be positive")
+	}
+	if lists > len(s.vectors) {
+		return fmt.Errorf("cannot build %d lists from %d documents", lists, len(s.vectors))
+	}
+
+	centroids := kmeans(s.vectors, lists)
+	index := &ivfIndex{
+		centroids:  centroids,
+		lists:      make([]map[int]struct{}, lists),
+		assignment: make(map[int]int, len(s.vectors)),
+		probes:     min(probes, lists),
+	}
+	for i := range index.lists {
+		index.lists[i] = map[int]struct{}{}
+	}
+	for i, vector := range s.vectors {
+		index.add(i, vector)
+	}
+
+	s.ivf = index
+	return nil
+}
+
+// DropApproximateIndex goes back to searching every document.
+func (s *Store) DropApproximateIndex() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ivf = nil
+}
+
+// kmeans returns count centroids of vectors, seeded with vectors spread over the slice so
+// the result is deterministic.
+func kmeans(vectors [][]float32, count int) [][]float32 {
+	centroids := make([][]float32, count)
+	for i := range centroids {
+		centroids[i] = append([]float32(nil), vectors[i*len(vectors)/count]...)
+	}
+
+	assignment := make([]int, len(vectors))
+	for iteration := 0; iteration < kmeansIterations; iteration++ {
+		changed := iteration == 0
+		for i, vector := range vectors {
+			if closest := closestCentroid(centroids, vector); closest != assignment[i] {
+				assignment[i] = closest
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+
+		sums := make([][]float32, count)
+		sizes := make([]int, count)
+		for i := range sums {
+			sums[i] = make([]float32, len(vectors[0]))
+		}
+		for i, vector := range vectors {
+			sizes[assignment[i]]++
+			for d, value := range vector {
+				sums[assignment[i]][d] += value
+			}
+		}
+		for i := range centroids {
+			// an empty cluster keeps its previous centroid
+			if sizes[i] == 0 {
+				continue
+			}
+			for d := range sums[i] {
+				centroids[i][d] = sums[i][d] / float32(sizes[i])
+			}
+		}
+	}
+	return centroids
+}
+
+func closestCentroid(centroids [][]float32, vector []float32) int {
+	closest, best := 0, float32(-1)
+	for i, centroid := range centroids {
+		if distance := squaredDistance(centroid, vector); best < 0 || distance < best {
+			closest, best = i, distance
+		}
+	}
+	return closest
+}
+
+func squaredDistance(a, b []float32) float32 {
+	var sum float32
+	for i := range a {
+		diff := a[i] - b[i]
+		sum += diff * diff
+	}
+	return sum
+}
+
+func (i *ivfIndex) add(index int, vector []float32) {
+	list := closestCentroid(i.centroids, vector)
+	i.lists[list][index] = struct{}{}
+	i.assignment[index] = list
+}
+
+func (i *ivfIndex) remove(index int) {
+	if list, ok := i.assignment[index]; ok {
+		delete(i.lists[list], index)
+		delete(i.assignment, index)
+	}
+}
+
+// move records that the document at from is now at to.
+func (i *ivfIndex) move(from int, to int) {
+	if list, ok := i.assignment[from]; ok {
+		delete(i.lists[list], from)
+		delete(i.assignment, from)
+		i.lists[list][to] = struct{}{}
+		i.assignment[to] = list
+	}
+}
+
+// candidates returns the document indexes of the lists closest to query.
+func (i *ivfIndex) candidates(query []float32, metric Metric) []int {
+	type scoredList struct {
+		list  int
+		score float32
+	}
+
+	scored := make([]scoredList, len(i.centroids))
+	for list, centroid := range i.centroids {
+		// for Dot the lists with the largest product hold the best candidates
+		score := -squaredDistance(centroid, query)
+		if metric == Dot {
+			score = dot(centroid, query)
+		}
+		scored[list] = scoredList{list: list, score: score}
+	}
+	sort.Slice(scored, func(a, b int) bool {
+		return scored[a].score > scored[b].score
+	})
+
+	candidates := []int{}
+	for _, list := range scored[:i.probes] {
+		for index := range i.lists[list.list] {
+			candidates = append(candidates, index)
+		}
+	}
+	return candidates
+}
@@ -0,0 +1,111 @@
+package vectorstore
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/sieglu2/go_foundation/foundation"
+	"github.com/sieglu2/go_foundation/llm"
+)
+
+const defaultContextPrompt = "Answer the question using the sources below. Cite the sources you use by their number, " +
+	"and say so if the sources do not hold the answer."
+
+// Retriever embeds texts and queries with Embedder to fill and search Store.
+type Retriever struct {
+	Store    *Store
+	Embedder llm.Embedder
+	// Prompt introduces the sources in the system message of ContextMessages.
+	Prompt string
+}
+
+func NewRetriever(store *Store, embedder llm.Embedder) *Retriever {
+	return &Retriever{
+		Store:    store,
+		Embedder: embedder,
+		Prompt:   defaultContextPrompt,
+	}
+}
+
+// AddTexts embeds the documents without a vector from their text and adds them all.
+func (r *Retriever) AddTexts(ctx context.Context, documents ...Document) error {
+	logger := foundation.Logger()
+
+	var texts []string
+	var missing []int
+	for i, document := range documents {
+		if len(document.Vector) == 0 {
+			texts = append(texts, document.Text)
+			missing = append(missing, i)
+		}
+	}
+
+	if len(texts) > 0 {
+		vectors, err := r.Embedder.Embed(ctx, texts)
+		if err != nil {
+			logger.Errorf("failed to Embed %d documents: %v", len(texts), err)
+			return fmt.Errorf("failed to Embed %d documents: %v", len(texts), err)
+		}
+		if len(vectors) != len(texts) {
+			return fmt.Errorf("embedded %d documents into %d vectors", len(texts), len(vectors))
+		}
+
+		// keep the vectors off the caller's documents
+		documents = append([]Document(nil), documents...)
+		for i, index := range missing {
+			documents[index].Vector = vectors[i]
+		}
+	}
+
+	return r.Store.Add(documents...)
+}
+
+// Retrieve returns the k documents most similar to query among the ones kept by filter.
+func (r *Retriever) Retrieve(ctx context.Context, query string, k int, filter Filter) ([]Match, error) {
+	logger := foundation.Logger()
+
+	vectors, err := r.Embedder.Embed(ctx, []string{query})
+	if err != nil {
+		logger.Errorf("failed to Embed query: %v", err)
+		return nil, fmt.Errorf("failed to Embed query: %v", err)
+	}
+	if len(vectors) != 1 {
+		return nil, fmt.Errorf("embedded the query into %d vectors", len(vectors))
+	}
+
+	return r.Store.Search(vectors[0], k, filter)
+}
+
+// ContextMessages retrieves the k documents closest to question and returns the messages
+// to send: a system message listing them as numbered sources, then the question.
+func (r *Retriever) ContextMessages(ctx context.Context, question string, k int, filter Filter) ([]llm.LlmMessage, error) {
+	matches, err := r.Retrieve(ctx, question, k, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return []llm.LlmMessage{
+		{Role: llm.RoleSystem, Content: BuildContext(r.Prompt, matches)},
+		{Role: llm.RoleUser, Content: question},
+	}, nil
+}
+
+// BuildContext lists the matched documents as numbered sources after prompt.
+func BuildContext(prompt string, matches []Match) string {
+	var builder strings.Builder
+	builder.WriteString(prompt)
+	builder.WriteString("\n\nSources:")
+	if len(matches) == 0 {
+		builder.WriteString("\n(none)")
+	}
+	for i, match := range matches {
+		fmt.Fprintf(&builder, "\n\n[%d]", i+1)
+		if source := match.Document.Metadata["source"]; source != "" {
+			fmt.Fprintf(&builder, " %s", source)
+		}
+		builder.WriteString("\n")
+		builder.WriteString(strings.TrimSpace(match.Document.Text))
+	}
+	return builder.String()
+}
@@ -0,0 +1,316 @@
+// Package vectorstore is an in-memory vector index with metadata filtering and persistence,
+// searched by brute force or through an approximate inverted file index for larger sets.
+package vectorstore
+
+import (
+	"encoding/gob"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/sieglu2/go_foundation/foundation"
+)
+
+type Metric int
+
+const (
+	// Cosine compares the directions of the vectors, the usual choice for text embeddings.
+	Cosine Metric = iota
+	// Dot is the raw dot product, for embeddings meant to be compared that way.
+	Dot
+)
+
+type Document struct {
+	ID       string
+	Text     string
+	Metadata map[string]string
+	Vector   []float32
+}
+
+type Match struct {
+	Document Document
+	Score    float32
+}
+
+// Filter keeps the documents whose metadata it returns true for.
+type Filter func(metadata map[string]string) bool
+
+// MetadataEquals keeps the documents having all the given metadata values.
+func MetadataEquals(values map[string]string) Filter {
+	return func(metadata map[string]string) bool {
+		for key, value := range values {
+			if metadata[key] != value {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+type Store struct {
+	mu        sync.RWMutex
+	metric    Metric
+	dimension int
+	documents []Document
+	// vectors are the document vectors, normalized for Cosine, in the order of documents.
+	vectors [][]float32
+	ids     map[string]int
+	ivf     *ivfIndex
+}
+
+func NewStore(metric Metric) *Store {
+	return &Store{
+		metric: metric,
+		ids:    map[string]int{},
+	}
+}
+
+func (s *Store) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.documents)
+}
+
+// Add adds the documents, replacing the ones with the same id. All the vectors of a store
+// must have the same dimension.
+func (s *Store) Add(documents ...Document) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// the whole batch is validated first, a rejected batch leaves the store unchanged
+	dimension := s.dimension
+	for _, document := range documents {
+		if document.ID == "" {
+			return fmt.Errorf("document without id")
+		}
+		if len(document.Vector) == 0 {
+			return fmt.Errorf("document %s has no vector", document.ID)
+		}
+		if dimension == 0 {
+			dimension = len(document.Vector)
+		}
+		if len(document.Vector) != dimension {
+			return fmt.Errorf("document %s has dimension %d, the store has %d", document.ID, len(document.Vector), dimension)
+		}
+	}
+	s.dimension = dimension
+
+	for _, document := range documents {
+		vector := s.prepare(document.Vector)
+		if index, ok := s.ids[document.ID]; ok {
+			if s.ivf != nil {
+				s.ivf.remove(index)
+			}
+			s.documents[index] = document
+			s.vectors[index] = vector
+		} else {
+			s.ids[document.ID] = len(s.documents)
+			s.documents = append(s.documents, document)
+			s.vectors = append(s.vectors, vector)
+		}
+		if s.ivf != nil {
+			s.ivf.add(s.ids[document.ID], vector)
+		}
+	}
+	return nil
+}
+
+// Delete removes the documents of the given ids, unknown ids are ignored.
+func (s *Store) Delete(ids ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, id := range ids {
+		index, ok := s.ids[id]
+		if !ok {
+			continue
+		}
+
+		// move the last document into the hole
+		last := len(s.documents) - 1
+		if s.ivf != nil {
+			s.ivf.remove(index)
+			if index != last {
+				s.ivf.move(last, index)
+			}
+		}
+		s.documents[index] = s.documents[last]
+		s.vectors[index] = s.vectors[last]
+		s.ids[s.documents[index].ID] = index
+		s.documents = s.documents[:last]
+		s.vectors = s.vectors[:last]
+		delete(s.ids, id)
+	}
+}
+
+func (s *Store) Get(id string) (Document, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	index, ok := s.ids[id]
+	if !ok {
+		return Document{}, false
+	}
+	return s.documents[index], true
+}
+
+// Search returns the k documents most similar to query, best first, among the ones kept
+// by filter if not nil. It searches the approximate index once built, every document
+// otherwise.
+func (s *Store) Search(query []float32, k int, filter Filter) ([]Match, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if len(s.documents) == 0 || k <= 0 {
+		return nil, nil
+	}
+	if len(query) != s.dimension {
+		return nil, fmt.Errorf("query has dimension %d, the store has %d", len(query), s.dimension)
+	}
+	query = s.prepare(query)
+
+	var candidates []int
+	if s.ivf != nil {
+		candidates = s.ivf.candidates(query, s.metric)
+	}
+
+	var matches []Match
+	consider := func(index int) {
+		if filter != nil && !filter(s.documents[index].Metadata) {
+			return
+		}
+		matches = append(matches, Match{Document: s.documents[index], Score: dot(query, s.vectors[index])})
+	}
+	if candidates != nil {
+		for _, index := range candidates {
+			consider(index)
+		}
+	} else {
+		for index := range s.documents {
+			consider(index)
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].Score > matches[j].Score
+	})
+	if len(matches) > k {
+		matches = matches[:k]
+	}
+	return matches, nil
+}
+
+// prepare normalizes a vector for Cosine, so the similarity is the dot product.
+func (s *Store) prepare(vector []float32) []float32 {
+	if s.metric != Cosine {
+		return vector
+	}
+	return normalize(vector)
+}
+
+func normalize(vector []float32) []float32 {
+	var norm float64
+	for _, value := range vector {
+		norm += float64(value) * float64(value)
+	}
+	normalized := make([]float32, len(vector))
+	if norm == 0 {
+		return normalized
+	}
+	norm = math.Sqrt(norm)
+	for i, value := range vector {
+		normalized[i] = float32(float64(value) / norm)
+	}
+	return normalized
+}
+
+func dot(a, b []float32) float32 {
+	var sum float32
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return sum
+}
+
+// storeFile is the persisted form of a Store.
+type storeFile struct {
+	Metric    Metric
+	Documents []Document
+	Lists     int
+	Probes    int
+}
+
+// Save writes the store to path. The approximate index is saved as its settings and
+// rebuilt by Load.
+func (s *Store) Save(path string) error {
+	logger := foundation.Logger()
+
+	// a copy, Add replaces documents in place once the lock is released
+	s.mu.RLock()
+	file := storeFile{Metric: s.metric, Documents: append([]Document(nil), s.documents...)}
+	if s.ivf != nil {
+		file.Lists, file.Probes = len(s.ivf.centroids), s.ivf.probes
+	}
+	s.mu.RUnlock()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		logger.Errorf("failed to MkdirAll for %s: %v", path, err)
+		return fmt.Errorf("failed to MkdirAll for %s: %v", path, err)
+	}
+
+	// write to a temporary file first so a crash never leaves a truncated store
+	tmpPath := path + ".tmp"
+	out, err := os.Create(tmpPath)
+	if err != nil {
+		logger.Errorf("failed to Create %s: %v", tmpPath, err)
+		return fmt.Errorf("failed to Create %s: %v", tmpPath, err)
+	}
+	if err := gob.NewEncoder(out).Encode(file); err != nil {
+		out.Close()
+		os.Remove(tmpPath)
+		logger.Errorf("failed to Encode store: %v", err)
+		return fmt.Errorf("failed to Encode store: %v", err)
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmpPath)
+		logger.Errorf("failed to Close %s: %v", tmpPath, err)
+		return fmt.Errorf("failed to Close %s: %v", tmpPath, err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		logger.Errorf("failed to Rename %s: %v", tmpPath, err)
+		return fmt.Errorf("failed to Rename %s: %v", tmpPath, err)
+	}
+	return nil
+}
+
+// Load reads a store written by Save.
+func Load(path string) (*Store, error) {
+	logger := foundation.Logger()
+
+	in, err := os.Open(path)
+	if err != nil {
+		logger.Errorf("failed to Open %s: %v", path, err)
+		return nil, fmt.Errorf("failed to Open %s: %v", path, err)
+	}
+	defer in.Close()
+
+	var file storeFile
+	if err := gob.NewDecoder(in).Decode(&file); err != nil {
+		logger.Errorf("failed to Decode store %s: %v", path, err)
+		return nil, fmt.Errorf("failed to Decode store %s: %v", path, err)
+	}
+
+	store := NewStore(file.Metric)
+	if err := store.Add(file.Documents...); err != nil {
+		return nil, err
+	}
+	if file.Lists > 0 {
+		if err := store.BuildApproximateIndex(file.Lists, file.Probes); err != nil {
+			return nil, err
+		}
+	}
+	return store, nil
+}
@@ -0,0 +1,195 @@
+package vectorstore_test
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sieglu2/go_foundation/llm"
+	"github.com/sieglu2/go_foundation/llm/vectorstore"
+)
+
+// fakeEmbedder embeds a text as the counts of the letters a, b and c.
+type fakeEmbedder struct{}
+
+func (fakeEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vectors[i] = []float32{
+			float32(strings.Count(text, "a")),
+			float32(strings.Count(text, "b")),
+			float32(strings.Count(text, "c")),
+		}
+	}
+	return vectors, nil
+}
+
+func ids(matches []vectorstore.Match) string {
+	var result []string
+	for _, match := range matches {
+		result = append(result, match.Document.ID)
+	}
+	return strings.Join(result, ",")
+}
+
+func TestStore(t *testing.T) {
+	documents := []vectorstore.Document{
+		{ID: "x", Vector: []float32{1, 0}, Metadata: map[string]string{"lang": "en"}},
+		{ID: "xy", Vector: []float32{1, 1}, Metadata: map[string]string{"lang": "fr"}},
+		{ID: "long-x", Vector: []float32{10, 1}, Metadata: map[string]string{"lang": "en"}},
+	}
+
+	t.Run("Cosine ignores length", func(t *testing.T) {
+		store := vectorstore.NewStore(vectorstore.Cosine)
+		if err := store.Add(documents...); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+		matches, err := store.Search([]float32{1, 0}, 2, nil)
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if ids(matches) != "x,long-x" || matches[0].Score < 0.999 {
+			t.Fatalf("unexpected matches: %+v", matches)
+		}
+	})
+
+	t.Run("Dot favours length", func(t *testing.T) {
+		store := vectorstore.NewStore(vectorstore.Dot)
+		store.Add(documents...)
+		matches, _ := store.Search([]float32{1, 0}, 1, nil)
+		if ids(matches) != "long-x" || matches[0].Score != 10 {
+			t.Fatalf("unexpected matches: %+v", matches)
+		}
+	})
+
+	t.Run("Metadata filter", func(t *testing.T) {
+		store := vectorstore.NewStore(vectorstore.Cosine)
+		store.Add(documents...)
+		matches, _ := store.Search([]float32{1, 0}, 3, vectorstore.MetadataEquals(map[string]string{"lang": "fr"}))
+		if ids(matches) != "xy" {
+			t.Fatalf("unexpected matches: %+v", matches)
+		}
+	})
+
+	t.Run("Replace and delete", func(t *testing.T) {
+		store := vectorstore.NewStore(vectorstore.Cosine)
+		store.Add(documents...)
+		store.Add(vectorstore.Document{ID: "x", Vector: []float32{0, 1}})
+		store.Delete("xy", "unknown")
+
+		if store.Len() != 2 {
+			t.Fatalf("expected 2 documents, got %d", store.Len())
+		}
+		matches, _ := store.Search([]float32{0, 1}, 1, nil)
+		if ids(matches) != "x" {
+			t.Fatalf("expected the replaced vector, got %+v", matches)
+		}
+		if _, ok := store.Get("xy"); ok {
+			t.Fatalf("xy should be deleted")
+		}
+	})
+
+	t.Run("Dimension mismatch", func(t *testing.T) {
+		store := vectorstore.NewStore(vectorstore.Cosine)
+		store.Add(documents...)
+		if err := store.Add(vectorstore.Document{ID: "z", Vector: []float32{1, 2, 3}}); err == nil {
+			t.Fatalf("adding another dimension should fail")
+		}
+		if _, err := store.Search([]float32{1}, 1, nil); err == nil {
+			t.Fatalf("searching another dimension should fail")
+		}
+	})
+
+	t.Run("Rejected batch keeps the store empty", func(t *testing.T) {
+		store := vectorstore.NewStore(vectorstore.Cosine)
+		err := store.Add(vectorstore.Document{ID: "z", Vector: []float32{1, 2, 3}}, vectorstore.Document{ID: "w", Vector: []float32{1}})
+		if err == nil {
+			t.Fatalf("adding mixed dimensions should fail")
+		}
+		if err := store.Add(documents...); err != nil || store.Len() != len(documents) {
+			t.Fatalf("the rejected batch should not fix the dimension: %v", err)
+		}
+	})
+}
+
+func TestApproximateIndex(t *testing.T) {
+	// 4 clusters of 25 points around the axes
+	axes := [][]float32{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}, {-1, 0, 0}}
+	store := vectorstore.NewStore(vectorstore.Cosine)
+	for i := 0; i < 100; i++ {
+		axis := axes[i%len(axes)]
+		jitter := float32(i) / 1000
+		store.Add(vectorstore.Document{
+			ID:     fmt.Sprintf("%d", i),
+			Vector: []float32{axis[0] + jitter, axis[1] + jitter, axis[2] - jitter},
+		})
+	}
+
+	exact, _ := store.Search([]float32{0, 1, 0}, 5, nil)
+	if err := store.BuildApproximateIndex(4, 1); err != nil {
+		t.Fatalf("BuildApproximateIndex failed: %v", err)
+	}
+	approximate, _ := store.Search([]float32{0, 1, 0}, 5, nil)
+	if ids(approximate) != ids(exact) {
+		t.Fatalf("expected the exact neighbours %s, got %s", ids(exact), ids(approximate))
+	}
+
+	// documents added or deleted later are kept in the index
+	store.Add(vectorstore.Document{ID: "new", Vector: []float32{0, 1, 0}})
+	store.Delete("1")
+	matches, _ := store.Search([]float32{0, 1, 0}, 100, nil)
+	if matches[0].Document.ID != "new" || strings.Contains(","+ids(matches)+",", ",1,") {
+		t.Fatalf("unexpected matches: %s", ids(matches))
+	}
+
+	t.Run("Save and load", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "store", "vectors.gob")
+		if err := store.Save(path); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+		loaded, err := vectorstore.Load(path)
+		if err != nil {
+			t.Fatalf("Load failed: %v", err)
+		}
+		if loaded.Len() != store.Len() {
+			t.Fatalf("expected %d documents, got %d", store.Len(), loaded.Len())
+		}
+		reloaded, _ := loaded.Search([]float32{0, 1, 0}, 5, nil)
+		expected, _ := store.Search([]float32{0, 1, 0}, 5, nil)
+		if ids(reloaded) != ids(expected) {
+			t.Fatalf("expected %s after load, got %s", ids(expected), ids(reloaded))
+		}
+	})
+}
+
+func TestRetriever(t *testing.T) {
+	ctx := context.Background()
+	retriever := vectorstore.NewRetriever(vectorstore.NewStore(vectorstore.Cosine), fakeEmbedder{})
+
+	err := retriever.AddTexts(ctx,
+		vectorstore.Document{ID: "1", Text: "aaa", Metadata: map[string]string{"source": "a.md"}},
+		vectorstore.Document{ID: "2", Text: "bbb"},
+		vectorstore.Document{ID: "3", Text: "ignored", Vector: []float32{0, 0, 1}},
+	)
+	if err != nil {
+		t.Fatalf("AddTexts failed: %v", err)
+	}
+
+	matches, err := retriever.Retrieve(ctx, "ccc", 1, nil)
+	if err != nil || ids(matches) != "3" {
+		t.Fatalf("expected the given vector to be kept, got %+v: %v", matches, err)
+	}
+
+	messages, err := retriever.ContextMessages(ctx, "about a?", 1, nil)
+	if err != nil {
+		t.Fatalf("ContextMessages failed: %v", err)
+	}
+	if len(messages) != 2 || messages[0].Role != llm.RoleSystem || messages[1].Content != "about a?" {
+		t.Fatalf("unexpected messages: %+v", messages)
+	}
+	if !strings.Contains(messages[0].Content, "[1] a.md\naaa") {
+		t.Fatalf("the system message should list the source, got: %s", messages[0].Content)
+	}
+}
@@ -1,13 +1,49 @@
 package main
 
 import (
-	"log"
+	"fmt"
 	"os"
 )
 
+type subcommand struct {
+	name  string
+	usage string
+	run   func(args []string) error
+}
+
+// subcommands are kept in alphabetical order, as printed by the usage.
+var subcommands = []subcommand{
+	{name: "batch", usage: "run the prompts of a jsonl file and append the replies to another, resuming after a crash", run: runBatch},
+	{name: "chat", usage: "start an interactive chat session with an llm provider", run: runChat},
+	{name: "diagnose", usage: "check the api key, models and latency of every llm provider", run: runDiagnose},
+	{name: "keys", usage: "store, test, delete and list the api keys of llm providers", run: runKeys},
+	{name: "vault", usage: "create and edit the encrypted vault of api keys", run: runVault},
+}
+
+func printUsage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s <command> [flags]\n\nCommands:\n", os.Args[0])
+	for _, cmd := range subcommands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", cmd.name, cmd.usage)
+	}
+}
+
 func main() {
-	logPath := "./xyz"
-	if err := os.RemoveAll(logPath); err != nil {
-		log.Fatalf("failed to remove WAL directory: %w", err)
+	if len(os.Args) < 2 {
+		printUsage()
+		os.Exit(2)
 	}
+
+	for _, cmd := range subcommands {
+		if cmd.name == os.Args[1] {
+			if err := cmd.run(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", cmd.name, err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "unknown command: %s\n\n", os.Args[1])
+	printUsage()
+	os.Exit(2)
 }
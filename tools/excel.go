@@ -1,4 +1,4 @@
-package foundation
+package tools
 
 import (
 	"fmt"
@@ -54,7 +54,7 @@ func CreateEmptyExcelSheet(fileName string, sheetNames []string) (*ExcelWriter,
 }
 
 func (c *ExcelWriter) SaveFile() error {
-	logger := Logger()
+	logger := foundation.Logger()
 
 	if c.fileHandle == nil {
 		logger.Warn(ErrEmptyFileHandle)
@@ -70,7 +70,7 @@ func (c *ExcelWriter) SaveFile() error {
 }
 
 func (c *ExcelWriter) AppendDataAsRows(sheetName string, labelTagStr string, bars []any) error {
-	logger := Logger()
+	logger := foundation.Logger()
 
 	if c.fileHandle == nil {
 		logger.Warn(ErrEmptyFileHandle)
@@ -121,7 +121,7 @@ func (c *ExcelWriter) AppendDataAsRows(sheetName string, labelTagStr string, bar
 }
 
 func (c *ExcelWriter) WriteStructFieldsAsRows(sheetName string, labelTagStr string, data any) error {
-	logger := Logger()
+	logger := foundation.Logger()
 
 	if c.fileHandle == nil {
 		logger.Warn(ErrEmptyFileHandle)
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sieglu2/go_foundation/llm/secret_key"
+	"golang.org/x/term"
+)
+
+const vaultUsage = `Usage: vault [-path file] <action> [args]
+
+Actions:
+  create                      create an empty vault
+  add <provider> [key]        add the api key of a provider, read from stdin if key is omitted
+  rotate <provider> [key]     replace the api key of a provider already in the vault
+  delete <provider>           remove the api key of a provider
+  list                        list the entries of the vault
+
+The passphrase is read from $` + secret_key.VaultPassphraseEnv + ` if set, otherwise prompted.`
+
+func runVault(args []string) error {
+	flags := flag.NewFlagSet("vault", flag.ContinueOnError)
+	path := flags.String("path", "", "vault file, the default vault under the user config directory if empty")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		return fmt.Errorf("missing action\n%s", vaultUsage)
+	}
+
+	vaultPath := *path
+	if vaultPath == "" {
+		defaultPath, err := secret_key.DefaultVaultPath()
+		if err != nil {
+			return err
+		}
+		vaultPath = defaultPath
+	}
+
+	action, args := flags.Arg(0), flags.Args()[1:]
+	switch action {
+	case "create":
+		return runVaultCreate(vaultPath)
+	case "add", "rotate":
+		return runVaultSet(vaultPath, action, args)
+	case "delete":
+		return runVaultDelete(vaultPath, args)
+	case "list":
+		return runVaultList(vaultPath)
+	}
+
+	return fmt.Errorf("unknown action: %s\n%s", action, vaultUsage)
+}
+
+func runVaultCreate(path string) error {
+	passphrase := os.Getenv(secret_key.VaultPassphraseEnv)
+	if passphrase == "" {
+		var err error
+		passphrase, err = readSecret("New vault passphrase: ")
+		if err != nil {
+			return err
+		}
+		confirm, err := readSecret("Confirm passphrase: ")
+		if err != nil {
+			return err
+		}
+		if passphrase != confirm {
+			return fmt.Errorf("passphrases do not match")
+		}
+	}
+
+	if _, err := secret_key.CreateVault(path, passphrase); err != nil {
+		return err
+	}
+
+	fmt.Printf("Created vault %s\n", path)
+	return nil
+}
+
+func runVaultSet(path string, action string, args []string) error {
+	if len(args) != 1 && len(args) != 2 {
+		return fmt.Errorf("usage: vault %s <provider> [key]", action)
+	}
+
+	provider, err := lookupProvider(args[0])
+	if err != nil {
+		return err
+	}
+
+	vault, err := openVault(path)
+	if err != nil {
+		return err
+	}
+
+	var key string
+	if len(args) == 2 {
+		key = args[1]
+	} else if key, err = readSecret(fmt.Sprintf("Enter the api key for %s: ", provider.Name)); err != nil {
+		return err
+	}
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return fmt.Errorf("empty key")
+	}
+
+	if action == "rotate" {
+		if err := vault.Rotate(provider.AccountName, provider.ServiceName, key); err != nil {
+			return err
+		}
+	} else {
+		vault.Add(provider.AccountName, provider.ServiceName, key)
+	}
+
+	if err := vault.Save(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Successfully stored API key for %s in %s\n", provider.Name, path)
+	return nil
+}
+
+func runVaultDelete(path string, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: vault delete <provider>")
+	}
+
+	provider, err := lookupProvider(args[0])
+	if err != nil {
+		return err
+	}
+
+	vault, err := openVault(path)
+	if err != nil {
+		return err
+	}
+
+	if err := vault.Delete(provider.AccountName, provider.ServiceName); err != nil {
+		return err
+	}
+	if err := vault.Save(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Successfully deleted API key for %s from %s\n", provider.Name, path)
+	return nil
+}
+
+func runVaultList(path string) error {
+	vault, err := openVault(path)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%-10s %-14s %-14s %-20s %s\n", "SERVICE", "ACCOUNT", "KEY", "CREATED", "ROTATED")
+	for _, entry := range vault.Entries() {
+		rotated := "-"
+		if !entry.RotatedAt.IsZero() {
+			rotated = entry.RotatedAt.Format("2006-01-02 15:04:05")
+		}
+		fmt.Printf("%-10s %-14s %-14s %-20s %s\n", entry.ServiceName, entry.AccountName, secret_key.MaskKey(entry.Key),
+			entry.CreatedAt.Format("2006-01-02 15:04:05"), rotated)
+	}
+	return nil
+}
+
+func openVault(path string) (*secret_key.Vault, error) {
+	passphrase, err := secret_key.PassphraseFromEnv()
+	if err != nil {
+		if passphrase, err = readSecret("Vault passphrase: "); err != nil {
+			return nil, err
+		}
+	}
+	return secret_key.OpenVault(path, passphrase)
+}
+
+// readSecret prompts on stderr and reads a line without echo when stdin is a terminal.
+// stdinReader is shared by every read of a piped stdin, as a reader per read would buffer
+// the lines meant for the next ones.
+var stdinReader = bufio.NewReader(os.Stdin)
+
+func readSecret(prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+
+	fd := int(os.Stdin.Fd())
+	if term.IsTerminal(fd) {
+		secret, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", fmt.Errorf("failed to read from terminal: %v", err)
+		}
+		return string(secret), nil
+	}
+
+	line, err := stdinReader.ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("failed to read from stdin: %v", err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}